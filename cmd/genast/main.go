@@ -0,0 +1,352 @@
+// Genast writes the declarations of the nodes of the syntax tree of glox
+// from the description in pkg/lox/ast.def, with what follows from their
+// fields alone: the constructors, the part of Walk that visits the
+// children, and the JSON form of MarshalAST and UnmarshalAST. It is run by
+// go generate in pkg/lox:
+//
+//	genast [-o ast.go] ast.def
+//
+// The behavior of the nodes is in the eval and execute methods and the
+// switches on their types, which are written by hand. A new node is added
+// to ast.def, then given the methods it needs.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type node struct {
+	kind   string // Expr or Stmt
+	name   string
+	fields []field
+}
+
+type field struct {
+	names    []string // none if embedded
+	typ      string
+	optional bool   // a child or a token that may be nil
+	json     string // the name of the JSON member if not that of the field, "-" for none
+	comment  string
+}
+
+// child reports whether the field holds nodes for Walk to visit.
+func (f field) child() bool {
+	switch f.typ {
+	case "Expr", "Stmt", "[]Expr", "[]Stmt":
+		return len(f.names) > 0
+	}
+	return false
+}
+
+// syntax reports whether the field is part of the syntax of the node, for
+// its constructor and its JSON form.
+func (f field) syntax() bool {
+	switch f.typ {
+	case "Expr", "Stmt", "[]Expr", "[]Stmt", "*Token", "[]*Token", "Value":
+		return len(f.names) > 0 && f.json != "-"
+	}
+	return false
+}
+
+// member returns the name of the JSON member of the field called name.
+func (f field) member(name string) string {
+	if f.json != "" {
+		return f.json
+	}
+	return name
+}
+
+// jsonTypes are the types of the members of the JSON nodes by the type of
+// the fields.
+var jsonTypes = map[string]string{
+	"Expr":     "*jsonNode",
+	"Stmt":     "*jsonNode",
+	"[]Expr":   "[]*jsonNode",
+	"[]Stmt":   "[]*jsonNode",
+	"*Token":   "*jsonToken",
+	"[]*Token": "[]*jsonToken",
+	"Value":    "json.RawMessage",
+}
+
+func main() {
+	out := flag.String("o", "ast.go", "write the code to `file`")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, "usage: genast [-o ast.go] ast.def\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	nodes, err := parse(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "genast:", err)
+		os.Exit(1)
+	}
+	code, err := generate(filepath.Base(flag.Arg(0)), nodes)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "genast:", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*out, code, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "genast:", err)
+		os.Exit(1)
+	}
+}
+
+// parse reads the nodes described in the file name.
+func parse(name string) ([]*node, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var nodes []*node
+	seen := make(map[string]bool)
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text := sc.Text()
+		errorf := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%v:%v: %v", name, line, fmt.Sprintf(format, args...))
+		}
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(strings.TrimSpace(text), "#") {
+			continue
+		}
+
+		if text[0] != ' ' && text[0] != '\t' {
+			words := strings.Fields(text)
+			if len(words) != 2 || words[0] != "Expr" && words[0] != "Stmt" {
+				return nil, errorf("want Expr or Stmt and the name of a node")
+			}
+			if seen[words[1]] {
+				return nil, errorf("node %v is described twice", words[1])
+			}
+			seen[words[1]] = true
+			nodes = append(nodes, &node{kind: words[0], name: words[1]})
+			continue
+		}
+
+		if len(nodes) == 0 {
+			return nil, errorf("field outside of a node")
+		}
+		var fd field
+		if i := strings.Index(text, "//"); i >= 0 {
+			fd.comment = strings.TrimSpace(text[i+2:])
+			text = text[:i]
+		}
+		if i := strings.Index(text, `"`); i >= 0 {
+			name, err := strconv.Unquote(strings.TrimSpace(text[i:]))
+			if err != nil || name == "" {
+				return nil, errorf("bad name of the JSON member")
+			}
+			fd.json = name
+			text = text[:i]
+		}
+		words := strings.Fields(strings.ReplaceAll(text, ",", " "))
+		if len(words) == 0 {
+			return nil, errorf("missing field")
+		}
+		fd.names, fd.typ = words[:len(words)-1], words[len(words)-1]
+		if strings.HasSuffix(fd.typ, "?") {
+			fd.typ = strings.TrimSuffix(fd.typ, "?")
+			fd.optional = true
+			if !fd.child() && fd.typ != "*Token" {
+				return nil, errorf("only children and tokens can be missing")
+			}
+		}
+		if fd.json != "" && len(fd.names) != 1 {
+			return nil, errorf("the name of the JSON member is for a single field")
+		}
+		n := nodes[len(nodes)-1]
+		n.fields = append(n.fields, fd)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// generate returns the formatted code for nodes, described in the file src.
+func generate(src string, nodes []*node) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by genast from %v; DO NOT EDIT.\n\n", src)
+	b.WriteString("package lox\n\n")
+	b.WriteString("import (\n\"encoding/json\"\n\"fmt\"\n)\n")
+
+	for _, kind := range []string{"Expr", "Stmt"} {
+		b.WriteString("\ntype (\n")
+		for _, n := range nodes {
+			if n.kind != kind {
+				continue
+			}
+			fmt.Fprintf(&b, "%v struct {\n", n.name)
+			for _, f := range n.fields {
+				if len(f.names) > 0 {
+					fmt.Fprintf(&b, "%v ", strings.Join(f.names, ", "))
+				}
+				b.WriteString(f.typ)
+				if f.comment != "" {
+					fmt.Fprintf(&b, " // %v", f.comment)
+				}
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "%v\n}\n\n", strings.ToLower(kind))
+		}
+		b.WriteString(")\n")
+	}
+
+	b.WriteString("\n// The constructors take the syntax of the nodes, the fields that the\n")
+	b.WriteString("// resolver and the interpreter fill in are left zero.\n")
+	for _, n := range nodes {
+		var params, inits []string
+		for _, f := range n.fields {
+			if !f.syntax() {
+				continue
+			}
+			params = append(params, fmt.Sprintf("%v %v", strings.Join(f.names, ", "), f.typ))
+			for _, name := range f.names {
+				inits = append(inits, fmt.Sprintf("%v: %v", name, name))
+			}
+		}
+		fmt.Fprintf(&b, "\nfunc new%v(%v) *%v {\n", n.name, strings.Join(params, ", "), n.name)
+		fmt.Fprintf(&b, "return &%v{%v}\n}\n", n.name, strings.Join(inits, ", "))
+	}
+
+	b.WriteString("\n// walkChildren calls Walk for the children of node.\n")
+	b.WriteString("func walkChildren(v Visitor, node Node) {\n")
+	b.WriteString("switch n := node.(type) {\n")
+	for _, n := range nodes {
+		fmt.Fprintf(&b, "case *%v:\n", n.name)
+		for _, f := range n.fields {
+			if !f.child() {
+				continue
+			}
+			for _, name := range f.names {
+				call := fmt.Sprintf("Walk(v, n.%v)", name)
+				switch f.typ {
+				case "[]Expr":
+					call = fmt.Sprintf("walkExprs(v, n.%v)", name)
+				case "[]Stmt":
+					call = fmt.Sprintf("walkStmts(v, n.%v)", name)
+				}
+				if f.optional {
+					call = fmt.Sprintf("if n.%v != nil {\n%v\n}", name, call)
+				}
+				fmt.Fprintf(&b, "%v\n", call)
+			}
+		}
+	}
+	b.WriteString("default:\npanic(\"unexpected node\")\n}\n}\n")
+
+	if err := generateJSON(&b, nodes); err != nil {
+		return nil, err
+	}
+	return format.Source(b.Bytes())
+}
+
+// generateJSON writes the JSON nodes and the code that converts the nodes
+// to them and back.
+func generateJSON(b *bytes.Buffer, nodes []*node) error {
+	b.WriteString("\n// jsonNode is a node in the JSON form of the AST, with the members of all\n")
+	b.WriteString("// the nodes.\n")
+	b.WriteString("type jsonNode struct {\n")
+	b.WriteString("Node string `json:\"node\"`\n")
+	b.WriteString("First *jsonToken `json:\"first,omitempty\"`\n")
+	b.WriteString("Last *jsonToken `json:\"last,omitempty\"`\n")
+	types := map[string]string{"node": "string", "first": "*jsonToken", "last": "*jsonToken"}
+	for _, n := range nodes {
+		for _, f := range n.fields {
+			if !f.syntax() {
+				continue
+			}
+			for _, name := range f.names {
+				m, typ := f.member(name), jsonTypes[f.typ]
+				if t, ok := types[m]; ok {
+					if t != typ {
+						return fmt.Errorf("the member %v of %v is %v, elsewhere it is %v", m, n.name, typ, t)
+					}
+					continue
+				}
+				types[m] = typ
+				fmt.Fprintf(b, "%v %v `json:\"%v,omitempty\"`\n", exported(m), typ, m)
+			}
+		}
+	}
+	b.WriteString("}\n")
+
+	b.WriteString("\n// fields sets the name and the members of the syntax of node in n.\n")
+	b.WriteString("func (enc *astEncoder) fields(n *jsonNode, node Node) {\n")
+	b.WriteString("switch node := node.(type) {\n")
+	for _, n := range nodes {
+		fmt.Fprintf(b, "case *%v:\n", n.name)
+		fmt.Fprintf(b, "n.Node = %q\n", n.name)
+		for _, f := range n.fields {
+			if !f.syntax() {
+				continue
+			}
+			for _, name := range f.names {
+				conv := map[string]string{
+					"Expr":     "enc.expr",
+					"Stmt":     "enc.stmt",
+					"[]Expr":   "enc.exprs",
+					"[]Stmt":   "enc.stmts",
+					"*Token":   "encodeToken",
+					"[]*Token": "encodeTokens",
+					"Value":    "enc.value",
+				}[f.typ]
+				fmt.Fprintf(b, "n.%v = %v(node.%v)\n", exported(f.member(name)), conv, name)
+			}
+		}
+	}
+	b.WriteString("default:\npanic(fmt.Sprintf(\"unexpected node %T\", node))\n}\n}\n")
+
+	b.WriteString("\n// node returns the node that n describes, nil if there is none by its\n")
+	b.WriteString("// name.\n")
+	b.WriteString("func (d *astDecoder) node(n *jsonNode) Node {\n")
+	b.WriteString("switch n.Node {\n")
+	for _, n := range nodes {
+		fmt.Fprintf(b, "case %q:\n", n.name)
+		var args []string
+		for _, f := range n.fields {
+			if !f.syntax() {
+				continue
+			}
+			for _, name := range f.names {
+				m := f.member(name)
+				member := "n." + exported(m)
+				var arg string
+				switch f.typ {
+				case "Expr", "Stmt":
+					arg = fmt.Sprintf("d.%v(%v, %q, %v)", strings.ToLower(f.typ), member, m, !f.optional)
+				case "[]Expr", "[]Stmt":
+					arg = fmt.Sprintf("d.%vs(%v)", strings.ToLower(f.typ[2:]), member)
+				case "*Token":
+					arg = fmt.Sprintf("d.token(n, %v, %q, %v)", member, m, !f.optional)
+				case "[]*Token":
+					arg = fmt.Sprintf("d.tokens(n, %v, %q)", member, m)
+				case "Value":
+					arg = fmt.Sprintf("d.value(%v)", member)
+				}
+				args = append(args, arg)
+			}
+		}
+		fmt.Fprintf(b, "return new%v(%v)\n", n.name, strings.Join(args, ", "))
+	}
+	b.WriteString("}\nreturn nil\n}\n")
+	return nil
+}
+
+// exported returns name with its first letter in upper case.
+func exported(name string) string {
+	return strings.ToUpper(name[:1]) + name[1:]
+}
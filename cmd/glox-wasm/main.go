@@ -0,0 +1,115 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Glox-wasm is glox for the browser. It defines the JavaScript function
+//
+//	runLox(source, onPrint) -> {output, errors}
+//
+// which runs the script in source in a new interpreter and returns what
+// it printed and the messages of its errors, an array that is empty if it
+// ran fine. onPrint, if given, is called with every line printed as the
+// script runs. The scripts read and write files in memory, that are gone
+// after the run, and cannot run programs or connect to the network. They
+// stop after maxSteps loop iterations and calls, since the page cannot
+// interrupt them while they run. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o glox.wasm ./cmd/glox-wasm
+//
+// and load it with the wasm_exec.js of the Go distribution.
+package main
+
+import (
+	"bytes"
+	"strings"
+	"syscall/js"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+func main() {
+	js.Global().Set("runLox", js.FuncOf(runLox))
+	// The function is called for as long as the page lives.
+	select {}
+}
+
+// maxSteps is the step budget of a run, see lox.Interpreter.MaxSteps.
+const maxSteps = 10_000_000
+
+func runLox(this js.Value, args []js.Value) interface{} {
+	if len(args) < 1 || args[0].Type() != js.TypeString {
+		// a panic would take down the Go runtime of the whole page
+		return map[string]interface{}{
+			"output": "",
+			"errors": []interface{}{"runLox: the source must be a string"},
+		}
+	}
+	var onPrint js.Value
+	if len(args) > 1 && args[1].Type() == js.TypeFunction {
+		onPrint = args[1]
+	}
+
+	out := &lineWriter{onPrint: onPrint}
+	in := lox.New(
+		lox.WithStdout(out),
+		lox.WithStdin(strings.NewReader("")),
+		lox.WithFS(&lox.MemFS{}),
+		lox.EnableFileIO(),
+		lox.DenyCapabilities(lox.CapTempFile),
+		lox.WithMaxSteps(maxSteps),
+	)
+	defer in.Cleanup()
+	err := in.Run(args[0].String())
+	out.flush()
+
+	msgs := []interface{}{}
+	if err != nil {
+		for _, e := range unjoin(err) {
+			msgs = append(msgs, e.Error())
+		}
+	}
+	return map[string]interface{}{
+		"output": out.buf.String(),
+		"errors": msgs,
+	}
+}
+
+// unjoin returns the errors that err joins, or err alone.
+func unjoin(err error) []error {
+	if j, ok := err.(interface{ Unwrap() []error }); ok {
+		return j.Unwrap()
+	}
+	return []error{err}
+}
+
+// lineWriter keeps the output of a script and passes every line of it to
+// onPrint, unless that is undefined.
+type lineWriter struct {
+	buf     bytes.Buffer
+	line    []byte // not yet ended
+	onPrint js.Value
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	if w.onPrint.Type() != js.TypeFunction {
+		return len(p), nil
+	}
+	w.line = append(w.line, p...)
+	for {
+		i := bytes.IndexByte(w.line, '\n')
+		if i < 0 {
+			break
+		}
+		w.onPrint.Invoke(string(w.line[:i]))
+		w.line = w.line[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush passes the last line to onPrint if it did not end.
+func (w *lineWriter) flush() {
+	if len(w.line) > 0 && w.onPrint.Type() == js.TypeFunction {
+		w.onPrint.Invoke(string(w.line))
+		w.line = nil
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// astCmd implements `glox ast file.lox`, which prints the parsed program as
+// JSON for tools that do not link the parser. See lox.MarshalAST for the
+// form.
+func astCmd(args []string) int {
+	fs := flag.NewFlagSet("ast", flag.ExitOnError)
+	indent := fs.Bool("indent", false, "indent the JSON")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, "usage: glox ast [-indent] file.lox\n")
+		fs.PrintDefaults()
+	}
+	files := parseArgs(fs, args)
+	if len(files) != 1 {
+		fs.Usage()
+		return 2
+	}
+	_, stmts, ok := loadFile(files[0])
+	if !ok {
+		return 1
+	}
+	data, err := lox.MarshalAST(stmts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *indent {
+		var buf bytes.Buffer
+		json.Indent(&buf, data, "", "  ")
+		data = buf.Bytes()
+	}
+	fmt.Printf("%s\n", data)
+	return 0
+}
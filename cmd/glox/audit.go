@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// auditCmd implements `glox audit file.lox`. It lists the natives that the
+// script refers to, grouped by the kind of host access they give, so that a
+// third-party script can be reviewed before it is run. Any reference counts,
+// not only calls, since a native can be stored and called later under
+// another name. The natives that give no host access are left out.
+func auditCmd(args []string) int {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, "usage: glox audit file.lox\n")
+		fs.PrintDefaults()
+	}
+	files := parseArgs(fs, args)
+	if len(files) != 1 {
+		fs.Usage()
+		return 2
+	}
+	_, stmts, ok := loadFile(files[0])
+	if !ok {
+		return 1
+	}
+
+	audit(os.Stdout, stmts)
+	return 0
+}
+
+// audit writes the natives with host access that stmts refer to to w.
+func audit(w io.Writer, stmts []lox.Stmt) {
+	byCap := make(map[string][]*lox.Symbol)
+	for _, sym := range lox.Resolve(stmts).Symbols() {
+		if sym.Kind() != lox.NativeSymbol {
+			continue
+		}
+		if c := string(lox.NativeCapability(sym.Name())); c != "" {
+			byCap[c] = append(byCap[c], sym)
+		}
+	}
+	if len(byCap) == 0 {
+		fmt.Fprintln(w, "no natives with host access are used")
+		return
+	}
+
+	caps := make([]string, 0, len(byCap))
+	for c := range byCap {
+		caps = append(caps, c)
+	}
+	sort.Strings(caps)
+	for _, c := range caps {
+		fmt.Fprintf(w, "%v:\n", c)
+		syms := byCap[c]
+		sort.Slice(syms, func(i, j int) bool { return syms[i].Name() < syms[j].Name() })
+		for _, sym := range syms {
+			at := make([]string, 0, len(sym.Refs()))
+			for _, t := range sym.Refs() {
+				at = append(at, fmt.Sprintf("%v:%v", t.Line(), t.Col()))
+			}
+			fmt.Fprintf(w, "  %v at %v\n", sym.Name(), strings.Join(at, " "))
+		}
+	}
+}
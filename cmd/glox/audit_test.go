@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		// a native stored under another name is counted where it is named
+		{"var r = readFile;\nr(\"x\");\nprint readFile;",
+			"file:\n  readFile at 1:9 3:7\n"},
+		{"exec(\"ls\");\nwriteFile(\"a\", \"b\");\nvar get = httpGet;",
+			"exec:\n  exec at 1:1\nfile:\n  writeFile at 2:1\nnetwork:\n  httpGet at 3:11\n"},
+		{"print toString(1);\nprint 1 + 2;", "no natives with host access are used\n"},
+		// a variable with the name of a native hides it
+		{"var readFile = 1;\nprint readFile;", "no natives with host access are used\n"},
+	}
+	for _, tt := range tests {
+		file := filepath.Join(t.TempDir(), "script.lox")
+		if err := os.WriteFile(file, []byte(tt.src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, stmts, ok := loadFile(file)
+		if !ok {
+			t.Fatalf("%q does not parse", tt.src)
+		}
+		var b strings.Builder
+		audit(&b, stmts)
+		if got := b.String(); got != tt.want {
+			t.Errorf("%q:\ngot  %q\nwant %q", tt.src, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+	"github.com/ysmolsky/glox/pkg/loxrt"
+)
+
+// buildCmd implements `glox build [-target go|js] [-o out] script.lox`,
+// which translates the script into a Go program. The source of the program
+// is printed, or written to out if it ends with .go, otherwise the go
+// command compiles it into the executable out. The runtime that the
+// program needs is written next to it, so no module is fetched. The
+// program denies the natives like glox does when it runs the script: the
+// capabilities given to glox with -allow and -deny, as in
+// `glox -allow file build script.lox`, are baked into it and cannot be
+// changed when it runs. With -target=js the script is translated into
+// JavaScript instead, which is printed or written to out; it only has the
+// natives without host access.
+func buildCmd(args []string) int {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	out := flags.String("o", "", "write the source to `file`, or compile the executable if it does not end with .go")
+	target := flags.String("target", "go", "the `language` to translate into: go or js")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, "usage: glox build [-target go|js] [-o out] script.lox\n")
+		flags.PrintDefaults()
+	}
+	files := parseArgs(flags, args)
+	if len(files) != 1 {
+		flags.Usage()
+		return 2
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var code []byte
+	switch *target {
+	case "go":
+		code, err = lox.TranslateGo(string(data),
+			lox.AllowCapabilities(allowed...), lox.DenyCapabilities(denied...))
+	case "js":
+		code, err = lox.TranslateJS(string(data))
+	default:
+		fmt.Fprintf(os.Stderr, "unknown target %q\n", *target)
+		flags.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	header := fmt.Sprintf("// Code generated by glox build from %v; DO NOT EDIT.\n\n", filepath.Base(files[0]))
+	code = append([]byte(header), code...)
+
+	switch {
+	case *out == "":
+		os.Stdout.Write(code)
+	case *target == "js" || strings.HasSuffix(*out, ".go"):
+		err = os.WriteFile(*out, code, 0644)
+	default:
+		err = compileGo(code, *out)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// compileGo compiles the program in code into the executable out. The
+// program and a copy of the runtime are laid out as two modules in a
+// scratch directory, the program's replaces the module of glox with the
+// copy.
+func compileGo(code []byte, out string) error {
+	out, err := filepath.Abs(out)
+	if err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp("", "glox-build-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"prog/go.mod": "module prog\n\ngo 1.17\n\n" +
+			"require github.com/ysmolsky/glox v0.0.0\n\n" +
+			"replace github.com/ysmolsky/glox => ../glox\n",
+		"prog/main.go": string(code),
+		"glox/go.mod":  "module github.com/ysmolsky/glox\n\ngo 1.17\n",
+	}
+	names, err := fs.Glob(loxrt.Source, "*.go")
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		data, err := loxrt.Source.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		files["glox/pkg/loxrt/"+name] = string(data)
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			return err
+		}
+	}
+
+	cmd := exec.Command("go", "build", "-o", out, ".")
+	cmd.Dir = filepath.Join(dir, "prog")
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("go build: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"go/format"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// buildPrograms are translated into Go and must print what they print with
+// the interpreter.
+var buildPrograms = []string{
+	`print 1 + 2 * 3 - 4 / 8; print "a" + "b"; print 0.1 + 0.2; print -0; print 1000000 * 1000000 * 1000000 * 1000; print 1 / 3;`,
+	`print nil == false; print 1 == 1; print "a" != "a"; print !nil; print nil or "x"; print 0 and 1;`,
+	`var a = 1; { var a = a + 1; print a; } print a;`,
+	`fun fib(n) { if (n < 2) return n; return fib(n - 1) + fib(n - 2); } print fib(15);`,
+	`fun counter() { var n = 0; fun inc() { n = n + 1; return n; } return inc; }
+var c = counter(); c(); print c(); var d = counter(); print d();`,
+	`fun show() { print later; } var later = "defined after"; show();`,
+	`var fs = nil; for (var i = 0; i < 3; i = i + 1) { var j = i; fun f() { return j; } if (i == 1) fs = f; } print fs();`,
+	`var i = 0; while (true) { i = i + 1; if (i > 4) break; } print i;`,
+	`var f = fun(a, b) { return a * b; }; print f(6, 7); print f;`,
+	`print "x";
+print undefined;`,
+	`fun f(a) { return a; }
+print f(1, 2);`,
+	`print 1 + nil;`,
+	// denied like by the interpreter
+	`var r = readFile;
+print "x";
+print r("build_test.go");`,
+}
+
+func TestBuildGo(t *testing.T) {
+	run := !testing.Short()
+	if _, err := exec.LookPath("go"); err != nil {
+		run = false
+	}
+	for _, src := range buildPrograms {
+		code, err := lox.TranslateGo(src)
+		if err != nil {
+			t.Errorf("%q: %v", src, err)
+			continue
+		}
+		if formatted, err := format.Source(code); err != nil {
+			t.Errorf("%q: %v\n%s", src, err, code)
+		} else if !bytes.Equal(formatted, code) {
+			t.Errorf("%q: the code is not formatted:\n%s", src, code)
+		}
+		if !run {
+			continue
+		}
+
+		var want bytes.Buffer
+		in := lox.New(lox.WithBackend("tree"), lox.WithStdout(&want))
+		if err := in.Run(src); err != nil {
+			want.WriteString(err.Error() + "\n")
+		}
+		exe := filepath.Join(t.TempDir(), "prog")
+		if err := compileGo(code, exe); err != nil {
+			t.Errorf("%q: %v\n%s", src, err, code)
+			continue
+		}
+		got, _ := exec.Command(exe).Output()
+		if string(got) != want.String() {
+			t.Errorf("%q:\ngot  %q\nwant %q", src, got, want.String())
+		}
+	}
+	if !run {
+		t.Log("the programs were not run")
+	}
+}
+
+func TestBuildGoTasks(t *testing.T) {
+	_, err := lox.TranslateGo("fun f() {}\nvar t = spawn(f);\njoin(t);")
+	want := "[line 2] error: the native 'spawn' of the tasks is not supported\n" +
+		"[line 3] error: the native 'join' of the tasks is not supported"
+	if err == nil || err.Error() != want {
+		t.Errorf("got %v, want %v", err, want)
+	}
+}
+
+// TestBuildGoCapabilities checks the capabilities that the program allows
+// and denies besides the default ones.
+func TestBuildGoCapabilities(t *testing.T) {
+	tests := []struct {
+		opts []lox.Option
+		want string
+	}{
+		{nil, "func main() {\n\tloxrt.Main(run)\n}"},
+		{[]lox.Option{lox.AllowCapabilities(lox.CapFile, lox.CapNetwork)},
+			"func main() {\n\tloxrt.Allow(\"file\", \"network\")\n\tloxrt.Main(run)\n}"},
+		// a capability in both is denied, like by glox
+		{[]lox.Option{lox.AllowCapabilities(lox.CapExec, lox.CapFile), lox.DenyCapabilities(lox.CapStdin, lox.CapFile)},
+			"func main() {\n\tloxrt.Allow(\"exec\")\n\tloxrt.Deny(\"stdin\")\n\tloxrt.Main(run)\n}"},
+	}
+	for _, tt := range tests {
+		code, err := lox.TranslateGo(`print readLine();`, tt.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(code), tt.want) {
+			t.Errorf("got:\n%s\nwant the main:\n%v", code, tt.want)
+		}
+	}
+}
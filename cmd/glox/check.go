@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// checkCmd implements `glox check file.lox...`. It scans, parses and
+// resolves the scripts without running them and reports every error found,
+// including the uses of names that are declared nowhere, which would fail
+// at run time. The files are checked together like `glox run` runs them,
+// so a script may use the globals of those before it.
+func checkCmd(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, "usage: glox check file.lox...\n")
+		fs.PrintDefaults()
+	}
+	files := parseArgs(fs, args)
+	if len(files) == 0 {
+		fs.Usage()
+		return 2
+	}
+	var program []lox.Stmt
+	fileOf := make(map[*lox.Token]string)
+	failed := false
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+			continue
+		}
+		tokens, err := lox.NewScanner(string(data)).Scan()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", file, err)
+			failed = true
+			continue
+		}
+		for _, t := range tokens {
+			fileOf[t] = file
+		}
+		// what could be parsed is resolved still, so that a declaration
+		// in it does not make for more errors
+		stmts, errs := lox.NewParser(tokens).Parse()
+		for _, e := range errs {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", file, e)
+			failed = true
+		}
+		program = append(program, stmts...)
+	}
+	for _, sym := range lox.Resolve(program).Symbols() {
+		if sym.Kind() != lox.UndefinedSymbol {
+			continue
+		}
+		for _, t := range sym.Refs() {
+			fmt.Fprintf(os.Stderr, "%v:%v:%v: undefined variable '%v'\n",
+				fileOf[t], t.Line(), t.Col(), sym.Name())
+			failed = true
+		}
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// coverage counts how many times the statements on every line of the
+// scripts ran, for -coverage and -cover-out. Only the lines with statements
+// that report to OnStatement count, and the lines typed into the REPL do
+// not count at all.
+type coverage struct {
+	files   []*coverFile // in the order they first ran
+	byName  map[string]*coverFile
+	current *coverFile // of the source being run, nil if it is not counted
+}
+
+type coverFile struct {
+	name  string
+	lines []string    // of the source
+	hits  map[int]int // the executable lines and the times they ran
+	order []int       // the executable lines in order
+}
+
+// cover is set by -coverage or -cover-out.
+var cover *coverage
+
+// The flags of the coverage: -coverage prints a summary to stderr,
+// -cover-out names the file for the lcov or HTML report.
+var (
+	coverReport = false
+	coverOut    = ""
+)
+
+func newCoverage() *coverage {
+	return &coverage{byName: make(map[string]*coverFile)}
+}
+
+// source sets the source that the following runs come from, file is its
+// name or empty if it is not counted. The lines of a file that ran before
+// add to what it counted then.
+func (c *coverage) source(file, src string) {
+	c.current = nil
+	if file == "" {
+		return
+	}
+	if f, ok := c.byName[file]; ok {
+		c.current = f
+		return
+	}
+	f := &coverFile{name: file, lines: strings.Split(src, "\n"), hits: make(map[int]int)}
+	toks, err := lox.NewScanner(src).Scan()
+	if err == nil {
+		if stmts, errs := lox.NewParser(toks).Parse(); len(errs) == 0 {
+			f.order = lox.StatementLines(stmts)
+		}
+	}
+	for _, line := range f.order {
+		f.hits[line] = 0
+	}
+	c.files = append(c.files, f)
+	c.byName[file] = f
+	c.current = f
+}
+
+func (c *coverage) hooks() *lox.Hooks {
+	return &lox.Hooks{
+		OnStatement: func(line int) {
+			if f := c.current; f != nil {
+				if _, ok := f.hits[line]; ok {
+					f.hits[line]++
+				}
+			}
+		},
+	}
+}
+
+// covered returns the number of executable lines of f that ran.
+func (f *coverFile) covered() int {
+	n := 0
+	for _, line := range f.order {
+		if f.hits[line] > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(n) / float64(total)
+}
+
+// finishCoverage reports the coverage of the runs if it is counted.
+func finishCoverage() {
+	if cover == nil {
+		return
+	}
+	if coverReport {
+		cover.summary(os.Stderr)
+	}
+	if coverOut != "" {
+		if err := cover.write(coverOut); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
+
+// summary prints the share of the lines that ran in every file and the
+// ranges of the lines that did not.
+func (c *coverage) summary(w io.Writer) {
+	all, covered := 0, 0
+	for _, f := range c.files {
+		n := f.covered()
+		all += len(f.order)
+		covered += n
+		fmt.Fprintf(w, "%v: %.1f%% of %v lines", f.name, percent(n, len(f.order)), len(f.order))
+		if missed := f.missed(); missed != "" {
+			fmt.Fprintf(w, ", not run: %v", missed)
+		}
+		fmt.Fprintln(w)
+	}
+	if len(c.files) > 1 {
+		fmt.Fprintf(w, "total: %.1f%% of %v lines\n", percent(covered, all), all)
+	}
+}
+
+// missed returns the executable lines of f that did not run, with the
+// adjacent ones joined into ranges like "4-7".
+func (f *coverFile) missed() string {
+	var ranges []string
+	for i := 0; i < len(f.order); i++ {
+		if f.hits[f.order[i]] > 0 {
+			continue
+		}
+		j := i
+		for j+1 < len(f.order) && f.hits[f.order[j+1]] == 0 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, fmt.Sprint(f.order[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%v-%v", f.order[i], f.order[j]))
+		}
+		i = j
+	}
+	return strings.Join(ranges, ", ")
+}
+
+// write writes the report to file, in HTML if its name ends with .html and
+// in the lcov format of genhtml and the editors otherwise.
+func (c *coverage) write(file string) error {
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	if strings.HasSuffix(file, ".html") {
+		c.writeHTML(w)
+	} else {
+		c.writeLcov(w)
+	}
+	err = w.Flush()
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (c *coverage) writeLcov(w io.Writer) {
+	fmt.Fprintln(w, "TN:")
+	for _, f := range c.files {
+		fmt.Fprintf(w, "SF:%v\n", f.name)
+		for _, line := range f.order {
+			fmt.Fprintf(w, "DA:%v,%v\n", line, f.hits[line])
+		}
+		fmt.Fprintf(w, "LF:%v\nLH:%v\nend_of_record\n", len(f.order), f.covered())
+	}
+}
+
+// writeHTML writes a page with the sources of the files, the lines that ran
+// in green and those that did not in red.
+func (c *coverage) writeHTML(w io.Writer) {
+	fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>glox coverage</title>
+<style>
+body { font-family: sans-serif; }
+pre { font-family: monospace; line-height: 1.3; }
+.hit { background: #dfd; }
+.miss { background: #fdd; }
+.n { color: #888; display: inline-block; width: 4em; text-align: right; margin-right: 1em; }
+</style>
+</head>
+<body>
+`)
+	for _, f := range c.files {
+		fmt.Fprintf(w, "<h2>%v: %.1f%%</h2>\n<pre>", html.EscapeString(f.name),
+			percent(f.covered(), len(f.order)))
+		for i, text := range f.lines {
+			line := i + 1
+			if i == len(f.lines)-1 && text == "" {
+				break
+			}
+			class, title := "", ""
+			if n, ok := f.hits[line]; ok {
+				class, title = "miss", fmt.Sprintf("ran %v times", n)
+				if n > 0 {
+					class = "hit"
+				}
+			}
+			fmt.Fprintf(w, "<span class=%q title=%q><span class=\"n\">%v</span>%v</span>\n",
+				class, title, line, html.EscapeString(text))
+		}
+		fmt.Fprint(w, "</pre>\n")
+	}
+	fmt.Fprint(w, "</body>\n</html>\n")
+}
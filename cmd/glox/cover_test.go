@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// coverScript has a branch that is not taken and a function that is called
+// twice.
+const coverScript = `fun sign(n) {
+  if (n < 0) {
+    print "negative";
+    return -1;
+  }
+  return 1;
+}
+sign(1);
+sign(2);
+`
+
+func TestCoverage(t *testing.T) {
+	c := newCoverage()
+	var out strings.Builder
+	in := lox.New(lox.WithStdout(&out))
+	in.Hooks = c.hooks()
+	c.source("sign.lox", coverScript)
+	if err := in.Run(coverScript); err != nil {
+		t.Fatal(err)
+	}
+	// what the REPL runs is not counted
+	c.source("", "")
+	if err := in.Run(`sign(-1);`); err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	c.summary(&b)
+	if got, want := b.String(), "sign.lox: 71.4% of 7 lines, not run: 3-4\n"; got != want {
+		t.Errorf("summary:\ngot  %q\nwant %q", got, want)
+	}
+	b.Reset()
+	c.writeLcov(&b)
+	want := "TN:\nSF:sign.lox\n" +
+		"DA:1,1\nDA:2,2\nDA:3,0\nDA:4,0\nDA:6,2\nDA:8,1\nDA:9,1\n" +
+		"LF:7\nLH:5\nend_of_record\n"
+	if got := b.String(); got != want {
+		t.Errorf("lcov:\ngot  %q\nwant %q", got, want)
+	}
+	b.Reset()
+	c.writeHTML(&b)
+	for _, want := range []string{
+		"<h2>sign.lox: 71.4%</h2>",
+		`<span class="hit" title="ran 2 times"><span class="n">2</span>  if (n &lt; 0) {</span>`,
+		`<span class="miss" title="ran 0 times"><span class="n">3</span>    print &#34;negative&#34;;</span>`,
+		`<span class="" title=""><span class="n">5</span>  }</span>`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("the HTML has no %q:\n%v", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,494 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// dapCmd implements `glox dap`, which debugs a script for an editor over
+// the Debug Adapter Protocol on stdin and stdout. The editor launches the
+// script with the arguments
+//
+//	{"program": "script.lox", "args": [...], "stopOnEntry": false}
+//
+// and can then set breakpoints on its lines, step through it, pause it and
+// look at the arguments of the calls on the stack and at the globals. The
+// output of the script is sent to the editor, its stdin is empty. The flags
+// given to glox apply to the script. There is one thread, the tasks of the
+// script show up in its stack, see debugger.
+func dapCmd(args []string) int {
+	flags := flag.NewFlagSet("dap", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, "usage: glox dap\n")
+		flags.PrintDefaults()
+	}
+	if len(parseArgs(flags, args)) > 0 {
+		flags.Usage()
+		return 2
+	}
+	s := &dapServer{
+		r:           bufio.NewReader(os.Stdin),
+		out:         os.Stdout,
+		breakpoints: make(map[string][]int),
+		configured:  make(chan struct{}),
+	}
+	if err := s.serve(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// dapServer answers the requests of an editor that debugs one script.
+type dapServer struct {
+	r *bufio.Reader
+
+	mu  sync.Mutex // of the writes to out
+	out io.Writer
+	seq int
+
+	breakpoints map[string][]int // the lines of the breakpoints by the path of the file
+	configured  chan struct{}    // closed by configurationDone
+	program     string           // the absolute path of the script, once launched
+	dbg         *debugger
+	script      *lox.Interpreter // runs the program
+	cancel      context.CancelFunc
+}
+
+// dapRequest is a request of the editor.
+type dapRequest struct {
+	Seq       int             `json:"seq"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// The variables references of the scopes, those of the locals of the
+// frames follow localsRef by the id of the frame.
+const (
+	globalsRef = 1
+	localsRef  = 1000
+)
+
+// serve answers the requests until the editor disconnects.
+func (s *dapServer) serve() error {
+	for {
+		body, err := readFrame(s.r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("dap: %v", err)
+		}
+		var req dapRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return fmt.Errorf("dap: %v", err)
+		}
+		if done := s.handle(&req); done {
+			return nil
+		}
+	}
+}
+
+// maxFrame is the size of the largest message that readFrame accepts, far
+// more than an editor sends, so that a bad Content-Length does not make it
+// allocate whatever it says.
+const maxFrame = 16 << 20
+
+// readFrame reads a message framed with a Content-Length header, as the
+// Debug Adapter and the Language Server Protocols send them.
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("bad Content-Length: %q", header.Get("Content-Length"))
+	}
+	if n > maxFrame {
+		return nil, fmt.Errorf("message of %v bytes is larger than %v", n, maxFrame)
+	}
+	body := make([]byte, n)
+	_, err = io.ReadFull(r, body)
+	return body, err
+}
+
+// writeFrame writes v as JSON framed with a Content-Length header.
+func writeFrame(w io.Writer, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+// send writes the message msg with the next sequence number.
+func (s *dapServer) send(msg map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	msg["seq"] = s.seq
+	writeFrame(s.out, msg)
+}
+
+func (s *dapServer) respond(req *dapRequest, body interface{}) {
+	msg := map[string]interface{}{
+		"type":        "response",
+		"request_seq": req.Seq,
+		"command":     req.Command,
+		"success":     true,
+	}
+	if body != nil {
+		msg["body"] = body
+	}
+	s.send(msg)
+}
+
+func (s *dapServer) fail(req *dapRequest, format string, args ...interface{}) {
+	s.send(map[string]interface{}{
+		"type":        "response",
+		"request_seq": req.Seq,
+		"command":     req.Command,
+		"success":     false,
+		"message":     fmt.Sprintf(format, args...),
+	})
+}
+
+func (s *dapServer) event(name string, body interface{}) {
+	msg := map[string]interface{}{"type": "event", "event": name}
+	if body != nil {
+		msg["body"] = body
+	}
+	s.send(msg)
+}
+
+// dapOutput sends what is written to it to the editor as output of the
+// category, stdout or stderr.
+type dapOutput struct {
+	s        *dapServer
+	category string
+}
+
+func (o dapOutput) Write(p []byte) (int, error) {
+	o.s.event("output", map[string]interface{}{"category": o.category, "output": string(p)})
+	return len(p), nil
+}
+
+// handle answers req and tells if the session is over.
+func (s *dapServer) handle(req *dapRequest) bool {
+	switch req.Command {
+	case "initialize":
+		s.respond(req, map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+		})
+		s.event("initialized", nil)
+	case "launch":
+		s.launch(req)
+	case "setBreakpoints":
+		s.setBreakpoints(req)
+	case "configurationDone":
+		select {
+		case <-s.configured:
+		default:
+			close(s.configured)
+		}
+		s.respond(req, nil)
+	case "threads":
+		s.respond(req, map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": 1, "name": "main"}},
+		})
+	case "stackTrace":
+		s.stackTrace(req)
+	case "scopes":
+		s.scopes(req)
+	case "variables":
+		s.variables(req)
+	case "evaluate":
+		s.evaluate(req)
+	case "continue":
+		s.resume(req, stepContinue)
+	case "next":
+		s.resume(req, stepOver)
+	case "stepIn":
+		s.resume(req, stepIn)
+	case "stepOut":
+		s.resume(req, stepOut)
+	case "pause":
+		if s.dbg != nil {
+			s.dbg.pause()
+		}
+		s.respond(req, nil)
+	case "disconnect", "terminate":
+		if s.dbg != nil {
+			s.cancel()
+			s.dbg.step(stepContinue)
+		}
+		s.respond(req, nil)
+		return req.Command == "disconnect"
+	default:
+		s.fail(req, "%v is not supported", req.Command)
+	}
+	return false
+}
+
+// launch starts the script once the editor has set the breakpoints.
+func (s *dapServer) launch(req *dapRequest) {
+	var args struct {
+		Program     string   `json:"program"`
+		Args        []string `json:"args"`
+		StopOnEntry bool     `json:"stopOnEntry"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		s.fail(req, "%v", err)
+		return
+	}
+	if s.dbg != nil {
+		s.fail(req, "the script is launched already")
+		return
+	}
+	if args.Program == "" {
+		s.fail(req, "no program to launch")
+		return
+	}
+	program, err := filepath.Abs(args.Program)
+	if err != nil {
+		s.fail(req, "%v", err)
+		return
+	}
+	data, err := os.ReadFile(program)
+	if err != nil {
+		s.fail(req, "%v", err)
+		return
+	}
+	s.program = program
+
+	entry := args.StopOnEntry
+	s.dbg = newDebugger(func(reason string) {
+		if entry {
+			reason, entry = "entry", false
+		}
+		s.event("stopped", map[string]interface{}{
+			"reason":            reason,
+			"threadId":          1,
+			"allThreadsStopped": true,
+		})
+	})
+	s.dbg.setBreakpoints(s.breakpoints[program])
+	if args.StopOnEntry {
+		s.dbg.pause()
+	}
+	s.script = newInterp(
+		lox.WithStdout(dapOutput{s, "stdout"}),
+		lox.WithStderr(dapOutput{s, "stderr"}),
+		lox.WithStdin(strings.NewReader("")),
+		lox.WithArgs(args.Args...),
+		lox.WithHooks(s.dbg.hooks()),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.respond(req, nil)
+
+	go func() {
+		<-s.configured
+		err := runIn(ctx, s.script, string(data))
+		s.script.Cleanup()
+		code := 0
+		if err != nil {
+			code = 1
+			if ctx.Err() == nil {
+				fmt.Fprintln(s.script.Stderr, err)
+			}
+		}
+		s.event("exited", map[string]interface{}{"exitCode": code})
+		s.event("terminated", nil)
+	}()
+}
+
+// setBreakpoints sets the breakpoints of a file, each on the first line
+// with a statement at or after the line asked for.
+func (s *dapServer) setBreakpoints(req *dapRequest) {
+	var args struct {
+		Source struct {
+			Path string `json:"path"`
+		} `json:"source"`
+		Breakpoints []struct {
+			Line int `json:"line"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		s.fail(req, "%v", err)
+		return
+	}
+	path, err := filepath.Abs(args.Source.Path)
+	if err != nil {
+		s.fail(req, "%v", err)
+		return
+	}
+	var stmtLines []int
+	if _, stmts, ok := loadFile(path); ok {
+		stmtLines = lox.StatementLines(stmts)
+	}
+	var lines []int
+	result := []map[string]interface{}{}
+	for _, bp := range args.Breakpoints {
+		i := 0
+		for i < len(stmtLines) && stmtLines[i] < bp.Line {
+			i++
+		}
+		if i == len(stmtLines) {
+			result = append(result, map[string]interface{}{
+				"verified": false,
+				"line":     bp.Line,
+				"message":  "no statement at or after this line",
+			})
+			continue
+		}
+		lines = append(lines, stmtLines[i])
+		result = append(result, map[string]interface{}{"verified": true, "line": stmtLines[i]})
+	}
+	s.breakpoints[path] = lines
+	if s.dbg != nil && path == s.program {
+		s.dbg.setBreakpoints(lines)
+	}
+	s.respond(req, map[string]interface{}{"breakpoints": result})
+}
+
+// resume goes on with the script that stopped.
+func (s *dapServer) resume(req *dapRequest, mode stepMode) {
+	if s.dbg == nil || !s.dbg.step(mode) {
+		s.fail(req, "the script is not stopped")
+		return
+	}
+	if mode == stepContinue {
+		s.respond(req, map[string]interface{}{"allThreadsContinued": true})
+	} else {
+		s.respond(req, nil)
+	}
+}
+
+// stackTrace lists the frames, the innermost first. The id of a frame is
+// its depth, so that it stays the same while the frame is on the stack.
+func (s *dapServer) stackTrace(req *dapRequest) {
+	if s.dbg == nil {
+		s.fail(req, "the script is not launched")
+		return
+	}
+	stack := s.dbg.stack()
+	frames := make([]map[string]interface{}, len(stack))
+	for i, fr := range stack {
+		frames[i] = map[string]interface{}{
+			"id":     len(stack) - i,
+			"name":   fr.name,
+			"line":   fr.line,
+			"column": 1,
+			"source": map[string]interface{}{
+				"name": filepath.Base(s.program),
+				"path": s.program,
+			},
+		}
+	}
+	s.respond(req, map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)})
+}
+
+func (s *dapServer) scopes(req *dapRequest) {
+	var args struct {
+		FrameID int `json:"frameId"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		s.fail(req, "%v", err)
+		return
+	}
+	scopes := []map[string]interface{}{{
+		"name":               "Locals",
+		"variablesReference": localsRef + args.FrameID,
+		"expensive":          false,
+	}}
+	scopes = append(scopes, map[string]interface{}{
+		"name":               "Globals",
+		"variablesReference": globalsRef,
+		"expensive":          false,
+	})
+	s.respond(req, map[string]interface{}{"scopes": scopes})
+}
+
+// variables lists the globals but the natives, or the parameters and the
+// locals in scope in a frame, as the program has them when it stopped.
+func (s *dapServer) variables(req *dapRequest) {
+	var args struct {
+		Ref int `json:"variablesReference"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		s.fail(req, "%v", err)
+		return
+	}
+	if s.dbg == nil {
+		s.fail(req, "the script is not launched")
+		return
+	}
+	vars := []map[string]interface{}{}
+	variable := func(name string, v lox.Value) {
+		value := v.Quoted()
+		if v.Type() == "uninitialized" {
+			value = "unassigned"
+		}
+		vars = append(vars, map[string]interface{}{
+			"name":               name,
+			"value":              value,
+			"type":               v.Type(),
+			"variablesReference": 0,
+		})
+	}
+	switch {
+	case args.Ref == globalsRef:
+		for _, name := range s.script.Globals() {
+			if v, _ := s.script.Global(name); v.Type() != "native" {
+				variable(name, v)
+			}
+		}
+	case args.Ref > localsRef:
+		for _, l := range s.dbg.locals(args.Ref - localsRef) {
+			variable(l.Name, l.Value)
+		}
+	}
+	s.respond(req, map[string]interface{}{"variables": vars})
+}
+
+// evaluate shows the value of a global variable, for the hovers and the
+// watches of the editor.
+func (s *dapServer) evaluate(req *dapRequest) {
+	var args struct {
+		Expression string `json:"expression"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		s.fail(req, "%v", err)
+		return
+	}
+	if s.dbg == nil {
+		s.fail(req, "the script is not launched")
+		return
+	}
+	name := strings.TrimSpace(args.Expression)
+	v, ok := s.script.Global(name)
+	if !ok {
+		s.fail(req, "only global variables can be evaluated, '%v' is not one", name)
+		return
+	}
+	s.respond(req, map[string]interface{}{
+		"result":             v.Quoted(),
+		"type":               v.Type(),
+		"variablesReference": 0,
+	})
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+func TestReadFrame(t *testing.T) {
+	var b bytes.Buffer
+	writeFrame(&b, map[string]int{"seq": 1})
+	b.WriteString("Content-Length: 2\r\nContent-Type: application/json\r\n\r\n{}")
+	r := bufio.NewReader(&b)
+	for _, want := range []string{`{"seq":1}`, `{}`} {
+		body, err := readFrame(r)
+		if err != nil || string(body) != want {
+			t.Errorf("got %q, %v, want %q", body, err, want)
+		}
+	}
+	if _, err := readFrame(r); err != io.EOF {
+		t.Errorf("got %v at the end, want EOF", err)
+	}
+
+	for _, tt := range []struct{ in, err string }{
+		{"Content-Length: 99999999999\r\n\r\n{}", "message of 99999999999 bytes is larger than 16777216"},
+		{"Content-Length: -1\r\n\r\n", `bad Content-Length: "-1"`},
+		{"Content-Type: text/plain\r\n\r\n", `bad Content-Length: ""`},
+		{"Content-Length: 10\r\n\r\n{}", "unexpected EOF"},
+	} {
+		_, err := readFrame(bufio.NewReader(strings.NewReader(tt.in)))
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%q: got %v, want %v", tt.in, err, tt.err)
+		}
+	}
+}
+
+// dapMessage is a response or an event of the server.
+type dapMessage struct {
+	Type       string          `json:"type"`
+	RequestSeq int             `json:"request_seq"`
+	Command    string          `json:"command"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Event      string          `json:"event"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// dapClient talks to a dapServer like an editor, it keeps the messages that
+// arrive before they are waited for and collects the output events.
+type dapClient struct {
+	t       *testing.T
+	w       io.Writer
+	msgs    chan *dapMessage
+	pending []*dapMessage
+	seq     int
+	output  strings.Builder
+	done    chan error // gets what serve returns
+}
+
+func newDAPClient(t *testing.T) *dapClient {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	s := &dapServer{
+		r:           bufio.NewReader(inR),
+		out:         outW,
+		breakpoints: make(map[string][]int),
+		configured:  make(chan struct{}),
+	}
+	c := &dapClient{t: t, w: inW, msgs: make(chan *dapMessage, 100), done: make(chan error, 1)}
+	go func() {
+		c.done <- s.serve()
+		outW.Close()
+	}()
+	go func() {
+		r := bufio.NewReader(outR)
+		defer close(c.msgs)
+		for {
+			body, err := readFrame(r)
+			if err != nil {
+				return
+			}
+			var msg dapMessage
+			if err := json.Unmarshal(body, &msg); err != nil {
+				t.Error(err)
+				return
+			}
+			c.msgs <- &msg
+		}
+	}()
+	t.Cleanup(func() { inW.Close() })
+	return c
+}
+
+// request sends the request and returns the response to it.
+func (c *dapClient) request(command string, args interface{}) *dapMessage {
+	c.t.Helper()
+	c.seq++
+	seq := c.seq
+	writeFrame(c.w, map[string]interface{}{"seq": seq, "type": "request", "command": command, "arguments": args})
+	return c.wait(command, func(m *dapMessage) bool {
+		return m.Type == "response" && m.RequestSeq == seq
+	})
+}
+
+// event returns the next event called name.
+func (c *dapClient) event(name string) *dapMessage {
+	c.t.Helper()
+	return c.wait(name+" event", func(m *dapMessage) bool {
+		return m.Type == "event" && m.Event == name
+	})
+}
+
+func (c *dapClient) wait(what string, match func(*dapMessage) bool) *dapMessage {
+	c.t.Helper()
+	for i, m := range c.pending {
+		if match(m) {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			return m
+		}
+	}
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case m, ok := <-c.msgs:
+			if !ok {
+				c.t.Fatalf("the server stopped before the %v", what)
+			}
+			switch {
+			case m.Type == "event" && m.Event == "output":
+				var out struct{ Output string }
+				json.Unmarshal(m.Body, &out)
+				c.output.WriteString(out.Output)
+			case match(m):
+				return m
+			default:
+				c.pending = append(c.pending, m)
+			}
+		case <-timeout:
+			c.t.Fatalf("no %v", what)
+		}
+	}
+}
+
+// body decodes the body of a successful response or an event into v, if
+// v is not nil.
+func (c *dapClient) body(m *dapMessage, v interface{}) {
+	c.t.Helper()
+	if m.Type == "response" && !m.Success {
+		c.t.Fatalf("%v failed: %v", m.Command, m.Message)
+	}
+	if v == nil {
+		return
+	}
+	if err := json.Unmarshal(m.Body, v); err != nil {
+		c.t.Fatalf("%v: %v", m.Command, err)
+	}
+}
+
+// stopped waits for the stopped event and returns its reason and the name
+// and the line of every frame, the innermost first.
+func (c *dapClient) stopped() (reason string, frames []string) {
+	c.t.Helper()
+	var ev struct{ Reason string }
+	c.body(c.event("stopped"), &ev)
+	var trace struct {
+		StackFrames []struct {
+			ID   int
+			Name string
+			Line int
+		}
+	}
+	c.body(c.request("stackTrace", map[string]int{"threadId": 1}), &trace)
+	for i, fr := range trace.StackFrames {
+		if fr.ID != len(trace.StackFrames)-i {
+			c.t.Errorf("frame %v has the id %v", fr.Name, fr.ID)
+		}
+		frames = append(frames, fr.Name+":"+strconv.Itoa(fr.Line))
+	}
+	return ev.Reason, frames
+}
+
+// variables returns the variables of the reference as name=value.
+func (c *dapClient) variables(ref int) []string {
+	c.t.Helper()
+	var resp struct {
+		Variables []struct{ Name, Value, Type string }
+	}
+	c.body(c.request("variables", map[string]int{"variablesReference": ref}), &resp)
+	var vars []string
+	for _, v := range resp.Variables {
+		vars = append(vars, v.Name+"="+v.Value)
+	}
+	return vars
+}
+
+const dapScript = `var total = 0;
+fun add(n) {
+  var twice = n * 2;
+  total = total + twice;
+  return total;
+}
+add(1);
+print add(2);
+`
+
+// TestDAPSession debugs a script like an editor: it sets breakpoints,
+// launches the script, looks at the stack and the variables where it stops
+// and steps through it.
+func TestDAPSession(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "script.lox")
+	if err := os.WriteFile(file, []byte(dapScript), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := newDAPClient(t)
+
+	var caps map[string]bool
+	c.body(c.request("initialize", map[string]string{"adapterID": "glox"}), &caps)
+	if !caps["supportsConfigurationDoneRequest"] {
+		t.Errorf("capabilities %v", caps)
+	}
+	c.event("initialized")
+	if m := c.request("stackTrace", nil); m.Success || m.Message != "the script is not launched" {
+		t.Errorf("stackTrace before launch: %+v", m)
+	}
+	if m := c.request("restart", nil); m.Success || m.Message != "restart is not supported" {
+		t.Errorf("restart: %+v", m)
+	}
+
+	// a breakpoint moves to the next line with a statement
+	var bps struct {
+		Breakpoints []struct {
+			Verified bool
+			Line     int
+		}
+	}
+	c.body(c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": file},
+		"breakpoints": []map[string]int{{"line": 4}, {"line": 6}, {"line": 30}},
+	}), &bps)
+	if got, want := fmt.Sprint(bps.Breakpoints), "[{true 4} {true 7} {false 30}]"; got != want {
+		t.Errorf("breakpoints %v, want %v", got, want)
+	}
+
+	c.body(c.request("launch", map[string]interface{}{"program": file}), nil)
+	c.request("configurationDone", nil)
+	if reason, frames := c.stopped(); reason != "breakpoint" || !reflect.DeepEqual(frames, []string{"main:7"}) {
+		t.Errorf("stopped for %v at %v, want a breakpoint at main:7", reason, frames)
+	}
+	if m := c.request("launch", map[string]interface{}{"program": file}); m.Success {
+		t.Error("a second launch succeeded")
+	}
+
+	c.request("continue", map[string]int{"threadId": 1})
+	if reason, frames := c.stopped(); reason != "breakpoint" || !reflect.DeepEqual(frames, []string{"add:4", "main:7"}) {
+		t.Errorf("stopped for %v at %v, want a breakpoint at add:4 main:7", reason, frames)
+	}
+	var scopes struct {
+		Scopes []struct {
+			Name               string
+			VariablesReference int
+		}
+	}
+	c.body(c.request("scopes", map[string]int{"frameId": 2}), &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].VariablesReference != localsRef+2 || scopes.Scopes[1].VariablesReference != globalsRef {
+		t.Fatalf("scopes %+v", scopes.Scopes)
+	}
+	if got, want := c.variables(localsRef+2), []string{"n=1", "twice=2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("locals %v, want %v", got, want)
+	}
+	if got, want := c.variables(globalsRef), []string{"add=<fn add>", "total=0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("globals %v, want %v", got, want)
+	}
+	var result struct{ Result, Type string }
+	c.body(c.request("evaluate", map[string]string{"expression": "total"}), &result)
+	if result.Result != "0" || result.Type != "number" {
+		t.Errorf("evaluate total: %+v", result)
+	}
+
+	c.request("next", map[string]int{"threadId": 1})
+	if reason, frames := c.stopped(); reason != "step" || !reflect.DeepEqual(frames, []string{"add:5", "main:7"}) {
+		t.Errorf("stopped for %v at %v, want a step to add:5 main:7", reason, frames)
+	}
+	if got, want := c.variables(globalsRef), []string{"add=<fn add>", "total=2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("globals %v, want %v", got, want)
+	}
+
+	c.request("continue", map[string]int{"threadId": 1})
+	if reason, frames := c.stopped(); reason != "breakpoint" || !reflect.DeepEqual(frames, []string{"add:4", "main:8"}) {
+		t.Errorf("stopped for %v at %v, want a breakpoint at add:4 main:8", reason, frames)
+	}
+	if got, want := c.variables(localsRef+2), []string{"n=2", "twice=4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("locals %v, want %v", got, want)
+	}
+
+	c.request("continue", map[string]int{"threadId": 1})
+	var exited struct{ ExitCode int }
+	c.body(c.event("exited"), &exited)
+	c.event("terminated")
+	if exited.ExitCode != 0 || c.output.String() != "6\n" {
+		t.Errorf("exited with %v and the output %q", exited.ExitCode, c.output.String())
+	}
+	if m := c.request("continue", nil); m.Success || m.Message != "the script is not stopped" {
+		t.Errorf("continue after the exit: %+v", m)
+	}
+	if m := c.request("disconnect", nil); !m.Success {
+		t.Errorf("disconnect: %+v", m)
+	}
+	if err := <-c.done; err != nil {
+		t.Error(err)
+	}
+}
+
+// TestDAPStopOnEntry launches a script that stops before its first
+// statement and then fails, the error goes to stderr.
+func TestDAPStopOnEntry(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "script.lox")
+	if err := os.WriteFile(file, []byte("print 1;\nprint nil + 1;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := newDAPClient(t)
+	c.request("initialize", nil)
+	c.body(c.request("launch", map[string]interface{}{"program": file, "stopOnEntry": true}), nil)
+	c.request("configurationDone", nil)
+	if reason, frames := c.stopped(); reason != "entry" || !reflect.DeepEqual(frames, []string{"main:1"}) {
+		t.Errorf("stopped for %v at %v, want the entry at main:1", reason, frames)
+	}
+	c.request("continue", nil)
+	var exited struct{ ExitCode int }
+	c.body(c.event("exited"), &exited)
+	want := "1\n[line 2] runtime error: operands of '+' must be two numbers or two strings, got nil and number\n"
+	if exited.ExitCode != 1 || c.output.String() != want {
+		t.Errorf("exited with %v and the output %q, want 1 and %q", exited.ExitCode, c.output.String(), want)
+	}
+	c.request("disconnect", nil)
+}
+
+// TestDebuggerTasks shows how the debugger sees the tasks: a task runs in
+// the frame on top of the stack, the join() that waits for it, and the
+// stack is back to the main program once the task is done.
+func TestDebuggerTasks(t *testing.T) {
+	src := "fun work() {\n  var x = 1;\n  return x;\n}\n" +
+		"fun main() {\n  var t = spawn(work);\n  return join(t);\n}\n" +
+		"print main();\nprint \"after\";\n"
+	for _, backend := range []string{"tree", "vm"} {
+		stopped := make(chan string, 1)
+		d := newDebugger(func(reason string) { stopped <- reason })
+		d.setBreakpoints([]int{3, 10})
+		var out bytes.Buffer
+		in := lox.New(lox.WithBackend(backend), lox.WithHooks(d.hooks()), lox.WithStdout(&out))
+		done := make(chan error, 1)
+		go func() { done <- in.Run(src) }()
+
+		<-stopped
+		if got, want := debugStack(d), []string{"join:3", "main:7", "main:9"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%v backend: the stack in the task is %v, want %v", backend, got, want)
+		}
+		if got := d.locals(3); len(got) != 1 || got[0].Name != "x" {
+			t.Errorf("%v backend: the locals of the task are %v", backend, got)
+		}
+		d.step(stepContinue)
+		<-stopped
+		if got, want := debugStack(d), []string{"main:10"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%v backend: the stack after the task is %v, want %v", backend, got, want)
+		}
+		d.step(stepContinue)
+		if err := <-done; err != nil || out.String() != "1\nafter\n" {
+			t.Errorf("%v backend: got %q, %v", backend, out.String(), err)
+		}
+	}
+}
+
+func debugStack(d *debugger) []string {
+	var frames []string
+	for _, fr := range d.stack() {
+		frames = append(frames, fr.name+":"+strconv.Itoa(fr.line))
+	}
+	return frames
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// deadcodeCmd implements `glox deadcode file.lox`, which lists the code that
+// can never run: functions that are not reachable from the top-level code,
+// branches and loops behind constant conditions and statements that follow
+// a return, break or continue.
+func deadcodeCmd(args []string) int {
+	fs := flag.NewFlagSet("deadcode", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, "usage: glox deadcode file.lox\n")
+		fs.PrintDefaults()
+	}
+	files := parseArgs(fs, args)
+	if len(files) != 1 {
+		fs.Usage()
+		return 2
+	}
+	_, stmts, ok := loadFile(files[0])
+	if !ok {
+		return 1
+	}
+	for _, f := range lox.DeadCode(stmts, lox.Resolve(stmts)) {
+		fmt.Printf("%v:%v:%v: %v\n", files[0], f.At.Line(), f.At.Col(), f.Msg)
+	}
+	return 0
+}
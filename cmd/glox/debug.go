@@ -0,0 +1,170 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// debugger stops a program at its breakpoints and steps through it, for
+// glox dap. It follows the program through its hooks: the program stops
+// in OnStatement, which waits until it is resumed.
+//
+// The hooks do not tell the tasks apart, so the calls of the tasks make one
+// stack with those of the main program, like for the profiler. A task that
+// starts runs in the frame on top of the stack, usually the join() that
+// waits for it, which then shows the line and the locals of the task, and
+// the calls of the task are pushed on top of it. While several tasks run,
+// the frames they push interleave.
+type debugger struct {
+	mu          sync.Mutex
+	breakpoints map[int]bool // the lines to stop at
+	mode        stepMode
+	depth       int // of the stack when the step began
+	frames      []debugFrame
+	last        [2]int // the line and the depth of the statement that ran last
+	paused      bool
+
+	// stopped is called by the program, with the reason, when it stops.
+	stopped func(reason string)
+	resume  chan stepMode
+}
+
+type debugFrame struct {
+	name  string
+	line  int        // of the statement running in the frame
+	scope *lox.Scope // of that statement, nil before the first
+}
+
+// stepMode tells where the program stops next, besides the breakpoints.
+type stepMode int
+
+const (
+	stepContinue stepMode = iota // at a breakpoint only
+	stepPause                    // at the next statement, for pause and stopOnEntry
+	stepIn                       // at the next statement
+	stepOver                     // at the next statement in the frame or an outer one
+	stepOut                      // at the next statement in an outer frame
+)
+
+func newDebugger(stopped func(reason string)) *debugger {
+	return &debugger{
+		breakpoints: make(map[int]bool),
+		frames:      []debugFrame{{name: "main"}},
+		stopped:     stopped,
+		resume:      make(chan stepMode),
+	}
+}
+
+func (d *debugger) hooks() *lox.Hooks {
+	return &lox.Hooks{
+		OnStatement: d.statement,
+		OnScope: func(sc *lox.Scope) {
+			d.mu.Lock()
+			d.frames[len(d.frames)-1].scope = sc
+			d.mu.Unlock()
+		},
+		OnCall: func(name string, args []lox.Value) {
+			if name == "" {
+				name = "(anonymous)"
+			}
+			d.mu.Lock()
+			d.frames = append(d.frames, debugFrame{name: name})
+			d.mu.Unlock()
+		},
+		OnReturn: func(lox.Value) {
+			d.mu.Lock()
+			if len(d.frames) > 1 {
+				d.frames = d.frames[:len(d.frames)-1]
+			}
+			d.mu.Unlock()
+		},
+	}
+}
+
+// statement stops the program before the statement at line if it should
+// and waits until it is resumed.
+func (d *debugger) statement(line int) {
+	d.mu.Lock()
+	depth := len(d.frames)
+	d.frames[depth-1].line = line
+	reason := ""
+	switch {
+	case d.mode == stepPause:
+		reason = "pause"
+	case d.mode == stepIn,
+		d.mode == stepOver && depth <= d.depth,
+		d.mode == stepOut && depth < d.depth:
+		reason = "step"
+	case d.breakpoints[line] && d.last != [2]int{line, depth}:
+		// a line with more statements than one stops once
+		reason = "breakpoint"
+	}
+	d.last = [2]int{line, depth}
+	if reason == "" {
+		d.mu.Unlock()
+		return
+	}
+	d.paused = true
+	d.mu.Unlock()
+
+	d.stopped(reason)
+	mode := <-d.resume
+	d.mu.Lock()
+	d.mode, d.depth, d.paused = mode, len(d.frames), false
+	d.mu.Unlock()
+}
+
+// setBreakpoints replaces the breakpoints with those at lines.
+func (d *debugger) setBreakpoints(lines []int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints = make(map[int]bool)
+	for _, line := range lines {
+		d.breakpoints[line] = true
+	}
+}
+
+// step resumes the program that stopped, to stop again as mode says. It
+// tells if the program was stopped.
+func (d *debugger) step(mode stepMode) bool {
+	d.mu.Lock()
+	paused := d.paused
+	d.mu.Unlock()
+	if paused {
+		d.resume <- mode
+	}
+	return paused
+}
+
+// pause stops the program at its next statement.
+func (d *debugger) pause() {
+	d.mu.Lock()
+	if !d.paused {
+		d.mode = stepPause
+	}
+	d.mu.Unlock()
+}
+
+// stack returns the frames of the calls, the innermost first.
+func (d *debugger) stack() []debugFrame {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stack := make([]debugFrame, len(d.frames))
+	for i, fr := range d.frames {
+		stack[len(d.frames)-1-i] = fr
+	}
+	return stack
+}
+
+// locals returns the local variables of the frame at depth, 1 for the
+// outermost, as they are now. They are read only while the program is
+// stopped, when they cannot change under the reader.
+func (d *debugger) locals(depth int) []lox.Variable {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.paused || depth < 1 || depth > len(d.frames) || d.frames[depth-1].scope == nil {
+		return nil
+	}
+	return d.frames[depth-1].scope.Locals()
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diff returns the changes from old to new, both the content of file, as
+// a unified diff with three lines of context.
+func diff(file, old, new string) string {
+	a, b := splitLines(old), splitLines(new)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var edits []edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i]})
+			i++
+			j++
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			edits = append(edits, edit{'-', a[i]})
+			i++
+		default:
+			edits = append(edits, edit{'+', b[j]})
+			j++
+		}
+	}
+
+	// a hunk runs from context lines before a change to context lines
+	// after the last change that is close enough to be joined with it
+	const context = 3
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %v\n+++ %v\n", file, file)
+	line := [2]int{1, 1} // in old and new of the next edit
+	for k := 0; k < len(edits); {
+		if edits[k].op == ' ' {
+			line[0]++
+			line[1]++
+			k++
+			continue
+		}
+		start := k
+		for start > 0 && k-start < context {
+			start--
+		}
+		end, last := k, k // end of the hunk and its last change
+		for ; end < len(edits) && end-last <= 2*context; end++ {
+			if edits[end].op != ' ' {
+				last = end
+			}
+		}
+		if end > last+1+context {
+			end = last + 1 + context
+		}
+		var n [2]int // lines of old and new in the hunk
+		var body strings.Builder
+		for _, e := range edits[start:end] {
+			if e.op != '+' {
+				n[0]++
+			}
+			if e.op != '-' {
+				n[1]++
+			}
+			fmt.Fprintf(&body, "%c%v\n", e.op, e.line)
+		}
+		fmt.Fprintf(&out, "@@ -%v,%v +%v,%v @@\n%v",
+			line[0]-(k-start), n[0], line[1]-(k-start), n[1], body.String())
+		for _, e := range edits[k:end] {
+			if e.op != '+' {
+				line[0]++
+			}
+			if e.op != '-' {
+				line[1]++
+			}
+		}
+		k = end
+	}
+	return out.String()
+}
+
+// edit is a line of a diff, op is ' ' for a line that is kept, '-' for one
+// that is removed and '+' for one that is added.
+type edit struct {
+	op   byte
+	line string
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// editor reads the lines typed into the REPL on a terminal. It knows the
+// usual keys of readline: the arrows, Home and End, Ctrl-A and Ctrl-E to go
+// to the start and the end of the line, Ctrl-K, Ctrl-U and Ctrl-W to delete,
+// Ctrl-P and Ctrl-N or Up and Down to go through the history and Ctrl-R to
+// search it. The history is kept in a file across sessions. Tab completes
+// the word before the cursor.
+type editor struct {
+	fd      uintptr // of the terminal
+	in      *bufio.Reader
+	out     io.Writer
+	history []string
+	file    string // keeps the history, empty if it is not kept
+
+	// complete returns the sorted words that start with prefix, nil
+	// leaves Tab alone.
+	complete func(prefix string) []string
+}
+
+// errInterrupt is returned by readLine when Ctrl-C discards the line.
+var errInterrupt = errors.New("interrupted")
+
+// maxHistory is the number of lines kept in the history.
+const maxHistory = 1000
+
+// newEditor returns an editor for the terminal of the process that reads
+// the keys from in, nil if stdin or stdout is not a terminal.
+func newEditor(in *bufio.Reader) *editor {
+	if !isTerminal(os.Stdin.Fd()) || !isTerminal(os.Stdout.Fd()) || os.Getenv("TERM") == "dumb" {
+		return nil
+	}
+	e := &editor{fd: os.Stdin.Fd(), in: in, out: os.Stdout}
+	if home, err := os.UserHomeDir(); err == nil {
+		e.file = filepath.Join(home, ".glox_history")
+		e.load()
+	}
+	return e
+}
+
+// load reads the history from its file and cuts the file down to the last
+// maxHistory lines when it grew past twice as many.
+func (e *editor) load() {
+	data, err := os.ReadFile(e.file)
+	if err != nil {
+		return
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) > 2*maxHistory {
+		lines = lines[len(lines)-maxHistory:]
+		os.WriteFile(e.file, []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+	} else if len(lines) > maxHistory {
+		lines = lines[len(lines)-maxHistory:]
+	}
+	e.history = lines
+}
+
+// add appends line to the history and its file, unless it is empty or the
+// same as the last one.
+func (e *editor) add(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if n := len(e.history); n > 0 && e.history[n-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+	if e.file == "" {
+		return
+	}
+	f, err := os.OpenFile(e.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(f, line)
+	f.Close()
+}
+
+func ctrl(r rune) rune {
+	return r & 0x1f
+}
+
+// readLine shows prompt and returns the line that is typed after it. It
+// returns io.EOF for Ctrl-D on an empty line and errInterrupt for Ctrl-C.
+func (e *editor) readLine(prompt string) (string, error) {
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return readPlain(e.in, prompt)
+	}
+	defer restore()
+
+	var buf []rune
+	pos := 0               // of the cursor in buf
+	hist := len(e.history) // the history entry in buf, len for a new line
+	var edited []rune      // the new line while going through the history
+	refresh := func() {
+		fmt.Fprintf(e.out, "\r%v%v\x1b[K", prompt, string(buf))
+		if n := len(buf) - pos; n > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", n)
+		}
+	}
+	recall := func(i int) {
+		if hist == len(e.history) {
+			edited = buf
+		}
+		hist = i
+		if hist == len(e.history) {
+			buf = edited
+		} else {
+			buf = []rune(e.history[hist])
+		}
+		pos = len(buf)
+	}
+	refresh()
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			line := string(buf)
+			e.add(line)
+			return line, nil
+		case ctrl('C'):
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupt
+		case ctrl('D'):
+			if len(buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(buf) {
+				buf = append(buf[:pos], buf[pos+1:]...)
+			}
+		case ctrl('A'):
+			pos = 0
+		case ctrl('E'):
+			pos = len(buf)
+		case ctrl('B'):
+			if pos > 0 {
+				pos--
+			}
+		case ctrl('F'):
+			if pos < len(buf) {
+				pos++
+			}
+		case 127, ctrl('H'):
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+			}
+		case ctrl('K'):
+			buf = buf[:pos]
+		case ctrl('U'):
+			buf = append([]rune(nil), buf[pos:]...)
+			pos = 0
+		case ctrl('W'):
+			start := wordStart(buf, pos)
+			buf = append(buf[:start], buf[pos:]...)
+			pos = start
+		case '\t':
+			if e.complete == nil {
+				break
+			}
+			start := wordStart(buf, pos)
+			if start == pos || !isWordRune(buf[pos-1]) {
+				break
+			}
+			words := e.complete(string(buf[start:pos]))
+			if len(words) == 0 {
+				break
+			}
+			word := []rune(commonPrefix(words))
+			if len(words) == 1 {
+				word = append(word, ' ')
+			}
+			if len(word) == pos-start && len(words) > 1 {
+				// nothing to add, show the choices under the line
+				fmt.Fprintf(e.out, "\r\n%v\r\n", strings.Join(words, "  "))
+				break
+			}
+			buf = append(buf[:start], append(word, buf[pos:]...)...)
+			pos = start + len(word)
+		case ctrl('L'):
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case ctrl('P'):
+			if hist > 0 {
+				recall(hist - 1)
+			}
+		case ctrl('N'):
+			if hist < len(e.history) {
+				recall(hist + 1)
+			}
+		case ctrl('R'):
+			line, run := e.search(buf)
+			if run {
+				fmt.Fprintf(e.out, "\r%v%v\x1b[K\r\n", prompt, line)
+				e.add(line)
+				return line, nil
+			}
+			buf, pos = []rune(line), len([]rune(line))
+		case 27: // escape sequences of the arrows and other keys
+			switch e.escape() {
+			case "[A", "OA":
+				if hist > 0 {
+					recall(hist - 1)
+				}
+			case "[B", "OB":
+				if hist < len(e.history) {
+					recall(hist + 1)
+				}
+			case "[C", "OC":
+				if pos < len(buf) {
+					pos++
+				}
+			case "[D", "OD":
+				if pos > 0 {
+					pos--
+				}
+			case "[H", "OH", "[1~", "[7~":
+				pos = 0
+			case "[F", "OF", "[4~", "[8~":
+				pos = len(buf)
+			case "[3~":
+				if pos < len(buf) {
+					buf = append(buf[:pos], buf[pos+1:]...)
+				}
+			case "b":
+				pos = wordStart(buf, pos)
+			case "f":
+				pos = wordEnd(buf, pos)
+			}
+		default:
+			if unicode.IsPrint(r) {
+				buf = append(buf[:pos], append([]rune{r}, buf[pos:]...)...)
+				pos++
+			}
+		}
+		refresh()
+	}
+}
+
+// escape reads the rest of an escape sequence after the ESC, e.g. "[A"
+// for Up or "[3~" for Delete, or the letter that was typed with Alt.
+func (e *editor) escape() string {
+	r, _, err := e.in.ReadRune()
+	if err != nil {
+		return ""
+	}
+	if r != '[' && r != 'O' {
+		return string(r)
+	}
+	seq := []rune{r}
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return string(seq)
+		}
+		seq = append(seq, r)
+		if r < '0' || r > '9' && r != ';' {
+			return string(seq)
+		}
+	}
+}
+
+// search lets the user search the history backwards for the lines that
+// contain what they type, Ctrl-R again goes on to older lines. It returns
+// the line found and if Enter was pressed to run it, or line if the search
+// was cancelled.
+func (e *editor) search(line []rune) (found string, run bool) {
+	var query []rune
+	i := len(e.history) // the entry found
+	match := string(line)
+	find := func(from int) {
+		for j := from; j >= 0; j-- {
+			if j < len(e.history) && strings.Contains(e.history[j], string(query)) {
+				i, match = j, e.history[j]
+				return
+			}
+		}
+	}
+	for {
+		fmt.Fprintf(e.out, "\r(reverse-i-search)`%v': %v\x1b[K", string(query), match)
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return string(line), false
+		}
+		switch {
+		case r == '\r' || r == '\n':
+			return match, true
+		case r == ctrl('C') || r == ctrl('G'):
+			return string(line), false
+		case r == ctrl('R'):
+			find(i - 1)
+		case r == 127 || r == ctrl('H'):
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				find(len(e.history) - 1)
+			}
+		case unicode.IsPrint(r):
+			query = append(query, r)
+			find(i)
+		default:
+			if r == 27 {
+				e.escape()
+			}
+			return match, false
+		}
+	}
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// wordStart returns the start of the word before pos.
+func wordStart(buf []rune, pos int) int {
+	for pos > 0 && !isWordRune(buf[pos-1]) {
+		pos--
+	}
+	for pos > 0 && isWordRune(buf[pos-1]) {
+		pos--
+	}
+	return pos
+}
+
+// wordEnd returns the end of the word after pos.
+func wordEnd(buf []rune, pos int) int {
+	for pos < len(buf) && !isWordRune(buf[pos]) {
+		pos++
+	}
+	for pos < len(buf) && isWordRune(buf[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// commonPrefix returns the longest prefix that all of words share.
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, w := range words[1:] {
+		for !strings.HasPrefix(w, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	return prefix
+}
+
+// readPlain shows prompt and reads a line from in, for input that is not a
+// terminal. A last line without a newline counts too.
+func readPlain(in *bufio.Reader, prompt string) (string, error) {
+	fmt.Print(prompt)
+	line, err := in.ReadString('\n')
+	if line == "" && err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// extractCmd implements `glox extract file.lox startLine endLine fnName`.
+// The statements on the selected lines are moved into a new top-level
+// function and replaced by a call to it. Local variables that the selection
+// uses but does not declare become the parameters of the function.
+func extractCmd(args []string) int {
+	fs := flag.NewFlagSet("extract", flag.ExitOnError)
+	write := fs.Bool("w", false, "write the result back to the file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, "usage: glox extract [-w] file.lox startLine endLine fnName\n")
+		fs.PrintDefaults()
+	}
+	pos := parseArgs(fs, args)
+	if len(pos) != 4 {
+		fs.Usage()
+		return 2
+	}
+	file, name := pos[0], pos[3]
+	start, err1 := strconv.Atoi(pos[1])
+	end, err2 := strconv.Atoi(pos[2])
+	if err1 != nil || err2 != nil || start < 1 || end < start {
+		fmt.Fprintf(os.Stderr, "invalid line range %v-%v\n", pos[1], pos[2])
+		return 2
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	out, err := lox.Extract(string(data), start, end, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: %v\n", file, err)
+		return 1
+	}
+	if *write {
+		if err := os.WriteFile(file, []byte(out), 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Print(out)
+	return 0
+}
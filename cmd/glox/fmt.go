@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// fmtCmd implements `glox fmt [-w | -check] file.lox...`, which reprints the
+// scripts in the canonical layout, see lox.Format. It prints them to stdout
+// unless -w writes them back to their files or -check prints how they would
+// change and fails if any would.
+func fmtCmd(args []string) int {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := fs.Bool("w", false, "write the result back to the files")
+	check := fs.Bool("check", false, "print a diff of the files that are not formatted and fail if there are any")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, "usage: glox fmt [-w | -check] file.lox...\n")
+		fs.PrintDefaults()
+	}
+	files := parseArgs(fs, args)
+	if len(files) == 0 || *write && *check {
+		fs.Usage()
+		return 2
+	}
+	code := 0
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+			continue
+		}
+		out, err := lox.Format(string(data))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", file, err)
+			code = 1
+			continue
+		}
+		switch {
+		case *check:
+			if out != string(data) {
+				fmt.Print(diff(file, string(data), out))
+				code = 1
+			}
+		case *write:
+			if out == string(data) {
+				continue
+			}
+			if err := os.WriteFile(file, []byte(out), 0o644); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				code = 1
+			}
+		default:
+			fmt.Print(out)
+		}
+	}
+	return code
+}
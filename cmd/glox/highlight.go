@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// highlightCmd implements `glox highlight [-json] file.lox`, which prints
+// the class of every token for editors that highlight the script, one per
+// line with its range: keyword, function, parameter, variable, string,
+// number, comment or operator. The identifiers that declare a name are
+// marked as declarations and the natives as the default library.
+func highlightCmd(args []string) int {
+	fs := flag.NewFlagSet("highlight", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the tokens as JSON lines")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, "usage: glox highlight [-json] file.lox\n")
+		fs.PrintDefaults()
+	}
+	files := parseArgs(fs, args)
+	if len(files) != 1 {
+		fs.Usage()
+		return 2
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	list, err := lox.Highlights(string(data))
+	enc := json.NewEncoder(os.Stdout)
+	for _, h := range list {
+		endLine, endCol := tokenEnd(h.Token)
+		if *asJSON {
+			enc.Encode(map[string]interface{}{
+				"line":      h.Token.Line(),
+				"col":       h.Token.Col(),
+				"endLine":   endLine,
+				"endCol":    endCol,
+				"class":     h.Class.String(),
+				"modifiers": modifiers(h),
+			})
+			continue
+		}
+		line := fmt.Sprintf("%v:%v-%v:%v\t%v", h.Token.Line(), h.Token.Col(), endLine, endCol, h.Class)
+		if mods := modifiers(h); len(mods) > 0 {
+			line += "\t" + strings.Join(mods, ",")
+		}
+		fmt.Println(line)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// highlightModifiers are the modifiers of the classes of the tokens, in
+// the order of the bits that the LSP sends them as.
+var highlightModifiers = []string{"declaration", "defaultLibrary"}
+
+func modifiers(h lox.Highlight) []string {
+	mods := []string{}
+	if h.Decl {
+		mods = append(mods, highlightModifiers[0])
+	}
+	if h.Native {
+		mods = append(mods, highlightModifiers[1])
+	}
+	return mods
+}
+
+// tokenEnd returns the line and the byte column just past the end of t.
+func tokenEnd(t *lox.Token) (line, col int) {
+	lexeme := t.Lexeme()
+	if i := strings.LastIndexByte(lexeme, '\n'); i >= 0 {
+		return t.Line() + strings.Count(lexeme, "\n"), len(lexeme) - i
+	}
+	return t.Line(), t.Col() + len(lexeme)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// lintCmd implements `glox lint [-config file] [-disable rules] file.lox...`,
+// which reports the code that is likely a mistake, see lox.Lint. The rules
+// are all on unless the config file or -disable turns them off.
+func lintCmd(args []string) int {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	opts := lox.LintOptions{Disabled: make(map[string]bool)}
+	fs.Func("config", "read the settings of the rules from `file`, see -rules", func(file string) error {
+		return readLintConfig(file, &opts)
+	})
+	fs.Func("disable", "comma-separated `rules` that are not checked", func(s string) error {
+		for _, r := range strings.Split(s, ",") {
+			if err := setRule(&opts, strings.TrimSpace(r), "off"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	fs.IntVar(&opts.MaxParams, "max-params", 5, "number of parameters that long-params allows")
+	rules := fs.Bool("rules", false, "list the rules and the form of the config file")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, "usage: glox lint [-config file] [-disable rules] [-max-params n] file.lox...\n")
+		fs.PrintDefaults()
+	}
+	files := parseArgs(fs, args)
+	if *rules {
+		for _, r := range lox.LintRules {
+			fmt.Printf("%-20v %v\n", r.Name, r.Doc)
+		}
+		fmt.Print("\nA config file has a setting on every line, # starts a comment:\n\n" +
+			"\tshadow = off\n\tlong-params = 7\n\n" +
+			"A rule is on or off, long-params may be set to the number of parameters it allows.\n")
+		return 0
+	}
+	if len(files) == 0 {
+		fs.Usage()
+		return 2
+	}
+	code := 0
+	for _, file := range files {
+		_, stmts, ok := loadFile(file)
+		if !ok {
+			code = 1
+			continue
+		}
+		for _, f := range lox.Lint(stmts, opts) {
+			fmt.Printf("%v:%v:%v: %v\n", file, f.At.Line(), f.At.Col(), f.Msg)
+			code = 1
+		}
+	}
+	return code
+}
+
+// readLintConfig reads the settings of the rules in file into opts.
+func readLintConfig(file string, opts *lox.LintOptions) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		rule, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("%v:%v: want rule = value", file, n)
+		}
+		if err := setRule(opts, strings.TrimSpace(rule), strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("%v:%v: %v", file, n, err)
+		}
+	}
+	return sc.Err()
+}
+
+// setRule turns rule on or off, or sets the number of parameters for
+// long-params.
+func setRule(opts *lox.LintOptions, rule, value string) error {
+	known := false
+	for _, r := range lox.LintRules {
+		known = known || r.Name == rule
+	}
+	if !known {
+		return fmt.Errorf("unknown rule %q", rule)
+	}
+	switch value {
+	case "on":
+		delete(opts.Disabled, rule)
+		return nil
+	case "off":
+		opts.Disabled[rule] = true
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if rule != "long-params" || err != nil || n < 0 {
+		return fmt.Errorf("rule %v is on or off, not %q", rule, value)
+	}
+	opts.MaxParams = n
+	delete(opts.Disabled, rule)
+	return nil
+}
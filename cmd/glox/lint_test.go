@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+func TestReadLintConfig(t *testing.T) {
+	tests := []struct {
+		config string
+		want   lox.LintOptions
+		err    string
+	}{
+		{"# settings\nshadow = off\n\nempty-block=off # noisy\nlong-params = 7\n",
+			lox.LintOptions{Disabled: map[string]bool{"shadow": true, "empty-block": true}, MaxParams: 7}, ""},
+		{"shadow = off\nshadow = on\n", lox.LintOptions{Disabled: map[string]bool{}}, ""},
+		{"shadow\n", lox.LintOptions{}, ":1: want rule = value"},
+		{"\nunknown = off\n", lox.LintOptions{}, `:2: unknown rule "unknown"`},
+		{"shadow = 3\n", lox.LintOptions{}, `:1: rule shadow is on or off, not "3"`},
+		{"long-params = -1\n", lox.LintOptions{}, `:1: rule long-params is on or off, not "-1"`},
+	}
+	for _, tt := range tests {
+		file := filepath.Join(t.TempDir(), "lint.conf")
+		if err := os.WriteFile(file, []byte(tt.config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		opts := lox.LintOptions{Disabled: make(map[string]bool)}
+		err := readLintConfig(file, &opts)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%q: got %v, want the error %q", tt.config, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.config, err)
+		} else if !reflect.DeepEqual(opts, tt.want) {
+			t.Errorf("%q: got %+v, want %+v", tt.config, opts, tt.want)
+		}
+	}
+}
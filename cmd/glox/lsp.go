@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// lspCmd implements `glox lsp`, a server of the Language Server Protocol
+// on stdin and stdout for the editors. It checks the scripts as they are
+// edited and reports the syntax errors and the undefined names, finds the
+// declaration of a name, tells what a name is on hover, lists the
+// functions and the global variables of a script and classifies its tokens
+// for highlighting.
+func lspCmd(args []string) int {
+	flags := flag.NewFlagSet("lsp", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, "usage: glox lsp\n")
+		flags.PrintDefaults()
+	}
+	if len(parseArgs(flags, args)) > 0 {
+		flags.Usage()
+		return 2
+	}
+	s := &lspServer{out: os.Stdout, docs: make(map[string]*lspDoc)}
+	code, err := s.serve(bufio.NewReader(os.Stdin))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return code
+}
+
+// lspServer answers the requests of an editor about the scripts it has
+// open, it does not look at the files.
+type lspServer struct {
+	out      io.Writer
+	docs     map[string]*lspDoc // by URI
+	shutdown bool
+}
+
+// lspDoc is a script open in the editor as it was last checked.
+type lspDoc struct {
+	text   string
+	lines  []string
+	table  *lox.SymbolTable // nil if the script does not scan
+	errors []lspDiagnostic
+}
+
+type lspMessage struct {
+	ID     *json.RawMessage `json:"id,omitempty"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+}
+
+// lspPosition is 0-based, the characters are counted in UTF-16 code
+// units as the protocol says.
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+// The severities of the diagnostics and the kinds of the symbols.
+const (
+	severityError   = 1
+	severityWarning = 2
+
+	symbolFunction = 12
+	symbolVariable = 13
+)
+
+type lspTextDocument struct {
+	TextDocument struct {
+		URI  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"textDocument"`
+	Position       lspPosition `json:"position"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+// serve answers the messages until the editor tells the server to exit and
+// returns the exit code.
+func (s *lspServer) serve(r *bufio.Reader) (int, error) {
+	for {
+		body, err := readFrame(r)
+		if err == io.EOF {
+			return 1, nil
+		}
+		if err != nil {
+			return 1, fmt.Errorf("lsp: %v", err)
+		}
+		var msg lspMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return 1, fmt.Errorf("lsp: %v", err)
+		}
+		if msg.Method == "exit" {
+			if s.shutdown {
+				return 0, nil
+			}
+			return 1, nil
+		}
+		var params lspTextDocument
+		json.Unmarshal(msg.Params, &params)
+		result, fail := s.handle(msg.Method, &params)
+		if msg.ID == nil {
+			continue
+		}
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID}
+		if fail != nil {
+			resp["error"] = map[string]interface{}{"code": fail.code, "message": fail.msg}
+		} else {
+			resp["result"] = result
+		}
+		writeFrame(s.out, resp)
+	}
+}
+
+// lspError is the error of a request that failed.
+type lspError struct {
+	code int
+	msg  string
+}
+
+// handle answers a request or takes a notification, whose result is
+// dropped.
+func (s *lspServer) handle(method string, p *lspTextDocument) (interface{}, *lspError) {
+	uri := p.TextDocument.URI
+	switch method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":       1, // the whole text on every change
+				"definitionProvider":     true,
+				"hoverProvider":          true,
+				"documentSymbolProvider": true,
+				"semanticTokensProvider": map[string]interface{}{
+					"legend": map[string]interface{}{
+						"tokenTypes":     lox.TokenClasses(),
+						"tokenModifiers": highlightModifiers,
+					},
+					"full": true,
+				},
+			},
+			"serverInfo": map[string]interface{}{"name": "glox"},
+		}, nil
+	case "initialized":
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/didOpen":
+		s.update(uri, p.TextDocument.Text)
+	case "textDocument/didChange":
+		if n := len(p.ContentChanges); n > 0 {
+			s.update(uri, p.ContentChanges[n-1].Text)
+		}
+	case "textDocument/didClose":
+		delete(s.docs, uri)
+		s.publish(uri, nil)
+	case "textDocument/definition":
+		doc, sym := s.symbolAt(uri, p.Position)
+		if sym == nil || sym.Decl() == nil {
+			return nil, nil
+		}
+		return lspLocation{uri, doc.tokenRange(sym.Decl())}, nil
+	case "textDocument/hover":
+		doc, sym := s.symbolAt(uri, p.Position)
+		if sym == nil {
+			return nil, nil
+		}
+		return map[string]interface{}{
+			"contents": map[string]interface{}{"kind": "markdown", "value": doc.describe(sym)},
+		}, nil
+	case "textDocument/documentSymbol":
+		doc := s.docs[uri]
+		if doc == nil {
+			return nil, &lspError{-32602, "the document is not open"}
+		}
+		return doc.symbols(uri), nil
+	case "textDocument/semanticTokens/full":
+		doc := s.docs[uri]
+		if doc == nil {
+			return nil, &lspError{-32602, "the document is not open"}
+		}
+		return map[string]interface{}{"data": doc.semanticTokens()}, nil
+	default:
+		if strings.HasPrefix(method, "$/") {
+			return nil, nil
+		}
+		return nil, &lspError{-32601, "method not found: " + method}
+	}
+	return nil, nil
+}
+
+// update checks the new text of the document and publishes what it finds.
+func (s *lspServer) update(uri, text string) {
+	doc := &lspDoc{text: text, lines: strings.Split(text, "\n")}
+	s.docs[uri] = doc
+	sc := lox.NewScanner(text)
+	tokens, err := sc.Scan()
+	if err != nil {
+		line, col := sc.ErrorAt()
+		at := doc.position(line, col)
+		doc.errors = append(doc.errors, lspDiagnostic{lspRange{at, at}, severityError, "glox", message(err)})
+		s.publish(uri, doc.errors)
+		return
+	}
+	p := lox.NewParser(tokens)
+	stmts, errs := p.Parse()
+	for i, err := range errs {
+		doc.errors = append(doc.errors, lspDiagnostic{
+			doc.tokenRange(p.ErrorTokens()[i]), severityError, "glox", message(err),
+		})
+	}
+	// what could be parsed is resolved still, like glox check does
+	doc.table = lox.Resolve(stmts)
+	for _, sym := range doc.table.Symbols() {
+		if sym.Kind() != lox.UndefinedSymbol {
+			continue
+		}
+		for _, t := range sym.Refs() {
+			doc.errors = append(doc.errors, lspDiagnostic{
+				doc.tokenRange(t), severityWarning, "glox",
+				fmt.Sprintf("undefined variable '%v'", sym.Name()),
+			})
+		}
+	}
+	s.publish(uri, doc.errors)
+}
+
+// message returns the text of a syntax error without the line, which the
+// editor shows by itself.
+func message(err error) string {
+	msg := err.Error()
+	if strings.HasPrefix(msg, "[line ") {
+		if _, rest, ok := strings.Cut(msg, "] "); ok {
+			msg = rest
+		}
+	}
+	return strings.TrimPrefix(msg, "error: ")
+}
+
+func (s *lspServer) publish(uri string, diags []lspDiagnostic) {
+	if diags == nil {
+		diags = []lspDiagnostic{}
+	}
+	writeFrame(s.out, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  "textDocument/publishDiagnostics",
+		"params":  map[string]interface{}{"uri": uri, "diagnostics": diags},
+	})
+}
+
+// symbolAt returns the document and the symbol at the position in it.
+func (s *lspServer) symbolAt(uri string, at lspPosition) (*lspDoc, *lox.Symbol) {
+	doc := s.docs[uri]
+	if doc == nil || doc.table == nil || at.Line >= len(doc.lines) {
+		return nil, nil
+	}
+	line := at.Line + 1
+	return doc, doc.table.SymbolAt(line, byteCol(doc.lines[at.Line], at.Character))
+}
+
+// describe tells what sym is in markdown, with the line that declares it.
+func (doc *lspDoc) describe(sym *lox.Symbol) string {
+	scope := "local "
+	if sym.Global() {
+		scope = "global "
+	}
+	if sym.Kind() == lox.NativeSymbol || sym.Kind() == lox.UndefinedSymbol {
+		scope = ""
+	}
+	text := fmt.Sprintf("%v%v `%v`", scope, sym.Kind(), sym.Name())
+	if d := sym.Decl(); d != nil && d.Line() <= len(doc.lines) {
+		decl := strings.TrimSpace(doc.lines[d.Line()-1])
+		text += fmt.Sprintf(", declared at line %v\n\n```lox\n%v\n```", d.Line(), decl)
+	}
+	return text
+}
+
+// symbols returns the functions and the global variables of the document.
+func (doc *lspDoc) symbols(uri string) []map[string]interface{} {
+	result := []map[string]interface{}{}
+	if doc.table == nil {
+		return result
+	}
+	for _, sym := range doc.table.Symbols() {
+		kind := symbolVariable
+		switch {
+		case sym.Decl() == nil, sym.Kind() == lox.ParamSymbol:
+			continue
+		case sym.Kind() == lox.FunSymbol:
+			kind = symbolFunction
+		case !sym.Global():
+			continue
+		}
+		result = append(result, map[string]interface{}{
+			"name":     sym.Name(),
+			"kind":     kind,
+			"location": lspLocation{uri, doc.tokenRange(sym.Decl())},
+		})
+	}
+	return result
+}
+
+// semanticTokens returns the classes of the tokens encoded as the protocol
+// wants them: five numbers for each token, its line and start relative to
+// the token before, its length, class and modifiers. The tokens that span
+// lines are split into one for each line.
+func (doc *lspDoc) semanticTokens() []int {
+	list, _ := lox.Highlights(doc.text)
+	data := []int{}
+	var prev lspPosition
+	add := func(start, end lspPosition, h lox.Highlight) {
+		if end.Character <= start.Character {
+			return
+		}
+		char := start.Character
+		if start.Line == prev.Line {
+			char -= prev.Character
+		}
+		mods := 0 // the bits of highlightModifiers
+		if h.Decl {
+			mods |= 1
+		}
+		if h.Native {
+			mods |= 2
+		}
+		data = append(data, start.Line-prev.Line, char, end.Character-start.Character, int(h.Class), mods)
+		prev = start
+	}
+	for _, h := range list {
+		t := h.Token
+		endLine, endCol := tokenEnd(t)
+		start := doc.position(t.Line(), t.Col())
+		for line := t.Line(); line < endLine; line++ {
+			add(start, doc.position(line, len(doc.lines[line-1])+1), h)
+			start = lspPosition{Line: line}
+		}
+		add(start, doc.position(endLine, endCol), h)
+	}
+	return data
+}
+
+// position converts a 1-based line and byte column to a position.
+func (doc *lspDoc) position(line, col int) lspPosition {
+	if line < 1 || line > len(doc.lines) {
+		return lspPosition{Line: line - 1}
+	}
+	text := doc.lines[line-1]
+	if col-1 > len(text) {
+		col = len(text) + 1
+	}
+	return lspPosition{line - 1, utf16Len(text[:col-1])}
+}
+
+// tokenRange returns the range of t, up to the end of its first line for
+// the tokens that span more.
+func (doc *lspDoc) tokenRange(t *lox.Token) lspRange {
+	start := doc.position(t.Line(), t.Col())
+	lexeme, _, _ := strings.Cut(t.Lexeme(), "\n")
+	return lspRange{start, doc.position(t.Line(), t.Col()+len(lexeme))}
+}
+
+// utf16Len returns the length of s in UTF-16 code units.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// byteCol returns the 1-based byte column of the UTF-16 offset char in
+// line.
+func byteCol(line string, char int) int {
+	n := 0
+	for i, r := range line {
+		if n >= char {
+			return i + 1
+		}
+		n += utf16Len(string(r))
+	}
+	return len(line) + 1
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// lspReply is a response or a notification of the server.
+type lspReply struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// lspSession serves the messages, a request if it has an id, and returns
+// the exit code and the responses and notifications of the server.
+func lspSession(t *testing.T, msgs ...map[string]interface{}) (int, []lspReply) {
+	t.Helper()
+	var in, out bytes.Buffer
+	for _, msg := range msgs {
+		msg["jsonrpc"] = "2.0"
+		writeFrame(&in, msg)
+	}
+	s := &lspServer{out: &out, docs: make(map[string]*lspDoc)}
+	code, err := s.serve(bufio.NewReader(&in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var replies []lspReply
+	r := bufio.NewReader(&out)
+	for {
+		body, err := readFrame(r)
+		if err != nil {
+			break
+		}
+		var reply lspReply
+		if err := json.Unmarshal(body, &reply); err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, reply)
+	}
+	return code, replies
+}
+
+func lspRequest(id int, method string, params interface{}) map[string]interface{} {
+	return map[string]interface{}{"id": id, "method": method, "params": params}
+}
+
+func lspNotify(method string, params interface{}) map[string]interface{} {
+	return map[string]interface{}{"method": method, "params": params}
+}
+
+// lspAt is the params of a request about the position in the document.
+func lspAt(uri string, line, char int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"position":     map[string]int{"line": line, "character": char},
+	}
+}
+
+// The emoji takes two UTF-16 code units and four bytes, the positions are
+// counted in the former.
+const lspScript = `var greeting = "😀"; var copy = greeting;
+fun greet(name) {
+  return greeting + name;
+}
+print greet(who);
+`
+
+// TestLSPSession opens a script and asks about it like an editor.
+func TestLSPSession(t *testing.T) {
+	const uri = "file:///script.lox"
+	code, replies := lspSession(t,
+		lspRequest(1, "initialize", map[string]interface{}{}),
+		lspNotify("initialized", map[string]interface{}{}),
+		lspNotify("textDocument/didOpen", map[string]interface{}{
+			"textDocument": map[string]string{"uri": uri, "languageId": "lox", "text": lspScript},
+		}),
+		lspRequest(2, "textDocument/definition", lspAt(uri, 0, 32)),
+		lspRequest(3, "textDocument/hover", lspAt(uri, 2, 20)),
+		lspRequest(4, "textDocument/documentSymbol", lspAt(uri, 0, 0)),
+		lspRequest(5, "textDocument/definition", lspAt(uri, 4, 0)),
+		lspRequest(6, "textDocument/hover", lspAt(uri, 4, 12)),
+		lspRequest(7, "textDocument/rename", lspAt(uri, 0, 4)),
+		lspNotify("$/cancelRequest", map[string]int{"id": 1}),
+		lspNotify("textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]string{"uri": uri},
+			"contentChanges": []map[string]string{{"text": "var a = 1;\nprint a +;\n"}},
+		}),
+		lspRequest(8, "textDocument/documentSymbol", lspAt("file:///other.lox", 0, 0)),
+		lspNotify("textDocument/didClose", map[string]interface{}{"textDocument": map[string]string{"uri": uri}}),
+		lspRequest(9, "shutdown", nil),
+		lspNotify("exit", nil),
+	)
+	if code != 0 {
+		t.Errorf("exited with %v after shutdown", code)
+	}
+
+	var got []string
+	for _, r := range replies {
+		switch {
+		case r.Error != nil:
+			got = append(got, fmt.Sprintf("%v: error %v %v", *r.ID, r.Error.Code, r.Error.Message))
+		case r.ID != nil:
+			got = append(got, fmt.Sprintf("%v: %s", *r.ID, r.Result))
+		default:
+			got = append(got, fmt.Sprintf("%v: %s", r.Method, r.Params))
+		}
+	}
+	// the capabilities are long, they are checked on their own
+	var caps struct {
+		Capabilities map[string]interface{}
+	}
+	if len(got) > 0 {
+		json.Unmarshal(replies[0].Result, &caps)
+		got[0] = "1: initialized"
+	}
+	for _, c := range []string{"definitionProvider", "hoverProvider", "documentSymbolProvider", "semanticTokensProvider"} {
+		if caps.Capabilities[c] == nil {
+			t.Errorf("%v is missing from the capabilities", c)
+		}
+	}
+
+	want := []string{
+		"1: initialized",
+		`textDocument/publishDiagnostics: {"diagnostics":[{"range":{"start":{"line":4,"character":12},"end":{"line":4,"character":15}},"severity":2,"source":"glox","message":"undefined variable 'who'"}],"uri":"file:///script.lox"}`,
+		`2: {"uri":"file:///script.lox","range":{"start":{"line":0,"character":4},"end":{"line":0,"character":12}}}`,
+		"3: {\"contents\":{\"kind\":\"markdown\",\"value\":\"local parameter `name`, declared at line 2\\n\\n```lox\\nfun greet(name) {\\n```\"}}",
+		`4: [{"kind":13,"location":{"uri":"file:///script.lox","range":{"start":{"line":0,"character":4},"end":{"line":0,"character":12}}},"name":"greeting"},` +
+			`{"kind":13,"location":{"uri":"file:///script.lox","range":{"start":{"line":0,"character":25},"end":{"line":0,"character":29}}},"name":"copy"},` +
+			`{"kind":12,"location":{"uri":"file:///script.lox","range":{"start":{"line":1,"character":4},"end":{"line":1,"character":9}}},"name":"greet"}]`,
+		"5: null",
+		"6: {\"contents\":{\"kind\":\"markdown\",\"value\":\"undefined `who`\"}}",
+		"7: error -32601 method not found: textDocument/rename",
+		`textDocument/publishDiagnostics: {"diagnostics":[{"range":{"start":{"line":1,"character":9},"end":{"line":1,"character":10}},"severity":1,"source":"glox","message":"error at ';': expected expression"}],"uri":"file:///script.lox"}`,
+		"8: error -32602 the document is not open",
+		`textDocument/publishDiagnostics: {"diagnostics":[],"uri":"file:///script.lox"}`,
+		"9: null",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%v\nwant\n%v", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// TestLSPExit checks the exit codes: 0 after shutdown, else 1.
+func TestLSPExit(t *testing.T) {
+	if code, _ := lspSession(t, lspNotify("exit", nil)); code != 1 {
+		t.Errorf("exit without shutdown: got %v, want 1", code)
+	}
+	if code, _ := lspSession(t, lspRequest(1, "initialize", nil)); code != 1 {
+		t.Errorf("the end of the input: got %v, want 1", code)
+	}
+}
+
+// TestLSPSemanticTokens checks the encoding of the tokens: relative
+// positions in UTF-16 code units, a string that spans lines split in two.
+func TestLSPSemanticTokens(t *testing.T) {
+	doc := &lspDoc{text: "var s = \"😀\";\nprint \"a\nb\";"}
+	doc.lines = strings.Split(doc.text, "\n")
+	data := doc.semanticTokens()
+	if len(data)%5 != 0 {
+		t.Fatalf("%v numbers", len(data))
+	}
+	var got []string
+	for i := 0; i < len(data); i += 5 {
+		got = append(got, fmt.Sprint(data[i:i+3]))
+	}
+	// var s = "😀" print "a / b", the semicolons are not classified
+	want := "[0 0 3] [0 4 1] [0 2 1] [0 2 4] [1 0 5] [0 6 2] [1 0 2]"
+	if strings.Join(got, " ") != want {
+		t.Errorf("the positions and lengths are\n%v\nwant\n%v", strings.Join(got, " "), want)
+	}
+}
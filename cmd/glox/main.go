@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+var hadError = false
+
+// interp runs the scripts and the lines typed into the REPL.
+var interp = lox.New()
+
+// allowed and denied are the capabilities of -allow and -deny, a capability
+// in both is denied.
+var allowed, denied []lox.Capability
+
+// disasm prints the bytecode of programs instead of running them.
+var disasm = false
+
+// tokens, if set, prints the tokens of the script instead of running it,
+// as text or as JSON.
+var tokens tokensFlag
+
+// printAST, if set, is the form in which the AST of the script is printed
+// instead of running it: sexpr or tree.
+var printAST = ""
+
+// commands are the tools that glox provides besides running scripts, each
+// gets the arguments that follow its name and returns the exit code.
+var commands = map[string]func(args []string) int{
+	"query":     queryCmd,
+	"rename":    renameCmd,
+	"extract":   extractCmd,
+	"deadcode":  deadcodeCmd,
+	"audit":     auditCmd,
+	"ast":       astCmd,
+	"run":       runCmd,
+	"check":     checkCmd,
+	"fmt":       fmtCmd,
+	"lint":      lintCmd,
+	"test":      testCmd,
+	"dap":       dapCmd,
+	"lsp":       lspCmd,
+	"highlight": highlightCmd,
+	"build":     buildCmd,
+}
+
+func main() {
+	// Do not leave the scratch directory behind when interrupted.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		lox.Cleanup()
+		os.Exit(130)
+	}()
+
+	flag.BoolVar(&interp.Optimize, "O", interp.Optimize,
+		"fold constant expressions and branches before running")
+	flag.BoolVar(&interp.IEEEDivision, "ieee-division", interp.IEEEDivision,
+		"let division by zero produce Inf or NaN instead of an error")
+	flag.IntVar(&interp.MaxCallDepth, "max-call-depth", interp.MaxCallDepth,
+		"maximum depth of nested function calls")
+	flag.IntVar(&interp.MaxSteps, "max-steps", interp.MaxSteps,
+		"stop scripts after this many loop iterations and calls, 0 means no limit")
+	flag.IntVar(&interp.MaxAlloc, "max-alloc", interp.MaxAlloc,
+		"stop scripts that allocate more than this many bytes, 0 means no limit")
+	flag.Func("allow", "comma-separated `capabilities` that scripts may use besides the default ones: file, exec, network",
+		func(s string) error {
+			allowed = append(allowed, parseCapabilities(s)...)
+			return nil
+		})
+	flag.Func("deny", "comma-separated `capabilities` that scripts may not use, e.g. tempfile,stdin,tasks",
+		func(s string) error {
+			denied = append(denied, parseCapabilities(s)...)
+			return nil
+		})
+	flag.IntVar(&interp.TraceDepth, "trace-depth", interp.TraceDepth,
+		"number of innermost and outermost frames in stack traces, 0 shows all")
+	flag.StringVar(&interp.Backend, "backend", interp.Backend,
+		"how to run programs: tree (walk the AST) or vm (bytecode)")
+	flag.BoolVar(&disasm, "disasm", disasm,
+		"print the bytecode instead of running, implies -backend=vm")
+	traceFlag := flag.Bool("trace", false,
+		"print every statement to stderr with its line as it runs")
+	flag.StringVar(&printAST, "print-ast", printAST,
+		"print the AST of the script instead of running it, as a `form` of sexpr or tree")
+	flag.Var(&tokens, "tokens",
+		"print the tokens of the script instead of running it, -tokens=json prints them as JSON lines")
+	flag.BoolVar(&profileReport, "profile", profileReport,
+		"print the calls of every function and the time spent in it to stderr")
+	flag.StringVar(&pprofFile, "pprof", pprofFile,
+		"write a profile of the calls for go tool pprof to `file`")
+	flag.BoolVar(&coverReport, "coverage", coverReport,
+		"print the share of the lines of the scripts that ran and the lines that did not")
+	flag.StringVar(&coverOut, "cover-out", coverOut,
+		"write the coverage of the lines to `file`, in HTML if it ends with .html and lcov otherwise")
+	eval := flag.String("e", "",
+		"run the `program` given instead of a script")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, "usage: glox [flags] [script | - [args...]]\n"+
+			"       glox [flags] -e program [args...]\n"+
+			"       glox [flags] run [-watch] script... [-- args...]\n"+
+			"       glox query file.lox --symbol-at line:col\n"+
+			"       glox rename [-w] file.lox line:col newName\n"+
+			"       glox extract [-w] file.lox startLine endLine fnName\n"+
+			"       glox deadcode file.lox\n"+
+			"       glox audit file.lox\n"+
+			"       glox ast [-indent] file.lox\n"+
+			"       glox check file.lox...\n"+
+			"       glox fmt [-w | -check] file.lox...\n"+
+			"       glox lint [-config file] [-disable rules] file.lox...\n"+
+			"       glox test [-v] [-timeout d] [dir | file]...\n"+
+			"       glox dap\n"+
+			"       glox lsp\n"+
+			"       glox highlight [-json] file.lox\n"+
+			"       glox build [-target go|js] [-o out] script.lox\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if interp.Backend != "tree" && interp.Backend != "vm" {
+		fmt.Fprintf(os.Stderr, "unknown backend %q\n", interp.Backend)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if printAST != "" && printAST != "sexpr" && printAST != "tree" {
+		fmt.Fprintf(os.Stderr, "unknown form of the AST %q\n", printAST)
+		flag.Usage()
+		os.Exit(2)
+	}
+	interp.Allow(allowed...)
+	interp.Deny(denied...)
+	if disasm {
+		interp.Disasm = os.Stdout
+	}
+	var hooks []*lox.Hooks
+	if *traceFlag {
+		trace = &tracer{w: os.Stderr}
+		hooks = append(hooks, trace.hooks())
+	}
+	if profileReport || pprofFile != "" {
+		prof = newProfiler()
+		hooks = append(hooks, prof.hooks())
+	}
+	if coverReport || coverOut != "" {
+		cover = newCoverage()
+		hooks = append(hooks, cover.hooks())
+	}
+	interp.Hooks = combineHooks(hooks...)
+
+	args := flag.Args()
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			os.Exit(cmd(args[1:]))
+		}
+	}
+	if *eval != "" {
+		interp.Args = args
+		runSource("-e", *eval)
+	} else if len(args) > 0 {
+		interp.Args = args[1:]
+		runFile(args[0])
+	} else {
+		runPrompt()
+	}
+}
+
+// parseCapabilities returns the capabilities in the comma-separated list s.
+func parseCapabilities(s string) []lox.Capability {
+	var caps []lox.Capability
+	for _, c := range strings.Split(s, ",") {
+		caps = append(caps, lox.Capability(strings.TrimSpace(c)))
+	}
+	return caps
+}
+
+// runFile runs the script in file, or the one read from stdin if file is
+// "-".
+func runFile(file string) {
+	src, err := readScript(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	runSource(file, src)
+}
+
+// readScript returns the script in file, or the one on stdin if file is
+// "-".
+func readScript(file string) (string, error) {
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	return string(data), err
+}
+
+// runSource runs the program in src, read from file, and exits with 1 if
+// it fails.
+func runSource(file, src string) {
+	if tokens != "" {
+		os.Exit(printTokens(src))
+	}
+	if printAST != "" {
+		os.Exit(printTree(src))
+	}
+	starting(file, src)
+	report(interp.Run(src))
+	finishProfile()
+	finishCoverage()
+	interp.Cleanup()
+	if hadError {
+		os.Exit(1)
+	}
+}
+
+func runPrompt() {
+	// The scripts read from the same buffer with readLine().
+	stdin := bufio.NewReader(os.Stdin)
+	interp.Stdin = stdin
+	// The lines of a statement that is not complete yet, like an open
+	// block, are collected and run together.
+	var src string
+	sess := newSession()
+	read := func(prompt string) (string, error) {
+		return readPlain(stdin, prompt)
+	}
+	if ed := newEditor(stdin); ed != nil {
+		ed.complete = complete
+		read = ed.readLine
+	}
+	for {
+		prompt := "> "
+		if src != "" {
+			prompt = "... "
+		}
+		line, err := read(prompt)
+		if err == errInterrupt {
+			src = ""
+			continue
+		}
+		if err != nil {
+			if src != "" {
+				fmt.Println()
+				report(interp.RunLine(strings.TrimSuffix(src, "\n")))
+			}
+			break
+		}
+		if src == "" && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			if sess.command(line) {
+				break
+			}
+			continue
+		}
+		src += line
+		starting("", src)
+		v, err := evalInput(src)
+		if errors.Is(err, lox.ErrIncomplete) {
+			src += "\n"
+			continue
+		}
+		report(err)
+		if err == nil {
+			sess.record(src)
+		}
+		if !v.IsNil() {
+			fmt.Println(v.Quoted())
+		}
+		src = ""
+		hadError = false
+	}
+	finishProfile()
+	finishCoverage()
+	interp.Cleanup()
+}
+
+// starting tells the tracer and the coverage that the following runs come
+// from src, read from file. The lines typed into the REPL have no file.
+func starting(file, src string) {
+	if trace != nil {
+		trace.source(src)
+	}
+	if cover != nil {
+		cover.source(file, src)
+	}
+}
+
+// printTree prints the AST of src in the form of printAST and returns the
+// exit code.
+func printTree(src string) int {
+	tokens, err := lox.NewScanner(src).Scan()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	stmts, errs := lox.NewParser(tokens).Parse()
+	if len(errs) > 0 {
+		for _, e := range errs {
+			fmt.Fprintln(os.Stderr, e)
+		}
+		return 1
+	}
+	if printAST == "tree" {
+		lox.FprintTree(os.Stdout, stmts)
+		return 0
+	}
+	for _, s := range stmts {
+		fmt.Println(lox.SprintAST(s))
+	}
+	return 0
+}
+
+// report prints the error of a run to the stderr of the interpreter.
+func report(err error) {
+	if err != nil {
+		fmt.Fprintln(interp.Stderr, err)
+		hadError = true
+	}
+}
+
+// loadFile reads and parses the script in file for the tools, reporting the
+// errors to stderr. The returned statements are whatever could be parsed.
+func loadFile(file string) (tokens []*lox.Token, stmts []lox.Stmt, ok bool) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, false
+	}
+	tokens, err = lox.NewScanner(string(data)).Scan()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, false
+	}
+	stmts, errs := lox.NewParser(tokens).Parse()
+	for _, e := range errs {
+		fmt.Fprintln(os.Stderr, e)
+	}
+	return tokens, stmts, len(errs) == 0
+}
+
+// parseArgs parses the flags in args, also the ones that follow positional
+// arguments, and returns the positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var pos []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return pos
+		}
+		pos = append(pos, args[0])
+		args = args[1:]
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// profiler counts the calls of every function and the time spent in it,
+// for -profile and -pprof. The functions are told apart by their names,
+// anonymous ones are all "(anonymous)". With tasks running the calls of
+// all of them make one stack, so the times are only a rough guide.
+type profiler struct {
+	start  time.Time
+	stack  []profFrame
+	funcs  map[string]*profFunc
+	stacks map[string]*profStack // the self time of every stack of calls
+}
+
+type profFrame struct {
+	name  string
+	start time.Time
+	child time.Duration // spent in the calls it made
+}
+
+type profFunc struct {
+	name      string
+	calls     int
+	self, cum time.Duration
+	active    int // frames on the stack, cum is counted for the outermost
+}
+
+type profStack struct {
+	names []string // the innermost first
+	calls int
+	self  time.Duration
+}
+
+// prof is set by -profile or -pprof.
+var prof *profiler
+
+// The flags of the profiler: -profile prints the report to stderr, -pprof
+// names the file for the pprof profile.
+var (
+	profileReport = false
+	pprofFile     = ""
+)
+
+// finishProfile reports the profile of the runs if there is one.
+func finishProfile() {
+	if prof == nil {
+		return
+	}
+	prof.finish()
+	if profileReport {
+		prof.report(os.Stderr)
+	}
+	if pprofFile != "" {
+		if err := prof.writePprof(pprofFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
+
+func newProfiler() *profiler {
+	return &profiler{
+		start:  time.Now(),
+		funcs:  make(map[string]*profFunc),
+		stacks: make(map[string]*profStack),
+	}
+}
+
+func (p *profiler) hooks() *lox.Hooks {
+	return &lox.Hooks{
+		OnCall: func(name string, _ []lox.Value) {
+			if name == "" {
+				name = "(anonymous)"
+			}
+			f := p.funcs[name]
+			if f == nil {
+				f = &profFunc{name: name}
+				p.funcs[name] = f
+			}
+			f.calls++
+			f.active++
+			p.stack = append(p.stack, profFrame{name: name, start: time.Now()})
+		},
+		OnReturn: func(lox.Value) {
+			p.pop(time.Now())
+		},
+	}
+}
+
+// pop ends the innermost call at now.
+func (p *profiler) pop(now time.Time) {
+	if len(p.stack) == 0 {
+		return
+	}
+	fr := p.stack[len(p.stack)-1]
+	p.stack = p.stack[:len(p.stack)-1]
+	total := now.Sub(fr.start)
+	self := total - fr.child
+	f := p.funcs[fr.name]
+	f.self += self
+	if f.active--; f.active == 0 {
+		f.cum += total
+	}
+	if n := len(p.stack); n > 0 {
+		p.stack[n-1].child += total
+	}
+
+	names := []string{fr.name}
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		names = append(names, p.stack[i].name)
+	}
+	key := strings.Join(names, "\x00")
+	s := p.stacks[key]
+	if s == nil {
+		s = &profStack{names: names}
+		p.stacks[key] = s
+	}
+	s.calls++
+	s.self += self
+}
+
+// finish ends the calls that did not return, like those of a program that
+// failed.
+func (p *profiler) finish() {
+	now := time.Now()
+	for len(p.stack) > 0 {
+		p.pop(now)
+	}
+}
+
+// report prints the functions by the time spent in them, the longest
+// first.
+func (p *profiler) report(w io.Writer) {
+	total := time.Since(p.start)
+	funcs := make([]*profFunc, 0, len(p.funcs))
+	for _, f := range p.funcs {
+		funcs = append(funcs, f)
+	}
+	sort.Slice(funcs, func(i, j int) bool {
+		if funcs[i].self != funcs[j].self {
+			return funcs[i].self > funcs[j].self
+		}
+		return funcs[i].name < funcs[j].name
+	})
+	fmt.Fprintf(w, "total %v\n%10v %12v %7v %12v %7v  %v\n",
+		total.Round(time.Microsecond), "calls", "self", "self%", "cum", "cum%", "function")
+	percent := func(d time.Duration) string {
+		if total <= 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f%%", 100*float64(d)/float64(total))
+	}
+	for _, f := range funcs {
+		fmt.Fprintf(w, "%10v %12v %7v %12v %7v  %v\n", f.calls,
+			f.self.Round(time.Microsecond), percent(f.self),
+			f.cum.Round(time.Microsecond), percent(f.cum), f.name)
+	}
+}
+
+// writePprof writes the profile to file in the format of pprof, with the
+// calls and the self time of every stack as the samples.
+func (p *profiler) writePprof(file string) error {
+	var b protoBuf
+	strs := map[string]int{"": 0}
+	table := []string{""}
+	str := func(s string) int {
+		if i, ok := strs[s]; ok {
+			return i
+		}
+		strs[s] = len(table)
+		table = append(table, s)
+		return len(table) - 1
+	}
+	valueType := func(typ, unit string) []byte {
+		var v protoBuf
+		v.varint(1, uint64(str(typ)))
+		v.varint(2, uint64(str(unit)))
+		return v.Bytes()
+	}
+	b.bytes(1, valueType("calls", "count"))
+	b.bytes(1, valueType("time", "nanoseconds"))
+
+	// a function and a location of the same id for every name
+	ids := make(map[string]uint64)
+	names := make([]string, 0, len(p.funcs))
+	for name := range p.funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		ids[name] = uint64(i + 1)
+	}
+
+	keys := make([]string, 0, len(p.stacks))
+	for k := range p.stacks {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := p.stacks[k]
+		var sample, locs, values protoBuf
+		for _, name := range s.names {
+			locs.uvarint(ids[name])
+		}
+		values.uvarint(uint64(s.calls))
+		values.uvarint(uint64(s.self))
+		sample.bytes(1, locs.Bytes())
+		sample.bytes(2, values.Bytes())
+		b.bytes(2, sample.Bytes())
+	}
+	for _, name := range names {
+		var loc, line protoBuf
+		line.varint(1, ids[name])
+		loc.varint(1, ids[name])
+		loc.bytes(4, line.Bytes())
+		b.bytes(4, loc.Bytes())
+	}
+	for _, name := range names {
+		var fn protoBuf
+		fn.varint(1, ids[name])
+		fn.varint(2, uint64(str(name)))
+		fn.varint(3, uint64(str(name)))
+		b.bytes(5, fn.Bytes())
+	}
+	b.varint(9, uint64(p.start.UnixNano()))
+	b.varint(10, uint64(time.Since(p.start)))
+	for _, s := range table {
+		b.bytes(6, []byte(s))
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(f)
+	_, err = zw.Write(b.Bytes())
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// protoBuf encodes the fields of a protocol buffer message.
+type protoBuf struct {
+	bytes.Buffer
+}
+
+// uvarint writes x as a varint without a key, for the packed repeated
+// fields.
+func (b *protoBuf) uvarint(x uint64) {
+	for x >= 0x80 {
+		b.WriteByte(byte(x) | 0x80)
+		x >>= 7
+	}
+	b.WriteByte(byte(x))
+}
+
+// varint writes the varint field n.
+func (b *protoBuf) varint(n int, x uint64) {
+	b.uvarint(uint64(n) << 3)
+	b.uvarint(x)
+}
+
+// bytes writes the length-delimited field n.
+func (b *protoBuf) bytes(n int, data []byte) {
+	b.uvarint(uint64(n)<<3 | 2)
+	b.uvarint(uint64(len(data)))
+	b.Write(data)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+const profileScript = `fun fib(n) {
+  if (n < 2) return n;
+  return fib(n - 1) + fib(n - 2);
+}
+fib(4);
+var f = fun() {};
+f();
+f();
+`
+
+// profile runs profileScript with a profiler on backend.
+func profile(t *testing.T, backend string) *profiler {
+	t.Helper()
+	p := newProfiler()
+	in := lox.New(lox.WithBackend(backend), lox.WithStdout(io.Discard))
+	in.Hooks = p.hooks()
+	if err := in.Run(profileScript); err != nil {
+		t.Fatal(err)
+	}
+	p.finish()
+	return p
+}
+
+// TestProfileReport checks the calls in the report, the times vary.
+func TestProfileReport(t *testing.T) {
+	for _, backend := range []string{"tree", "vm"} {
+		var b strings.Builder
+		profile(t, backend).report(&b)
+		var got []string
+		for _, line := range strings.Split(b.String(), "\n")[2:] {
+			if f := strings.Fields(line); len(f) > 0 {
+				got = append(got, f[len(f)-1]+" "+f[0])
+			}
+		}
+		sort.Strings(got)
+		if want := "(anonymous) 2, fib 9"; strings.Join(got, ", ") != want {
+			t.Errorf("%v backend: the calls are %v, want %v\n%v", backend, strings.Join(got, ", "), want, b.String())
+		}
+	}
+}
+
+// TestWritePprof decodes the profile and checks the calls of every stack.
+func TestWritePprof(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "prof.pb.gz")
+	if err := profile(t, "tree").writePprof(file); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var strs []string
+	var sampleTypes, samples [][]byte
+	funcs := make(map[uint64]uint64) // id to the index of the name
+	locs := make(map[uint64]uint64)  // id to the id of the function
+	for _, fl := range protoFields(t, data) {
+		switch fl.n {
+		case 1:
+			sampleTypes = append(sampleTypes, fl.data)
+		case 2:
+			samples = append(samples, fl.data)
+		case 4:
+			var id, fn uint64
+			for _, lf := range protoFields(t, fl.data) {
+				switch lf.n {
+				case 1:
+					id = lf.x
+				case 4:
+					fn = protoFields(t, lf.data)[0].x
+				}
+			}
+			locs[id] = fn
+		case 5:
+			fs := protoFields(t, fl.data)
+			funcs[fs[0].x] = fs[1].x
+		case 6:
+			strs = append(strs, string(fl.data))
+		}
+	}
+	var types []string
+	for _, st := range sampleTypes {
+		fs := protoFields(t, st)
+		types = append(types, strs[fs[0].x]+"/"+strs[fs[1].x])
+	}
+	if got, want := strings.Join(types, " "), "calls/count time/nanoseconds"; got != want {
+		t.Errorf("the sample types are %v, want %v", got, want)
+	}
+	var got []string
+	for _, s := range samples {
+		fs := protoFields(t, s)
+		var names []string
+		for _, id := range protoVarints(t, fs[0].data) {
+			names = append(names, strs[funcs[locs[id]]])
+		}
+		got = append(got, fmt.Sprintf("%v: %v", strings.Join(names, " < "), protoVarints(t, fs[1].data)[0]))
+	}
+	// fib(4) calls fib(3) and fib(2), and so on down to fib(1) and fib(0)
+	want := "(anonymous): 2\nfib: 1\nfib < fib: 2\nfib < fib < fib: 4\nfib < fib < fib < fib: 2"
+	if strings.Join(got, "\n") != want {
+		t.Errorf("the samples are\n%v\nwant\n%v", strings.Join(got, "\n"), want)
+	}
+}
+
+// protoField is a field of a protocol buffer message, x is the value of a
+// varint and data that of a length-delimited field.
+type protoField struct {
+	n    int
+	x    uint64
+	data []byte
+}
+
+func protoFields(t *testing.T, b []byte) []protoField {
+	t.Helper()
+	var fields []protoField
+	for len(b) > 0 {
+		key := protoVarint(t, &b)
+		f := protoField{n: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			f.x = protoVarint(t, &b)
+		case 2:
+			n := protoVarint(t, &b)
+			if n > uint64(len(b)) {
+				t.Fatalf("field %v of %v bytes is cut short", f.n, n)
+			}
+			f.data, b = b[:n], b[n:]
+		default:
+			t.Fatalf("field %v has the wire type %v", f.n, key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// protoVarints decodes a packed repeated field.
+func protoVarints(t *testing.T, b []byte) []uint64 {
+	t.Helper()
+	var xs []uint64
+	for len(b) > 0 {
+		xs = append(xs, protoVarint(t, &b))
+	}
+	return xs
+}
+
+func protoVarint(t *testing.T, b *[]byte) uint64 {
+	t.Helper()
+	var x uint64
+	for shift := 0; ; shift += 7 {
+		if len(*b) == 0 || shift > 63 {
+			t.Fatal("bad varint")
+		}
+		c := (*b)[0]
+		*b = (*b)[1:]
+		x |= uint64(c&0x7f) << shift
+		if c < 0x80 {
+			return x
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// queryCmd implements `glox query file.lox --symbol-at line:col`, which
+// describes the symbol at the position: what kind of name it is, where it
+// is declared and everywhere it is referenced.
+func queryCmd(args []string) int {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	at := fs.String("symbol-at", "", "describe the symbol at `line:col`")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, "usage: glox query file.lox --symbol-at line:col\n")
+		fs.PrintDefaults()
+	}
+	files := parseArgs(fs, args)
+	if len(files) != 1 || *at == "" {
+		fs.Usage()
+		return 2
+	}
+	line, col, err := parsePosition(*at)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	_, stmts, ok := loadFile(files[0])
+	if !ok {
+		return 1
+	}
+	sym := lox.Resolve(stmts).SymbolAt(line, col)
+	if sym == nil {
+		fmt.Fprintf(os.Stderr, "no symbol at %v\n", *at)
+		return 1
+	}
+
+	scope := "local"
+	if sym.Global() {
+		scope = "global"
+	}
+	decl := "none"
+	if sym.Kind() == lox.NativeSymbol {
+		decl = "builtin"
+	} else if d := sym.Decl(); d != nil {
+		decl = fmt.Sprintf("%v:%v", d.Line(), d.Col())
+	}
+	refs := make([]string, 0, len(sym.Refs()))
+	for _, t := range sym.Refs() {
+		refs = append(refs, fmt.Sprintf("%v:%v", t.Line(), t.Col()))
+	}
+	fmt.Printf("name: %v\n", sym.Name())
+	fmt.Printf("kind: %v\n", sym.Kind())
+	fmt.Printf("scope: %v\n", scope)
+	fmt.Printf("declared: %v\n", decl)
+	fmt.Printf("references: %v\n", strings.Join(refs, " "))
+	return 0
+}
+
+// parsePosition parses a "line:col" pair.
+func parsePosition(s string) (line, col int, err error) {
+	l, c, ok := strings.Cut(s, ":")
+	if ok {
+		line, err = strconv.Atoi(l)
+		if err == nil {
+			col, err = strconv.Atoi(c)
+		}
+	}
+	if !ok || err != nil || line < 1 || col < 1 {
+		return 0, 0, fmt.Errorf("invalid position %q, expected line:col", s)
+	}
+	return line, col, nil
+}
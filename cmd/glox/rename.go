@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// renameCmd implements `glox rename file.lox line:col newName`. It renames
+// the symbol at the position, its declaration and all the references bound
+// to it, while other names that happen to be spelled the same stay intact.
+func renameCmd(args []string) int {
+	fs := flag.NewFlagSet("rename", flag.ExitOnError)
+	write := fs.Bool("w", false, "write the result back to the file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, "usage: glox rename [-w] file.lox line:col newName\n")
+		fs.PrintDefaults()
+	}
+	pos := parseArgs(fs, args)
+	if len(pos) != 3 {
+		fs.Usage()
+		return 2
+	}
+	file, at, name := pos[0], pos[1], pos[2]
+	line, col, err := parsePosition(at)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	out, err := lox.Rename(string(data), line, col, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: %v\n", file, err)
+		return 1
+	}
+	if *write {
+		if err := os.WriteFile(file, []byte(out), 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Print(out)
+	return 0
+}
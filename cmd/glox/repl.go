@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// session is the state that the REPL keeps besides the interpreter: the
+// globals to go back to on :reset and the inputs that ran so far.
+type session struct {
+	start      *lox.Snapshot
+	transcript []string
+}
+
+func newSession() *session {
+	return &session{start: interp.SnapshotGlobals()}
+}
+
+// record adds src, which ran without errors, to the transcript.
+func (s *session) record(src string) {
+	s.transcript = append(s.transcript, src)
+}
+
+// complete returns the globals and keywords that start with prefix for Tab
+// in the editor.
+func complete(prefix string) []string {
+	var words []string
+	for _, w := range append(interp.Globals(), lox.Keywords()...) {
+		if strings.HasPrefix(w, prefix) {
+			words = append(words, w)
+		}
+	}
+	sort.Strings(words)
+	return words
+}
+
+// replCommand is a command of the REPL, a line that starts with a colon
+// runs one of them instead of going to the scanner.
+type replCommand struct {
+	name, args, help string
+	run              func(s *session, arg string) (quit bool)
+}
+
+var replCommands []replCommand
+
+func init() {
+	// set here as :help lists them
+	replCommands = []replCommand{
+		{"help", "", "show this help", (*session).help},
+		{"env", "", "list the globals with their types and values", (*session).env},
+		{"load", "file", "run the script in file in this session", (*session).load},
+		{"save", "file", "save what ran in this session to file", (*session).save},
+		{"reset", "", "forget the globals defined in this session", (*session).reset},
+		{"quit", "", "leave the REPL", func(*session, string) bool { return true }},
+	}
+}
+
+// command runs the REPL command in line, telling if the REPL should quit.
+func (s *session) command(line string) (quit bool) {
+	name, arg := strings.TrimPrefix(strings.TrimSpace(line), ":"), ""
+	if i := strings.IndexAny(name, " \t"); i >= 0 {
+		name, arg = name[:i], strings.TrimSpace(name[i+1:])
+	}
+	for _, c := range replCommands {
+		if c.name != name {
+			continue
+		}
+		if c.args != "" && arg == "" {
+			fmt.Printf("usage: :%v %v\n", c.name, c.args)
+			return false
+		}
+		return c.run(s, arg)
+	}
+	fmt.Printf("unknown command :%v, :help lists the commands\n", name)
+	return false
+}
+
+func (s *session) help(string) bool {
+	for _, c := range replCommands {
+		usage := ":" + c.name
+		if c.args != "" {
+			usage += " " + c.args
+		}
+		fmt.Printf("  %-12v %v\n", usage, c.help)
+	}
+	return false
+}
+
+// env lists the globals, leaving out the natives.
+func (s *session) env(string) bool {
+	for _, name := range interp.Globals() {
+		v, _ := interp.Global(name)
+		if v.Type() == "native" {
+			continue
+		}
+		fmt.Printf("  %v: %v = %v\n", name, v.Type(), v.Quoted())
+	}
+	return false
+}
+
+// evalInput runs src, an input that is complete as typed, and keeps the
+// value of the expression that it ends with in _. That value is returned
+// for the REPL to show, it is nil if src does not end with an expression.
+func evalInput(src string) (lox.Value, error) {
+	v, ok, err := interp.EvalLine(src)
+	if err != nil || !ok || v.IsNil() {
+		return lox.Value{}, err
+	}
+	interp.Define("_", v)
+	return v, nil
+}
+
+// sessionHeader starts the transcripts that :save writes, which :load
+// replays input by input rather than running them as a script.
+const sessionHeader = "// glox session"
+
+// load runs the script in file like RunFile would. A session saved with
+// :save is replayed instead.
+func (s *session) load(file string) bool {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintln(interp.Stderr, err)
+		return false
+	}
+	text := string(data)
+	if strings.HasPrefix(text, sessionHeader+"\n") {
+		s.replay(strings.TrimPrefix(text, sessionHeader+"\n"))
+		return false
+	}
+	starting(file, text)
+	if err := interp.Run(text); err != nil {
+		fmt.Fprintln(interp.Stderr, err)
+		return false
+	}
+	s.record(":load " + file)
+	return false
+}
+
+// replay runs the inputs of a saved session like their lines typed in, so
+// the semicolons at the ends of lines may be left out and _ holds the value
+// of the last expression. The line numbers in errors are those within the
+// input, as in the REPL.
+func (s *session) replay(text string) {
+	var src string
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		if src == "" && strings.HasPrefix(line, ":") {
+			s.command(line)
+			continue
+		}
+		src += line
+		starting("", src)
+		_, err := evalInput(src)
+		if errors.Is(err, lox.ErrIncomplete) {
+			src += "\n"
+			continue
+		}
+		if err != nil {
+			fmt.Fprintln(interp.Stderr, err)
+			return
+		}
+		s.record(src)
+		src = ""
+	}
+	if src != "" {
+		// the session ends in the middle of an input
+		if err := interp.RunLine(src); err != nil {
+			fmt.Fprintln(interp.Stderr, err)
+		}
+	}
+}
+
+// save writes the inputs that ran without errors, so that :load can replay
+// them.
+func (s *session) save(file string) bool {
+	var b strings.Builder
+	fmt.Fprintln(&b, sessionHeader)
+	for _, src := range s.transcript {
+		fmt.Fprintln(&b, src)
+	}
+	if err := os.WriteFile(file, []byte(b.String()), 0o644); err != nil {
+		fmt.Fprintln(interp.Stderr, err)
+	}
+	return false
+}
+
+func (s *session) reset(string) bool {
+	interp.RestoreGlobals(s.start)
+	s.transcript = nil
+	return false
+}
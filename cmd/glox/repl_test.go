@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// TestSaveLoad saves a session whose inputs use _ and loads it into a new
+// one, which must end up with the same output and globals.
+func TestSaveLoad(t *testing.T) {
+	defer func(in *lox.Interpreter) { interp = in }(interp)
+	var out bytes.Buffer
+	interp = lox.New(lox.WithStdout(&out))
+	sess := newSession()
+	inputs := []string{
+		"1 + 2",
+		"var a = _ * 10",
+		"fun f() {\n\treturn a;\n}",
+		"f()",
+		"print _ + 1",
+		`"last"`,
+	}
+	for _, src := range inputs {
+		if _, err := evalInput(src); err != nil {
+			t.Fatalf("%q: %v", src, err)
+		}
+		sess.record(src)
+	}
+	file := filepath.Join(t.TempDir(), "session.lox")
+	sess.save(file)
+	want := out.String()
+
+	out.Reset()
+	interp = lox.New(lox.WithStdout(&out))
+	sess = newSession()
+	sess.load(file)
+	if got := out.String(); got != want {
+		t.Errorf("loading printed %q, want %q", got, want)
+	}
+	for name, want := range map[string]string{"a": "30", "_": `"last"`} {
+		v, ok := interp.Global(name)
+		if !ok {
+			t.Errorf("%v is not defined", name)
+		} else if v.Quoted() != want {
+			t.Errorf("%v = %v, want %v", name, v.Quoted(), want)
+		}
+	}
+	// and the loaded session saves the same transcript again
+	again := filepath.Join(t.TempDir(), "again.lox")
+	sess.save(again)
+	if a, b := readFile(t, file), readFile(t, again); a != b {
+		t.Errorf("saved again:\n%v\nwant:\n%v", b, a)
+	}
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// TestLoadScript loads a script whose statements span lines, which must run
+// as a whole like RunFile runs it, and be saved as the :load that ran it.
+func TestLoadScript(t *testing.T) {
+	defer func(in *lox.Interpreter) { interp = in }(interp)
+	var out bytes.Buffer
+	interp = lox.New(lox.WithStdout(&out))
+	sess := newSession()
+	script := filepath.Join(t.TempDir(), "script.lox")
+	src := "if (false)\n\tprint \"then\";\nelse\n\tprint \"else\";\nprint 1\n+ 2;\n"
+	if err := os.WriteFile(script, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sess.load(script)
+	if got, want := out.String(), "else\n3\n"; got != want {
+		t.Errorf("loading printed %q, want %q", got, want)
+	}
+
+	saved := filepath.Join(t.TempDir(), "session.lox")
+	sess.save(saved)
+	out.Reset()
+	interp = lox.New(lox.WithStdout(&out))
+	newSession().load(saved)
+	if got, want := out.String(), "else\n3\n"; got != want {
+		t.Errorf("loading the saved session printed %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// runCmd implements `glox run [-watch] [-time] a.lox b.lox -- args...`. It
+// runs the scripts one after another in the same interpreter, so the later
+// ones see the globals of the earlier ones, and passes the arguments after
+// "--" to them. It stops at the first script that fails.
+func runCmd(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	watchFlag := fs.Bool("watch", false, "run the scripts again whenever one of them changes")
+	timeFlag := fs.Bool("time", false, "print the time spent in each phase and the memory allocated to stderr")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, "usage: glox run [-watch] [-time] script... [-- args...]\n")
+		fs.PrintDefaults()
+	}
+	var scriptArgs []string
+	for i, a := range args {
+		if a == "--" {
+			args, scriptArgs = args[:i], args[i+1:]
+			break
+		}
+	}
+	scripts := parseArgs(fs, args)
+	if len(scripts) == 0 {
+		fs.Usage()
+		return 2
+	}
+	interp.Args = scriptArgs
+	defer interp.Cleanup()
+	defer finishProfile()
+	defer finishCoverage()
+	if *timeFlag {
+		interp.Timings = &lox.Timings{}
+		defer printTimings(interp.Timings, readAllocs())
+	}
+	if *watchFlag {
+		watch(scripts)
+	}
+	if !runScripts(context.Background(), scripts) {
+		return 1
+	}
+	return 0
+}
+
+// readAllocs returns the bytes and objects allocated by glox so far.
+func readAllocs() [2]uint64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return [2]uint64{m.TotalAlloc, m.Mallocs}
+}
+
+// printTimings prints the times in t and what was allocated since before.
+func printTimings(t *lox.Timings, before [2]uint64) {
+	after := readAllocs()
+	phases := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"scan", t.Scan},
+		{"parse", t.Parse},
+		{"optimize", t.Optimize},
+		{"resolve", t.Resolve},
+		{"compile", t.Compile},
+		{"run", t.Run},
+		{"total", t.Total()},
+	}
+	for _, p := range phases {
+		fmt.Fprintf(os.Stderr, "%-10v %12v\n", p.name, p.d)
+	}
+	fmt.Fprintf(os.Stderr, "%-10v %12v in %v objects\n", "allocated",
+		fmt.Sprintf("%.1f MB", float64(after[0]-before[0])/1e6), after[1]-before[1])
+}
+
+// runScripts runs the scripts until one fails or ctx is done and tells if
+// all of them ran.
+func runScripts(ctx context.Context, scripts []string) bool {
+	for _, file := range scripts {
+		src, err := readScript(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return false
+		}
+		starting(file, src)
+		err = runIn(ctx, interp, src)
+		if ctx.Err() != nil {
+			return false
+		}
+		if report(err); hadError {
+			return false
+		}
+	}
+	return true
+}
+
+// runIn runs the program in src with in until it is done or ctx is.
+func runIn(ctx context.Context, in *lox.Interpreter, src string) error {
+	stmts, err := in.Parse(src)
+	if err != nil {
+		return err
+	}
+	return in.RunContext(ctx, stmts)
+}
+
+// newInterp returns an interpreter of its own with the settings of the
+// flags given to glox, changed by opts.
+func newInterp(opts ...lox.Option) *lox.Interpreter {
+	in := lox.New(append([]lox.Option{
+		lox.WithBackend(interp.Backend),
+		lox.WithMaxCallDepth(interp.MaxCallDepth),
+		lox.WithMaxSteps(interp.MaxSteps),
+		lox.WithMaxAlloc(interp.MaxAlloc),
+		lox.WithTraceDepth(interp.TraceDepth),
+		lox.AllowCapabilities(allowed...),
+		lox.DenyCapabilities(denied...),
+	}, opts...)...)
+	in.Optimize, in.IEEEDivision = interp.Optimize, interp.IEEEDivision
+	return in
+}
+
+// watch runs the scripts, then again with the globals they started with
+// every time one of them changes, until glox is interrupted. A run that is
+// still going when a script changes is stopped.
+func watch(scripts []string) {
+	start := interp.SnapshotGlobals()
+	for {
+		stamps := fileStamps(scripts)
+		fmt.Print("\x1b[H\x1b[2J")
+		ctx, cancel := context.WithCancel(context.Background())
+		changed := make(chan struct{})
+		go func() {
+			for fileStamps(scripts) == stamps {
+				time.Sleep(200 * time.Millisecond)
+			}
+			cancel()
+			close(changed)
+		}()
+		began := time.Now()
+		if runScripts(ctx, scripts) || ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "[ran in %v, watching %v]\n",
+				time.Since(began).Round(time.Microsecond), strings.Join(scripts, " "))
+		}
+		<-changed
+		interp.RestoreGlobals(start)
+		hadError = false
+	}
+}
+
+// fileStamps sums up the sizes and modification times of the files, so
+// that a change to any of them changes the result.
+func fileStamps(files []string) string {
+	var b strings.Builder
+	for _, f := range files {
+		if fi, err := os.Stat(f); err == nil {
+			fmt.Fprintf(&b, "%v %v;", fi.Size(), fi.ModTime().UnixNano())
+		} else {
+			b.WriteString("-;")
+		}
+	}
+	return b.String()
+}
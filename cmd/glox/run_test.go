@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// TestReportStderr checks that the errors of the runs, syntax and runtime,
+// go to the stderr of the interpreter and the output stays on its stdout.
+func TestReportStderr(t *testing.T) {
+	defer func(in *lox.Interpreter, failed bool) { interp, hadError = in, failed }(interp, hadError)
+	tests := []struct {
+		src, out, errs string
+	}{
+		{"print 1;\nprint nil + 1;", "1\n", "[line 2] runtime error: operands of '+' must be two numbers or two strings, got nil and number\n"},
+		{"print 1;\nprint +;", "", "[line 2] error at '+': expected expression\n"},
+	}
+	for _, tt := range tests {
+		var out, errs bytes.Buffer
+		interp = lox.New(lox.WithStdout(&out), lox.WithStderr(&errs))
+		hadError = false
+		file := filepath.Join(t.TempDir(), "script.lox")
+		if err := os.WriteFile(file, []byte(tt.src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if runScripts(context.Background(), []string{file}) {
+			t.Errorf("%q ran without an error", tt.src)
+		}
+		if out.String() != tt.out {
+			t.Errorf("%q printed %q, want %q", tt.src, out.String(), tt.out)
+		}
+		if errs.String() != tt.errs {
+			t.Errorf("%q reported %q, want %q", tt.src, errs.String(), tt.errs)
+		}
+	}
+}
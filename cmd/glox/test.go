@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// testCmd implements `glox test [dir | file]...`, which runs the tests among
+// the scripts in the directories, . if none are given, and checks what they
+// print against what they expect. A test is a script named *_test.lox or
+// one with comments that say what to expect at the end of its lines:
+//
+//	print 1 + 2;  // expect: 3
+//	print x;      // expect runtime error: undefined variable 'x'
+//	print (;      // expect error at ';': expected expression
+//
+// An "expect:" comment is a line of output. The others are errors, which
+// are expected as "[line N] " and the text after "expect " with the line
+// of the comment. A test without comments passes if it runs without error.
+// Each test runs in an interpreter of its own, with the flags given to glox.
+func testCmd(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	verbose := flags.Bool("v", false, "list the tests that pass too")
+	timeout := flags.Duration("timeout", 10*time.Second, "fail a test that runs longer")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, "usage: glox test [-v] [-timeout d] [dir | file]...\n")
+		flags.PrintDefaults()
+	}
+	paths := parseArgs(flags, args)
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	var tests []string
+	for _, p := range paths {
+		found, err := findTests(p)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		tests = append(tests, found...)
+	}
+	if len(tests) == 0 {
+		fmt.Println("no tests found")
+		return 1
+	}
+
+	failed := 0
+	start := time.Now()
+	for _, file := range tests {
+		began := time.Now()
+		err := runTest(file, *timeout)
+		took := time.Since(began).Round(time.Millisecond)
+		if err != nil {
+			fmt.Printf("FAIL %v (%v)\n%v", file, took, err)
+			failed++
+		} else if *verbose {
+			fmt.Printf("ok   %v (%v)\n", file, took)
+		}
+	}
+	fmt.Printf("%v passed, %v failed in %v\n", len(tests)-failed, failed,
+		time.Since(start).Round(time.Millisecond))
+	finishCoverage()
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// findTests returns the tests in path, a test itself or a directory with
+// tests in it at any depth, in lexical order.
+func findTests(path string) ([]string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return []string{path}, nil
+	}
+	var tests []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if strings.HasSuffix(p, "_test.lox") {
+			tests = append(tests, p)
+			return nil
+		}
+		if ext := filepath.Ext(p); ext != ".lox" && ext != ".glx" {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if bytes.Contains(data, []byte("// expect")) {
+			tests = append(tests, p)
+		}
+		return nil
+	})
+	return tests, err
+}
+
+// runTest runs the test in file and returns an error with a diff of the
+// expected and the actual output if it fails.
+func runTest(file string, timeout time.Duration) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	src := string(data)
+
+	var out bytes.Buffer
+	in := newInterp(
+		lox.WithStdout(&out),
+		lox.WithStderr(&out),
+		lox.WithStdin(strings.NewReader("")),
+		lox.WithArgs(file),
+	)
+	if cover != nil {
+		cover.source(file, src)
+		in.Hooks = cover.hooks()
+	}
+	defer in.Cleanup()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err = runIn(ctx, in, src)
+	if err != nil {
+		// the first line of every error, not the stack trace
+		for _, line := range strings.Split(err.Error(), "\n") {
+			if !strings.HasPrefix(line, " ") {
+				fmt.Fprintln(in.Stderr, line)
+			}
+		}
+	}
+
+	want := expectations(src)
+	if want == nil {
+		if err != nil {
+			return errors.New(indent(out.String()))
+		}
+		return nil
+	}
+	var expected strings.Builder
+	for _, w := range want {
+		fmt.Fprintln(&expected, w)
+	}
+	if got := out.String(); got != expected.String() {
+		return errors.New(indent(diff(file, expected.String(), got)))
+	}
+	return nil
+}
+
+// expectations returns the output that the comments of src expect, nil if
+// there are none.
+func expectations(src string) []string {
+	var want []string
+	for i, line := range strings.Split(src, "\n") {
+		_, exp, ok := strings.Cut(line, "// expect")
+		if !ok {
+			continue
+		}
+		exp = strings.TrimRight(exp, "\r")
+		if strings.HasPrefix(exp, ": ") {
+			want = append(want, exp[2:])
+		} else if strings.HasPrefix(exp, " ") {
+			want = append(want, fmt.Sprintf("[line %v]%v", i+1, exp))
+		}
+	}
+	return want
+}
+
+// indent indents the lines of s by a tab.
+func indent(s string) string {
+	return "\t" + strings.ReplaceAll(strings.TrimSuffix(s, "\n"), "\n", "\n\t") + "\n"
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// TestRunTest runs the tests of the repository with both backends.
+func TestRunTest(t *testing.T) {
+	defer func(in *lox.Interpreter) { interp = in }(interp)
+	tests, err := findTests(filepath.Join("..", "..", "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tests) == 0 {
+		t.Fatal("no tests found")
+	}
+	for _, backend := range []string{"tree", "vm"} {
+		interp = lox.New(lox.WithBackend(backend))
+		for _, file := range tests {
+			if err := runTest(file, 10*time.Second); err != nil {
+				t.Errorf("%v with %v:\n%v", file, backend, err)
+			}
+		}
+	}
+}
+
+// TestRunTestFails checks that a test fails with a diff when it prints
+// something other than it expects.
+func TestRunTestFails(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "wrong.lox")
+	src := "print 1; // expect: 1\nprint 2; // expect: 3\n"
+	if err := os.WriteFile(file, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := runTest(file, 10*time.Second)
+	if err == nil {
+		t.Fatal("the test passed")
+	}
+	for _, want := range []string{"-3", "+2"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("the diff has no %q:\n%v", want, err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// tokensFlag is the form of -tokens: "text" for the flag alone or "json".
+type tokensFlag string
+
+func (f *tokensFlag) String() string   { return string(*f) }
+func (f *tokensFlag) IsBoolFlag() bool { return true }
+
+func (f *tokensFlag) Set(s string) error {
+	switch s {
+	case "true", "text":
+		*f = "text"
+	case "false":
+		*f = ""
+	case "json":
+		*f = "json"
+	default:
+		return fmt.Errorf("want text or json")
+	}
+	return nil
+}
+
+// printTokens prints the tokens of src, one per line, in the form of the
+// tokens flag and returns the exit code. The text form has the line and
+// column, the type, the lexeme and the literal of numbers and strings.
+func printTokens(src string) int {
+	list, err := lox.NewScanner(src).Scan()
+	enc := json.NewEncoder(os.Stdout)
+	for _, t := range list {
+		if tokens == "json" {
+			enc.Encode(t)
+			continue
+		}
+		line := fmt.Sprintf("%v:%v\t%v", t.Line(), t.Col(), t.Type())
+		if t.Type() != lox.EOF {
+			line += "\t" + t.Lexeme()
+		}
+		switch lit := t.Literal().(type) {
+		case string:
+			line += "\t" + strconv.Quote(lit)
+		case float64:
+			line += "\t" + strconv.FormatFloat(lit, 'g', -1, 64)
+		}
+		fmt.Println(line)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
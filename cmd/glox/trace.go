@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// tracer prints every statement that runs with its line, indented by the
+// depth of the calls, for -trace.
+type tracer struct {
+	w     io.Writer
+	lines []string // of the source being run
+	depth int
+}
+
+// trace is set by -trace.
+var trace *tracer
+
+// source sets the source that the following runs come from.
+func (t *tracer) source(src string) {
+	t.lines = strings.Split(src, "\n")
+}
+
+func (t *tracer) hooks() *lox.Hooks {
+	return &lox.Hooks{
+		OnStatement: func(line int) {
+			text := ""
+			if line >= 1 && line <= len(t.lines) {
+				text = strings.TrimSpace(t.lines[line-1])
+			}
+			fmt.Fprintf(t.w, "%4d: %v%v\n", line, strings.Repeat("  ", t.depth), text)
+		},
+		OnCall: func(string, []lox.Value) {
+			t.depth++
+		},
+		OnReturn: func(lox.Value) {
+			if t.depth > 0 {
+				t.depth--
+			}
+		},
+	}
+}
+
+// combineHooks returns hooks that call all of the hooks in list, nil if
+// there are none.
+func combineHooks(list ...*lox.Hooks) *lox.Hooks {
+	switch len(list) {
+	case 0:
+		return nil
+	case 1:
+		return list[0]
+	}
+	combined := &lox.Hooks{
+		OnStatement: func(line int) {
+			for _, h := range list {
+				if h.OnStatement != nil {
+					h.OnStatement(line)
+				}
+			}
+		},
+		OnCall: func(name string, args []lox.Value) {
+			for _, h := range list {
+				if h.OnCall != nil {
+					h.OnCall(name, args)
+				}
+			}
+		},
+		OnReturn: func(result lox.Value) {
+			for _, h := range list {
+				if h.OnReturn != nil {
+					h.OnReturn(result)
+				}
+			}
+		},
+	}
+	for _, h := range list {
+		if h.OnScope != nil {
+			// set only if used, as the scopes cost to make
+			combined.OnScope = func(sc *lox.Scope) {
+				for _, h := range list {
+					if h.OnScope != nil {
+						h.OnScope(sc)
+					}
+				}
+			}
+			break
+		}
+	}
+	return combined
+}
@@ -0,0 +1,95 @@
+// Concurrent runs the same script in many interpreters at once, each on its
+// own goroutine, and checks that they do not disturb each other. Build it
+// with the race detector to check for data races too:
+//
+//	go run -race ./examples/concurrent
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ysmolsky/glox/pkg/lox"
+)
+
+// script uses the host function id, tasks and the temporary sandbox, and
+// ends with an expression for Eval to return.
+const script = `
+fun fib(n) {
+	if (n < 2) return n;
+	return fib(n - 2) + fib(n - 1);
+}
+var s = "";
+var i = 0;
+while (i < id()) {
+	s = s + "x";
+	i = i + 1;
+}
+var f = tempFile();
+writeFile(f, s);
+var t = spawn(fun() { return fib(12); });
+print readFile(f) == s;
+print join(t) + id();
+fib(15) + id()
+`
+
+const (
+	workers = 16
+	rounds  = 5
+)
+
+func main() {
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	for id := 0; id < workers; id++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			errs[id] = work(id)
+		}(id)
+	}
+	wg.Wait()
+	failed := false
+	for id, err := range errs {
+		if err != nil {
+			fmt.Printf("interpreter %v: %v\n", id, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+	fmt.Printf("%v interpreters ran %v scripts each\n", workers, rounds)
+}
+
+// work evaluates the script repeatedly in a fresh interpreter, half of them
+// on the VM, and checks what it printed and returned.
+func work(id int) error {
+	var out bytes.Buffer
+	backend := "tree"
+	if id%2 == 1 {
+		backend = "vm"
+	}
+	in := lox.New(lox.WithStdout(&out), lox.WithBackend(backend), lox.EnableFileIO())
+	defer in.Cleanup()
+	if err := in.RegisterFunc("id", func() int { return id }); err != nil {
+		return err
+	}
+	for i := 0; i < rounds; i++ {
+		v, err := in.Eval(script)
+		if err != nil {
+			return err
+		}
+		if n, _ := v.Number(); n != float64(610+id) {
+			return fmt.Errorf("returned %v, want %v", v, 610+id)
+		}
+	}
+	want := strings.Repeat(fmt.Sprintf("true\n%v\n", 144+id), rounds)
+	if out.String() != want {
+		return fmt.Errorf("printed %q, want %q", out.String(), want)
+	}
+	return nil
+}
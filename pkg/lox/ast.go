@@ -0,0 +1,422 @@
+// Code generated by genast from ast.def; DO NOT EDIT.
+
+package lox
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type (
+	AssignExpr struct {
+		name  *Token
+		value Expr
+		binding
+		expr
+	}
+
+	BinaryExpr struct {
+		operator    *Token
+		left, right Expr
+		expr
+	}
+
+	CallExpr struct {
+		callee Expr
+		paren  *Token
+		args   []Expr
+		site   callSite // cached by the interpreter
+		expr
+	}
+
+	FunExpr struct {
+		keyword *Token
+		params  []*Token
+		body    []Stmt
+		slots   []*Token // declarations of the parameters and locals, by slot
+		expr
+	}
+
+	GroupingExpr struct {
+		e Expr
+		expr
+	}
+
+	LiteralExpr struct {
+		token *Token // nil for the literals made by the optimizer
+		value Value
+		expr
+	}
+
+	LogicalExpr struct {
+		operator    *Token
+		left, right Expr
+		expr
+	}
+
+	UnaryExpr struct {
+		operator *Token
+		right    Expr
+		expr
+	}
+
+	VarExpr struct {
+		name *Token
+		binding
+		expr
+	}
+)
+
+type (
+	BlockStmt struct {
+		list  []Stmt
+		slots []*Token // declarations of the locals, by slot
+		stmt
+	}
+
+	BreakStmt struct {
+		keyword *Token
+		stmt
+	}
+
+	ContinueStmt struct {
+		keyword *Token
+		stmt
+	}
+
+	ExprStmt struct {
+		expression Expr
+		stmt
+	}
+
+	FunStmt struct {
+		name   *Token
+		params []*Token
+		body   []Stmt
+		slot   int      // of the function itself, negative for globals
+		slots  []*Token // declarations of the parameters and locals, by slot
+		stmt
+	}
+
+	IfStmt struct {
+		condition Expr
+		block1    Stmt
+		block2    Stmt
+		stmt
+	}
+
+	PrintStmt struct {
+		expression Expr
+		stmt
+	}
+
+	ReturnStmt struct {
+		keyword *Token
+		value   Expr
+		stmt
+	}
+
+	VarStmt struct {
+		name *Token
+		init Expr
+		slot int // negative for globals
+		stmt
+	}
+
+	WhileStmt struct {
+		keyword   *Token // while or for
+		condition Expr
+		body      Stmt
+		stmt
+	}
+)
+
+// The constructors take the syntax of the nodes, the fields that the
+// resolver and the interpreter fill in are left zero.
+
+func newAssignExpr(name *Token, value Expr) *AssignExpr {
+	return &AssignExpr{name: name, value: value}
+}
+
+func newBinaryExpr(operator *Token, left, right Expr) *BinaryExpr {
+	return &BinaryExpr{operator: operator, left: left, right: right}
+}
+
+func newCallExpr(callee Expr, paren *Token, args []Expr) *CallExpr {
+	return &CallExpr{callee: callee, paren: paren, args: args}
+}
+
+func newFunExpr(keyword *Token, params []*Token, body []Stmt) *FunExpr {
+	return &FunExpr{keyword: keyword, params: params, body: body}
+}
+
+func newGroupingExpr(e Expr) *GroupingExpr {
+	return &GroupingExpr{e: e}
+}
+
+func newLiteralExpr(token *Token, value Value) *LiteralExpr {
+	return &LiteralExpr{token: token, value: value}
+}
+
+func newLogicalExpr(operator *Token, left, right Expr) *LogicalExpr {
+	return &LogicalExpr{operator: operator, left: left, right: right}
+}
+
+func newUnaryExpr(operator *Token, right Expr) *UnaryExpr {
+	return &UnaryExpr{operator: operator, right: right}
+}
+
+func newVarExpr(name *Token) *VarExpr {
+	return &VarExpr{name: name}
+}
+
+func newBlockStmt(list []Stmt) *BlockStmt {
+	return &BlockStmt{list: list}
+}
+
+func newBreakStmt(keyword *Token) *BreakStmt {
+	return &BreakStmt{keyword: keyword}
+}
+
+func newContinueStmt(keyword *Token) *ContinueStmt {
+	return &ContinueStmt{keyword: keyword}
+}
+
+func newExprStmt(expression Expr) *ExprStmt {
+	return &ExprStmt{expression: expression}
+}
+
+func newFunStmt(name *Token, params []*Token, body []Stmt) *FunStmt {
+	return &FunStmt{name: name, params: params, body: body}
+}
+
+func newIfStmt(condition Expr, block1 Stmt, block2 Stmt) *IfStmt {
+	return &IfStmt{condition: condition, block1: block1, block2: block2}
+}
+
+func newPrintStmt(expression Expr) *PrintStmt {
+	return &PrintStmt{expression: expression}
+}
+
+func newReturnStmt(keyword *Token, value Expr) *ReturnStmt {
+	return &ReturnStmt{keyword: keyword, value: value}
+}
+
+func newVarStmt(name *Token, init Expr) *VarStmt {
+	return &VarStmt{name: name, init: init}
+}
+
+func newWhileStmt(keyword *Token, condition Expr, body Stmt) *WhileStmt {
+	return &WhileStmt{keyword: keyword, condition: condition, body: body}
+}
+
+// walkChildren calls Walk for the children of node.
+func walkChildren(v Visitor, node Node) {
+	switch n := node.(type) {
+	case *AssignExpr:
+		Walk(v, n.value)
+	case *BinaryExpr:
+		Walk(v, n.left)
+		Walk(v, n.right)
+	case *CallExpr:
+		Walk(v, n.callee)
+		walkExprs(v, n.args)
+	case *FunExpr:
+		walkStmts(v, n.body)
+	case *GroupingExpr:
+		Walk(v, n.e)
+	case *LiteralExpr:
+	case *LogicalExpr:
+		Walk(v, n.left)
+		Walk(v, n.right)
+	case *UnaryExpr:
+		Walk(v, n.right)
+	case *VarExpr:
+	case *BlockStmt:
+		walkStmts(v, n.list)
+	case *BreakStmt:
+	case *ContinueStmt:
+	case *ExprStmt:
+		Walk(v, n.expression)
+	case *FunStmt:
+		walkStmts(v, n.body)
+	case *IfStmt:
+		Walk(v, n.condition)
+		Walk(v, n.block1)
+		if n.block2 != nil {
+			Walk(v, n.block2)
+		}
+	case *PrintStmt:
+		Walk(v, n.expression)
+	case *ReturnStmt:
+		if n.value != nil {
+			Walk(v, n.value)
+		}
+	case *VarStmt:
+		if n.init != nil {
+			Walk(v, n.init)
+		}
+	case *WhileStmt:
+		Walk(v, n.condition)
+		Walk(v, n.body)
+	default:
+		panic("unexpected node")
+	}
+}
+
+// jsonNode is a node in the JSON form of the AST, with the members of all
+// the nodes.
+type jsonNode struct {
+	Node       string          `json:"node"`
+	First      *jsonToken      `json:"first,omitempty"`
+	Last       *jsonToken      `json:"last,omitempty"`
+	Name       *jsonToken      `json:"name,omitempty"`
+	Value      *jsonNode       `json:"value,omitempty"`
+	Operator   *jsonToken      `json:"operator,omitempty"`
+	Left       *jsonNode       `json:"left,omitempty"`
+	Right      *jsonNode       `json:"right,omitempty"`
+	Callee     *jsonNode       `json:"callee,omitempty"`
+	Paren      *jsonToken      `json:"paren,omitempty"`
+	Args       []*jsonNode     `json:"args,omitempty"`
+	Keyword    *jsonToken      `json:"keyword,omitempty"`
+	Params     []*jsonToken    `json:"params,omitempty"`
+	Body       []*jsonNode     `json:"body,omitempty"`
+	Expression *jsonNode       `json:"expression,omitempty"`
+	Token      *jsonToken      `json:"token,omitempty"`
+	Literal    json.RawMessage `json:"literal,omitempty"`
+	Condition  *jsonNode       `json:"condition,omitempty"`
+	Then       *jsonNode       `json:"then,omitempty"`
+	Else       *jsonNode       `json:"else,omitempty"`
+	Init       *jsonNode       `json:"init,omitempty"`
+	Loop       *jsonNode       `json:"loop,omitempty"`
+}
+
+// fields sets the name and the members of the syntax of node in n.
+func (enc *astEncoder) fields(n *jsonNode, node Node) {
+	switch node := node.(type) {
+	case *AssignExpr:
+		n.Node = "AssignExpr"
+		n.Name = encodeToken(node.name)
+		n.Value = enc.expr(node.value)
+	case *BinaryExpr:
+		n.Node = "BinaryExpr"
+		n.Operator = encodeToken(node.operator)
+		n.Left = enc.expr(node.left)
+		n.Right = enc.expr(node.right)
+	case *CallExpr:
+		n.Node = "CallExpr"
+		n.Callee = enc.expr(node.callee)
+		n.Paren = encodeToken(node.paren)
+		n.Args = enc.exprs(node.args)
+	case *FunExpr:
+		n.Node = "FunExpr"
+		n.Keyword = encodeToken(node.keyword)
+		n.Params = encodeTokens(node.params)
+		n.Body = enc.stmts(node.body)
+	case *GroupingExpr:
+		n.Node = "GroupingExpr"
+		n.Expression = enc.expr(node.e)
+	case *LiteralExpr:
+		n.Node = "LiteralExpr"
+		n.Token = encodeToken(node.token)
+		n.Literal = enc.value(node.value)
+	case *LogicalExpr:
+		n.Node = "LogicalExpr"
+		n.Operator = encodeToken(node.operator)
+		n.Left = enc.expr(node.left)
+		n.Right = enc.expr(node.right)
+	case *UnaryExpr:
+		n.Node = "UnaryExpr"
+		n.Operator = encodeToken(node.operator)
+		n.Right = enc.expr(node.right)
+	case *VarExpr:
+		n.Node = "VarExpr"
+		n.Name = encodeToken(node.name)
+	case *BlockStmt:
+		n.Node = "BlockStmt"
+		n.Body = enc.stmts(node.list)
+	case *BreakStmt:
+		n.Node = "BreakStmt"
+		n.Keyword = encodeToken(node.keyword)
+	case *ContinueStmt:
+		n.Node = "ContinueStmt"
+		n.Keyword = encodeToken(node.keyword)
+	case *ExprStmt:
+		n.Node = "ExprStmt"
+		n.Expression = enc.expr(node.expression)
+	case *FunStmt:
+		n.Node = "FunStmt"
+		n.Name = encodeToken(node.name)
+		n.Params = encodeTokens(node.params)
+		n.Body = enc.stmts(node.body)
+	case *IfStmt:
+		n.Node = "IfStmt"
+		n.Condition = enc.expr(node.condition)
+		n.Then = enc.stmt(node.block1)
+		n.Else = enc.stmt(node.block2)
+	case *PrintStmt:
+		n.Node = "PrintStmt"
+		n.Expression = enc.expr(node.expression)
+	case *ReturnStmt:
+		n.Node = "ReturnStmt"
+		n.Keyword = encodeToken(node.keyword)
+		n.Value = enc.expr(node.value)
+	case *VarStmt:
+		n.Node = "VarStmt"
+		n.Name = encodeToken(node.name)
+		n.Init = enc.expr(node.init)
+	case *WhileStmt:
+		n.Node = "WhileStmt"
+		n.Keyword = encodeToken(node.keyword)
+		n.Condition = enc.expr(node.condition)
+		n.Loop = enc.stmt(node.body)
+	default:
+		panic(fmt.Sprintf("unexpected node %T", node))
+	}
+}
+
+// node returns the node that n describes, nil if there is none by its
+// name.
+func (d *astDecoder) node(n *jsonNode) Node {
+	switch n.Node {
+	case "AssignExpr":
+		return newAssignExpr(d.token(n, n.Name, "name", true), d.expr(n.Value, "value", true))
+	case "BinaryExpr":
+		return newBinaryExpr(d.token(n, n.Operator, "operator", true), d.expr(n.Left, "left", true), d.expr(n.Right, "right", true))
+	case "CallExpr":
+		return newCallExpr(d.expr(n.Callee, "callee", true), d.token(n, n.Paren, "paren", true), d.exprs(n.Args))
+	case "FunExpr":
+		return newFunExpr(d.token(n, n.Keyword, "keyword", true), d.tokens(n, n.Params, "params"), d.stmts(n.Body))
+	case "GroupingExpr":
+		return newGroupingExpr(d.expr(n.Expression, "expression", true))
+	case "LiteralExpr":
+		return newLiteralExpr(d.token(n, n.Token, "token", false), d.value(n.Literal))
+	case "LogicalExpr":
+		return newLogicalExpr(d.token(n, n.Operator, "operator", true), d.expr(n.Left, "left", true), d.expr(n.Right, "right", true))
+	case "UnaryExpr":
+		return newUnaryExpr(d.token(n, n.Operator, "operator", true), d.expr(n.Right, "right", true))
+	case "VarExpr":
+		return newVarExpr(d.token(n, n.Name, "name", true))
+	case "BlockStmt":
+		return newBlockStmt(d.stmts(n.Body))
+	case "BreakStmt":
+		return newBreakStmt(d.token(n, n.Keyword, "keyword", true))
+	case "ContinueStmt":
+		return newContinueStmt(d.token(n, n.Keyword, "keyword", true))
+	case "ExprStmt":
+		return newExprStmt(d.expr(n.Expression, "expression", true))
+	case "FunStmt":
+		return newFunStmt(d.token(n, n.Name, "name", true), d.tokens(n, n.Params, "params"), d.stmts(n.Body))
+	case "IfStmt":
+		return newIfStmt(d.expr(n.Condition, "condition", true), d.stmt(n.Then, "then", true), d.stmt(n.Else, "else", false))
+	case "PrintStmt":
+		return newPrintStmt(d.expr(n.Expression, "expression", true))
+	case "ReturnStmt":
+		return newReturnStmt(d.token(n, n.Keyword, "keyword", true), d.expr(n.Value, "value", false))
+	case "VarStmt":
+		return newVarStmt(d.token(n, n.Name, "name", true), d.expr(n.Init, "init", false))
+	case "WhileStmt":
+		return newWhileStmt(d.token(n, n.Keyword, "keyword", true), d.expr(n.Condition, "condition", true), d.stmt(n.Loop, "loop", true))
+	}
+	return nil
+}
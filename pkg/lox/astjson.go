@@ -0,0 +1,324 @@
+package lox
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// The JSON form of the AST
+//
+// MarshalAST writes a list of statements as a JSON array with an object for
+// every node. The "node" member names its type, e.g. "BinaryExpr" or
+// "WhileStmt", the other members are its tokens and children as ast.def
+// lists them, which also gives the few of them a name of their own:
+//
+//	GroupingExpr  expression
+//	LiteralExpr   token, literal
+//	BlockStmt     body
+//	IfStmt        condition, then, else
+//	WhileStmt     keyword, condition, loop
+//
+// Statements also have the first and last token of their source as
+// "first" and "last". A token is an object with its "type", "lexeme",
+// "line", "col" and "pos", and the "literal" of numbers and strings. The
+// members that are missing from the source, like the else of an if, are
+// left out, and so are the tokens of the nodes that the parser made up
+// while desugaring a for loop or the optimizer while folding constants.
+//
+// The jsonNode type and the conversion of every node, astEncoder.fields and
+// astDecoder.node, are generated with the nodes in ast.go.
+
+type jsonToken struct {
+	Type    string      `json:"type"`
+	Lexeme  string      `json:"lexeme"`
+	Line    int         `json:"line"`
+	Col     int         `json:"col"`
+	Pos     int         `json:"pos"`
+	Literal interface{} `json:"literal,omitempty"`
+}
+
+// MarshalAST writes stmts as JSON, see above for the form.
+func MarshalAST(stmts []Stmt) ([]byte, error) {
+	var enc astEncoder
+	nodes := enc.stmts(stmts)
+	if enc.err != nil {
+		return nil, enc.err
+	}
+	return json.Marshal(nodes)
+}
+
+// UnmarshalAST reads statements written by MarshalAST. They are not
+// resolved yet, like the ones of the Parser, but they are checked like the
+// Parser checks them for a return outside of functions and a break or
+// continue outside of loops.
+func UnmarshalAST(data []byte) ([]Stmt, error) {
+	var nodes []*jsonNode
+	if err := json.Unmarshal(data, &nodes); err != nil {
+		return nil, err
+	}
+	d := astDecoder{strs: make(interner)}
+	stmts := d.stmts(nodes)
+	if d.err != nil {
+		return nil, d.err
+	}
+	var pc placementChecker
+	walkStmts(&pc, stmts)
+	if pc.err != nil {
+		return nil, pc.err
+	}
+	return stmts, nil
+}
+
+// placementChecker finds the first statement that the Parser would not
+// allow where it is.
+type placementChecker struct {
+	loops []int // the loops around the statement in each function, innermost last
+	err   error
+}
+
+func (c *placementChecker) Enter(n Node) bool {
+	if c.err != nil {
+		return false
+	}
+	if len(c.loops) == 0 {
+		c.loops = []int{0} // the top level
+	}
+	in := len(c.loops) - 1
+	switch n := n.(type) {
+	case *FunStmt, *FunExpr:
+		c.loops = append(c.loops, 0)
+	case *WhileStmt:
+		c.loops[in]++
+	case *BreakStmt:
+		if c.loops[in] == 0 {
+			c.err = ParsingError(errorAtToken(n.keyword, msgOutsideLoop))
+		}
+	case *ContinueStmt:
+		if c.loops[in] == 0 {
+			c.err = ParsingError(errorAtToken(n.keyword, msgOutsideLoop))
+		}
+	case *ReturnStmt:
+		if in == 0 {
+			c.err = ParsingError(errorAtToken(n.keyword, msgTopReturn))
+		}
+	}
+	return true
+}
+
+func (c *placementChecker) Leave(n Node) {
+	if c.err != nil {
+		return
+	}
+	switch n.(type) {
+	case *FunStmt, *FunExpr:
+		c.loops = c.loops[:len(c.loops)-1]
+	case *WhileStmt:
+		c.loops[len(c.loops)-1]--
+	}
+}
+
+// astEncoder turns the AST into JSON nodes, keeping the first error.
+type astEncoder struct {
+	err error
+}
+
+func encodeToken(t *Token) *jsonToken {
+	if t == nil {
+		return nil
+	}
+	return &jsonToken{t.tok.String(), t.lexeme, t.line, t.col, t.pos, t.literal}
+}
+
+// MarshalJSON writes t in the form that the AST uses for its tokens, see
+// MarshalAST.
+func (t *Token) MarshalJSON() ([]byte, error) {
+	return json.Marshal(encodeToken(t))
+}
+
+func encodeTokens(ts []*Token) []*jsonToken {
+	var js []*jsonToken
+	for _, t := range ts {
+		js = append(js, encodeToken(t))
+	}
+	return js
+}
+
+func (enc *astEncoder) stmts(stmts []Stmt) []*jsonNode {
+	nodes := []*jsonNode{}
+	for _, s := range stmts {
+		nodes = append(nodes, enc.stmt(s))
+	}
+	return nodes
+}
+
+func (enc *astEncoder) exprs(exprs []Expr) []*jsonNode {
+	var nodes []*jsonNode
+	for _, x := range exprs {
+		nodes = append(nodes, enc.expr(x))
+	}
+	return nodes
+}
+
+func (enc *astEncoder) stmt(s Stmt) *jsonNode {
+	if s == nil {
+		return nil
+	}
+	first, last := s.span()
+	n := &jsonNode{First: encodeToken(first), Last: encodeToken(last)}
+	enc.fields(n, s)
+	return n
+}
+
+func (enc *astEncoder) expr(e Expr) *jsonNode {
+	if e == nil {
+		return nil
+	}
+	n := &jsonNode{}
+	enc.fields(n, e)
+	return n
+}
+
+// value encodes the value of a literal.
+func (enc *astEncoder) value(v Value) json.RawMessage {
+	lit, err := json.Marshal(v.Interface())
+	if err != nil && enc.err == nil {
+		enc.err = fmt.Errorf("the literal %v: %v", v, err)
+	}
+	return lit
+}
+
+// tokenTypes maps the names of the token types back to them.
+var tokenTypes = func() map[string]TokenType {
+	m := make(map[string]TokenType)
+	for t := LeftParen; t <= EOF; t++ {
+		m[t.String()] = t
+	}
+	return m
+}()
+
+// astDecoder turns the JSON nodes back into the AST, keeping the first
+// error.
+type astDecoder struct {
+	err  error
+	strs interner // of the names
+}
+
+func (d *astDecoder) fail(format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, args...)
+	}
+}
+
+// token decodes the member of n called what, which may be missing unless
+// it is required.
+func (d *astDecoder) token(n *jsonNode, t *jsonToken, what string, required bool) *Token {
+	if t == nil {
+		if required {
+			d.fail("%v without %v", n.Node, what)
+		}
+		return nil
+	}
+	tok, ok := tokenTypes[t.Type]
+	if !ok {
+		d.fail("unknown token type '%v'", t.Type)
+	}
+	lit := t.Literal
+	if lit != nil {
+		switch tok {
+		case Number:
+			if _, ok := lit.(float64); !ok {
+				d.fail("number token with the literal %v", lit)
+			}
+		case String:
+			if _, ok := lit.(string); !ok {
+				d.fail("string token with the literal %v", lit)
+			}
+		default:
+			lit = nil
+		}
+	}
+	lexeme := t.Lexeme
+	if tok == Identifier {
+		lexeme = d.strs.intern([]byte(lexeme))
+	}
+	return &Token{tok: tok, lexeme: lexeme, line: t.Line, col: t.Col, pos: t.Pos, literal: lit}
+}
+
+func (d *astDecoder) tokens(n *jsonNode, ts []*jsonToken, what string) []*Token {
+	var toks []*Token
+	for _, t := range ts {
+		toks = append(toks, d.token(n, t, what, true))
+	}
+	return toks
+}
+
+func (d *astDecoder) stmts(nodes []*jsonNode) []Stmt {
+	stmts := []Stmt{}
+	for _, n := range nodes {
+		stmts = append(stmts, d.stmt(n, "statement", true))
+	}
+	return stmts
+}
+
+func (d *astDecoder) exprs(nodes []*jsonNode) []Expr {
+	var exprs []Expr
+	for _, n := range nodes {
+		exprs = append(exprs, d.expr(n, "argument", true))
+	}
+	return exprs
+}
+
+// stmt decodes the statement n, which is the member what of its parent.
+func (d *astDecoder) stmt(n *jsonNode, what string, required bool) Stmt {
+	if n == nil {
+		if required {
+			d.fail("missing %v", what)
+		}
+		return nil
+	}
+	s, ok := d.node(n).(Stmt)
+	if !ok {
+		d.fail("%v is not a statement node", n.Node)
+		return nil
+	}
+	s.setSpan(d.token(n, n.First, "first", false), d.token(n, n.Last, "last", false))
+	return s
+}
+
+// expr decodes the expression n, which is the member what of its parent.
+func (d *astDecoder) expr(n *jsonNode, what string, required bool) Expr {
+	if n == nil {
+		if required {
+			d.fail("missing %v", what)
+		}
+		return nil
+	}
+	e, ok := d.node(n).(Expr)
+	if !ok {
+		d.fail("%v is not an expression node", n.Node)
+		return nil
+	}
+	return e
+}
+
+// value decodes the value of a literal, nil if it is missing.
+func (d *astDecoder) value(lit json.RawMessage) Value {
+	var x interface{}
+	if lit != nil {
+		if err := json.Unmarshal(lit, &x); err != nil {
+			d.fail("%v", err)
+		}
+	}
+	switch x := x.(type) {
+	case nil:
+		return nilValue
+	case bool:
+		return BoolValue(x)
+	case float64:
+		return NumberValue(x)
+	case string:
+		return StringValue(x)
+	}
+	d.fail("the literal %v is not nil, a boolean, a number or a string", x)
+	return nilValue
+}
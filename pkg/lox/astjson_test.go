@@ -0,0 +1,109 @@
+package lox
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestASTRoundTrip writes the AST of the test programs as JSON, reads it
+// back and writes it again, which must give the same JSON and a program
+// that prints the same.
+func TestASTRoundTrip(t *testing.T) {
+	files, err := filepath.Glob("../../test/*.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range files {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		toks, err := NewScanner(string(src)).Scan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stmts, errs := NewParser(toks).Parse()
+		if len(errs) > 0 {
+			continue
+		}
+		data, err := MarshalAST(stmts)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		back, err := UnmarshalAST(data)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		again, err := MarshalAST(back)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if !bytes.Equal(data, again) {
+			t.Errorf("%v: the JSON changed after a round trip:\n%s\n%s", name, data, again)
+		}
+		if got, want := runStmts(t, back), runStmts(t, stmts); got != want {
+			t.Errorf("%v: read back it prints\n%v\nwant\n%v", name, got, want)
+		}
+	}
+}
+
+// runStmts runs parsed statements and returns what they printed.
+func runStmts(t *testing.T, stmts []Stmt) string {
+	t.Helper()
+	var out bytes.Buffer
+	in := New(WithStdout(&out), WithStderr(&out), WithMaxSteps(100000))
+	if err := in.RunContext(context.Background(), stmts); err != nil {
+		out.WriteString(err.Error())
+	}
+	return out.String()
+}
+
+func TestUnmarshalASTErrors(t *testing.T) {
+	tests := []struct {
+		json, err string
+	}{
+		{`[{"node": "NoStmt"}]`, "NoStmt is not a statement node"},
+		{`[{"node": "VarExpr", "name": {"type": "ident", "lexeme": "a"}}]`, "VarExpr is not a statement node"},
+		{`[{"node": "ExprStmt", "expression": {"node": "PrintStmt", "expression": {"node": "LiteralExpr"}}}]`, "PrintStmt is not an expression node"},
+		{`[{"node": "ExprStmt"}]`, "missing expression"},
+		{`[{"node": "BreakStmt"}]`, "BreakStmt without keyword"},
+		{`[{"node": "IfStmt", "condition": {"node": "LiteralExpr", "literal": true}}]`, "missing then"},
+		{`[{"node": "ExprStmt", "expression": {"node": "LiteralExpr", "literal": [1]}}]`, "is not nil, a boolean, a number or a string"},
+		{`[{"node": "ReturnStmt", "keyword": {"type": "return", "lexeme": "return", "line": 1}}]`, "[line 1] error at 'return': can't return from top-level code"},
+		{`[{"node": "BreakStmt", "keyword": {"type": "break", "lexeme": "break", "line": 2}}]`, "[line 2] error at 'break': expected inside the loop"},
+		{`[{"node": "ContinueStmt", "keyword": {"type": "continue", "lexeme": "continue", "line": 3}}]`, "[line 3] error at 'continue': expected inside the loop"},
+		{`[{"node": "WhileStmt", "keyword": {"type": "while", "lexeme": "while"},
+		   "condition": {"node": "LiteralExpr", "literal": true},
+		   "loop": {"node": "ExprStmt", "expression": {"node": "FunExpr", "keyword": {"type": "fun", "lexeme": "fun"},
+		     "body": [{"node": "BreakStmt", "keyword": {"type": "break", "lexeme": "break", "line": 4}}]}}}]`,
+			"[line 4] error at 'break': expected inside the loop"},
+	}
+	for _, tt := range tests {
+		_, err := UnmarshalAST([]byte(tt.json))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%v: got %v, want %q", tt.json, err, tt.err)
+		}
+	}
+}
+
+// TestUnmarshalASTPlacement reads statements that are where the Parser
+// allows them, which must not be reported.
+func TestUnmarshalASTPlacement(t *testing.T) {
+	const src = `
+fun f() { while (true) { if (true) break; continue; } return 1; }
+var g = fun() { return 2; };
+for (var i = 0; i < 3; i = i + 1) { fun h() { return i; } if (i == 1) continue; }
+`
+	stmts := parseProgram(t, src)
+	data, err := MarshalAST(stmts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UnmarshalAST(data); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,55 @@
+package lox
+
+import (
+	"bytes"
+	"testing"
+)
+
+// backends are the ways to run programs, the behavior tests run on each.
+var backends = []string{"tree", "vm"}
+
+// programTest is a program and what it prints, followed by the error it
+// fails with, if any.
+type programTest struct {
+	src, want string
+}
+
+// testPrograms runs the programs on every backend with opts and checks what
+// they print.
+func testPrograms(t *testing.T, tests []programTest, opts ...Option) {
+	t.Helper()
+	for _, backend := range backends {
+		for _, tt := range tests {
+			got := runProgram(t, tt.src, append(opts[:len(opts):len(opts)], WithBackend(backend))...)
+			if got != tt.want {
+				t.Errorf("%v backend, %q:\ngot  %q\nwant %q", backend, tt.src, got, tt.want)
+			}
+		}
+	}
+}
+
+// runProgram runs src with opts and returns what it printed, followed by
+// its error if any.
+func runProgram(t *testing.T, src string, opts ...Option) string {
+	t.Helper()
+	var out bytes.Buffer
+	in := New(append(opts, WithStdout(&out), WithStderr(&out), WithMaxSteps(100000))...)
+	if err := in.Run(src); err != nil {
+		out.WriteString(err.Error())
+	}
+	return out.String()
+}
+
+// parseProgram parses src, which must be free of syntax errors.
+func parseProgram(tb testing.TB, src string) []Stmt {
+	tb.Helper()
+	toks, err := NewScanner(src).Scan()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	stmts, errs := NewParser(toks).Parse()
+	if len(errs) > 0 {
+		tb.Fatal(errs)
+	}
+	return stmts
+}
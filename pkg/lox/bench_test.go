@@ -0,0 +1,51 @@
+package lox
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+// benchmarkProgram runs src on each backend, parsed once, b.N times.
+func benchmarkProgram(b *testing.B, src string) {
+	stmts := parseProgram(b, src)
+	for _, backend := range backends {
+		b.Run(backend, func(b *testing.B) {
+			in := New(WithBackend(backend), WithStdout(io.Discard))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := in.RunContext(context.Background(), stmts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkFib is dominated by calls and by reads of parameters.
+func BenchmarkFib(b *testing.B) {
+	benchmarkProgram(b, `
+fun fib(n) {
+	if (n < 2) return n;
+	return fib(n - 1) + fib(n - 2);
+}
+print fib(20);
+`)
+}
+
+// BenchmarkLocalLoop reads and assigns locals of nested blocks.
+func BenchmarkLocalLoop(b *testing.B) {
+	benchmarkProgram(b, `
+{
+	var sum = 0;
+	var i = 0;
+	while (i < 100000) {
+		var j = i * 2;
+		sum = sum + j;
+		i = i + 1;
+	}
+	print sum;
+}
+`)
+}
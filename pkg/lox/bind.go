@@ -0,0 +1,224 @@
+package lox
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// Binding Go values
+//
+// ToValue and FromValue convert between Go and Lox values by reflection, so
+// that the host can expose plain Go functions with RegisterFunc instead of
+// writing natives against Value. Booleans, numbers of any Go type and
+// strings become their Lox counterparts. Lox has no lists, maps or objects,
+// so slices, arrays, maps, structs and pointers pass through scripts as
+// host values: scripts can store them, print them and hand them back to
+// natives, which get the very same Go value.
+
+// hostObj boxes a host value, so that == compares values by identity and
+// the Go value does not have to be comparable. deepEquals() compares the Go
+// values.
+type hostObj struct {
+	v interface{}
+}
+
+func (h *hostObj) String() string {
+	return fmt.Sprint(h.v)
+}
+
+var (
+	valueType = reflect.TypeOf(Value{})
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// ToValue converts the Go value x to a Lox value. Functions become natives
+// like with RegisterFunc.
+func ToValue(x interface{}) (Value, error) {
+	if x == nil {
+		return nilValue, nil
+	}
+	return toValue(reflect.ValueOf(x))
+}
+
+func toValue(rv reflect.Value) (Value, error) {
+	if rv.Type() == valueType {
+		return rv.Interface().(Value), nil
+	}
+	switch rv.Kind() {
+	case reflect.Bool:
+		return BoolValue(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NumberValue(float64(rv.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return NumberValue(float64(rv.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return NumberValue(rv.Float()), nil
+	case reflect.String:
+		return StringValue(rv.String()), nil
+	case reflect.Interface:
+		if rv.IsNil() {
+			return nilValue, nil
+		}
+		return toValue(rv.Elem())
+	case reflect.Func:
+		if rv.IsNil() {
+			return nilValue, nil
+		}
+		n, err := wrapFunc(runtime.FuncForPC(rv.Pointer()).Name(), rv)
+		if err != nil {
+			return nilValue, err
+		}
+		return funValue(n), nil
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if rv.IsNil() {
+			return nilValue, nil
+		}
+		fallthrough
+	case reflect.Array, reflect.Struct:
+		return Value{kind: hostKind, obj: &hostObj{rv.Interface()}}, nil
+	}
+	return nilValue, fmt.Errorf("cannot convert %v to a Lox value", rv.Type())
+}
+
+// FromValue stores the Lox value v into the Go variable that ptr points to,
+// converting it to the type of the variable.
+func FromValue(v Value, ptr interface{}) error {
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("FromValue needs a non-nil pointer, got %T", ptr)
+	}
+	x, err := fromValue(v, rv.Type().Elem())
+	if err != nil {
+		return err
+	}
+	rv.Elem().Set(x)
+	return nil
+}
+
+func fromValue(v Value, t reflect.Type) (reflect.Value, error) {
+	if t == valueType {
+		return reflect.ValueOf(v), nil
+	}
+	if v.kind == hostKind {
+		h := reflect.ValueOf(v.obj.(*hostObj).v)
+		if h.Type().AssignableTo(t) {
+			return h, nil
+		}
+		return reflect.Value{}, fmt.Errorf("cannot use %v as %v", h.Type(), t)
+	}
+
+	x := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, ok := v.Bool(); ok {
+			x.SetBool(b)
+			return x, nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if f, ok := v.Number(); ok {
+			// the bounds go first, out of them the conversion is undefined
+			if f != math.Trunc(f) || f < -(1<<63) || f >= 1<<63 || x.OverflowInt(int64(f)) {
+				return x, fmt.Errorf("cannot use %v as %v", v, t)
+			}
+			x.SetInt(int64(f))
+			return x, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if f, ok := v.Number(); ok {
+			if f != math.Trunc(f) || f < 0 || f >= 1<<64 || x.OverflowUint(uint64(f)) {
+				return x, fmt.Errorf("cannot use %v as %v", v, t)
+			}
+			x.SetUint(uint64(f))
+			return x, nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, ok := v.Number(); ok {
+			x.SetFloat(f)
+			return x, nil
+		}
+	case reflect.String:
+		if s, ok := v.Str(); ok {
+			x.SetString(s)
+			return x, nil
+		}
+	case reflect.Interface:
+		if g := v.Interface(); g == nil {
+			return x, nil
+		} else if reflect.TypeOf(g).AssignableTo(t) {
+			x.Set(reflect.ValueOf(g))
+			return x, nil
+		}
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func:
+		if v.IsNil() {
+			return x, nil
+		}
+	}
+	return x, fmt.Errorf("cannot use %v as %v", v.Quoted(), t)
+}
+
+// RegisterFunc defines the native name that calls the Go function fn. The
+// arguments of a call are converted with FromValue to the parameter types
+// of fn, and the result, if any, with ToValue. If the last result of fn is
+// an error, a non-nil error becomes a runtime error at the call. The name
+// may be qualified like `http.get`, see RegisterNativeIn.
+func (in *Interpreter) RegisterFunc(name string, fn interface{}) error {
+	for _, part := range strings.Split(name, ".") {
+		if !isIdentifier(part) {
+			return fmt.Errorf("invalid native name %q", name)
+		}
+	}
+	rv := reflect.ValueOf(fn)
+	if rv.Kind() != reflect.Func || rv.IsNil() {
+		return fmt.Errorf("native %q: %T is not a function", name, fn)
+	}
+	n, err := wrapFunc(name, rv)
+	if err != nil {
+		return err
+	}
+	in.exclusive(func() { in.globals.define(name, funValue(n)) })
+	return nil
+}
+
+// wrapFunc makes a native called name out of the Go function fn.
+func wrapFunc(name string, fn reflect.Value) (*nativeFn, error) {
+	t := fn.Type()
+	if t.IsVariadic() {
+		return nil, fmt.Errorf("native %q: variadic functions are not supported", name)
+	}
+	if t.NumIn() > 255 {
+		return nil, fmt.Errorf("native %q: too many parameters", name)
+	}
+	results := t.NumOut()
+	withErr := results > 0 && t.Out(results-1) == errorType
+	if withErr {
+		results--
+	}
+	if results > 1 {
+		return nil, fmt.Errorf("native %q: cannot return %v values", name, results)
+	}
+
+	call := func(_ *Interpreter, args []Value) (Value, error) {
+		in := make([]reflect.Value, len(args))
+		for i, a := range args {
+			x, err := fromValue(a, t.In(i))
+			if err != nil {
+				return nilValue, fmt.Errorf("argument %v: %v", i+1, err)
+			}
+			in[i] = x
+		}
+		out := fn.Call(in)
+		if withErr {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return nilValue, err
+			}
+		}
+		if results == 0 {
+			return nilValue, nil
+		}
+		return toValue(out[0])
+	}
+	return &nativeFn{name: name, nargs: t.NumIn(), cap: CapHost, fn: call}, nil
+}
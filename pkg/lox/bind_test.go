@@ -0,0 +1,178 @@
+package lox
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestToValue(t *testing.T) {
+	type point struct{ X, Y int }
+	tests := []struct {
+		x    interface{}
+		want string // the Quoted() value
+		typ  string
+		err  string
+	}{
+		{nil, "nil", "nil", ""},
+		{true, "true", "boolean", ""},
+		{int8(-3), "-3", "number", ""},
+		{uint64(1 << 40), "1099511627776", "number", ""},
+		{float32(2.5), "2.5", "number", ""},
+		{"s", `"s"`, "string", ""},
+		{NumberValue(7), "7", "number", ""},
+		{[]int(nil), "nil", "nil", ""},
+		{(*point)(nil), "nil", "nil", ""},
+		{[]int{1, 2}, "[1 2]", "host", ""},
+		{point{1, 2}, "{1 2}", "host", ""},
+		{func(a, b int) int { return a + b }, "", "native", ""},
+		{make(chan int), "", "", "cannot convert chan int to a Lox value"},
+		{func(xs ...int) {}, "", "", "variadic functions are not supported"},
+	}
+	for _, tt := range tests {
+		v, err := ToValue(tt.x)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ToValue(%#v): got %v, want the error %q", tt.x, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ToValue(%#v): %v", tt.x, err)
+			continue
+		}
+		if got := v.Type(); got != tt.typ {
+			t.Errorf("ToValue(%#v) is a %v, want a %v", tt.x, got, tt.typ)
+		}
+		if got := v.Quoted(); tt.want != "" && got != tt.want {
+			t.Errorf("ToValue(%#v) = %v, want %v", tt.x, got, tt.want)
+		}
+	}
+}
+
+func TestFromValue(t *testing.T) {
+	host, _ := ToValue([]string{"a"})
+	tests := []struct {
+		v    Value
+		ptr  interface{} // to a zero variable of the type to convert to
+		want interface{}
+		err  string
+	}{
+		{BoolValue(true), new(bool), true, ""},
+		{NumberValue(-128), new(int8), int8(-128), ""},
+		{NumberValue(255), new(uint8), uint8(255), ""},
+		{NumberValue(1 << 53), new(int64), int64(1 << 53), ""},
+		{NumberValue(-(1 << 63)), new(int64), int64(math.MinInt64), ""},
+		{NumberValue(1 << 63), new(uint64), uint64(1 << 63), ""},
+		{NumberValue(2.5), new(float32), float32(2.5), ""},
+		{StringValue("s"), new(string), "s", ""},
+		{StringValue("s"), new(interface{}), "s", ""},
+		{nilValue, new(interface{}), nil, ""},
+		{nilValue, new([]int), []int(nil), ""},
+		{host, new([]string), []string{"a"}, ""},
+		{NumberValue(3), new(Value), NumberValue(3), ""},
+
+		{NumberValue(128), new(int8), nil, "cannot use 128 as int8"},
+		{NumberValue(-1), new(uint), nil, "cannot use -1 as uint"},
+		{NumberValue(1.5), new(int), nil, "cannot use 1.5 as int"},
+		{NumberValue(1 << 63), new(int64), nil, "as int64"},
+		{NumberValue(1e30), new(int64), nil, "as int64"},
+		{NumberValue(1e30), new(uint64), nil, "as uint64"},
+		{NumberValue(1 << 64), new(uint64), nil, "as uint64"},
+		{NumberValue(math.Inf(1)), new(int), nil, "cannot use +Inf as int"},
+		{NumberValue(math.Inf(-1)), new(int64), nil, "cannot use -Inf as int64"},
+		{NumberValue(math.NaN()), new(uint32), nil, "cannot use NaN as uint32"},
+		{StringValue("1"), new(int), nil, `cannot use "1" as int`},
+		{NumberValue(1), new(string), nil, "cannot use 1 as string"},
+		{BoolValue(true), new([]int), nil, "cannot use true as []int"},
+		{host, new([]int), nil, "cannot use []string as []int"},
+	}
+	for _, tt := range tests {
+		err := FromValue(tt.v, tt.ptr)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				got := reflect.ValueOf(tt.ptr).Elem().Interface()
+				t.Errorf("FromValue(%v, %T): got %v (%v), want the error %q", tt.v.Quoted(), tt.ptr, got, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("FromValue(%v, %T): %v", tt.v.Quoted(), tt.ptr, err)
+			continue
+		}
+		if got := reflect.ValueOf(tt.ptr).Elem().Interface(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FromValue(%v, %T) = %#v, want %#v", tt.v.Quoted(), tt.ptr, got, tt.want)
+		}
+	}
+	if err := FromValue(NumberValue(1), 0); err == nil {
+		t.Error("FromValue into a non-pointer succeeded")
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	errOdd := errors.New("odd")
+	funcs := map[string]interface{}{
+		"add":   func(a, b int) int { return a + b },
+		"upper": strings.ToUpper,
+		"half": func(n uint8) (uint8, error) {
+			if n%2 == 1 {
+				return 0, errOdd
+			}
+			return n / 2, nil
+		},
+		"pair":  func(a, b string) []string { return []string{a, b} },
+		"first": func(p []string) string { return p[0] },
+		"none":  func() {},
+	}
+	tests := []struct {
+		src, want string
+	}{
+		{`print add(2, 3);`, "5\n"},
+		{`print upper("lox");`, "LOX\n"},
+		{`print half(8);`, "4\n"},
+		{`print first(pair("a", "b"));`, "a\n"},
+		{`print none();`, "nil\n"},
+		{`half(3);`, "[line 1] runtime error: native 'half' failed: odd"},
+		{`half(512);`, "[line 1] runtime error: native 'half' failed: argument 1: cannot use 512 as uint8"},
+		{`var m = 1000000; add(1, m * m * m * m * m);`, "[line 1] runtime error: native 'add' failed: argument 2: cannot use 1e+30 as int"},
+		{`add("1", 1);`, `[line 1] runtime error: native 'add' failed: argument 1: cannot use "1" as int`},
+		{`first(1);`, "[line 1] runtime error: native 'first' failed: argument 1: cannot use 1 as []string"},
+		{`add(1);`, "[line 1] runtime error: expected 2 arguments but got 1"},
+	}
+	for _, backend := range backends {
+		for _, tt := range tests {
+			var out bytes.Buffer
+			in := New(WithBackend(backend), WithStdout(&out))
+			for name, fn := range funcs {
+				if err := in.RegisterFunc(name, fn); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := in.Run(tt.src); err != nil {
+				out.WriteString(err.Error())
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("%v backend, %q:\ngot  %q\nwant %q", backend, tt.src, got, tt.want)
+			}
+		}
+	}
+
+	in := New()
+	for _, tt := range []struct {
+		name string
+		fn   interface{}
+		err  string
+	}{
+		{"1x", func() {}, "invalid native name"},
+		{"f", 42, "int is not a function"},
+		{"f", (func())(nil), "is not a function"},
+		{"f", func() (int, int) { return 0, 0 }, "cannot return 2 values"},
+	} {
+		if err := in.RegisterFunc(tt.name, tt.fn); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("RegisterFunc(%q, %T): got %v, want the error %q", tt.name, tt.fn, err, tt.err)
+		}
+	}
+}
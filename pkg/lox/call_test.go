@@ -0,0 +1,66 @@
+package lox
+
+import "testing"
+
+// TestCallSiteCache checks that a call site that remembers its last callee
+// still calls what the callee expression evaluates to each time.
+func TestCallSiteCache(t *testing.T) {
+	tests := []programTest{
+		// the global is redefined between two calls from one site
+		{`fun f() { return 1; } fun call() { return f(); } print call(); fun f() { return 2; } print call();`, "1\n2\n"},
+		// closures of one function with their own variables
+		{`fun make(n) { fun get() { return n; } return get; }
+		  var fs = make(1); var gs = make(2);
+		  fun call(g) { return g(); }
+		  print call(fs); print call(gs); print call(fs);`, "1\n2\n1\n"},
+		// a native and a function at the same site
+		{`fun call(g, x) { return g(x); } fun id(x) { return x; }
+		  print call(id, "a"); print call(toString, 1); print call(id, "b");`, "a\n1\nb\n"},
+		// the arity is checked again when the callee changes
+		{`fun one(a) { return a; } fun two(a, b) { return a; }
+		  fun call(g) { return g(1); } print call(one); print call(two);`,
+			"1\n[line 2] runtime error: expected 2 arguments but got 1\n  [line 2] in call()\n  [line 2] in script"},
+		// and what was callable may no longer be
+		{`fun f() { return 1; } fun call() { return f(); } print call(); f = 3; print call();`,
+			"1\n[line 1] runtime error: '3' is not a function or class\n  [line 1] in call()\n  [line 1] in script"},
+	}
+	testPrograms(t, tests)
+}
+
+// BenchmarkClosureCalls calls a closure from one call site.
+func BenchmarkClosureCalls(b *testing.B) {
+	benchmarkProgram(b, `
+fun makeCounter() {
+	var count = 0;
+	fun increment(by) {
+		count = count + by;
+		return count;
+	}
+	return increment;
+}
+var counter = makeCounter();
+var i = 0;
+while (i < 50000) {
+	counter(1);
+	i = i + 1;
+}
+print counter(0);
+`)
+}
+
+// BenchmarkGlobalCalls calls a global function and updates global
+// variables, which go through the cached cells of the globals.
+func BenchmarkGlobalCalls(b *testing.B) {
+	benchmarkProgram(b, `
+fun add(a, b) {
+	return a + b;
+}
+var sum = 0;
+var i = 0;
+while (i < 50000) {
+	sum = add(sum, i);
+	i = i + 1;
+}
+print sum;
+`)
+}
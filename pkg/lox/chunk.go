@@ -0,0 +1,213 @@
+package lox
+
+import (
+	"fmt"
+	"io"
+)
+
+// Bytecode
+//
+// The VM backend runs programs compiled to bytecode instead of walking the
+// AST. A chunk holds the code of one function: a stream of opcodes, each
+// followed by its operands, and the constants that the code refers to.
+// Operands are one byte for stack slots, upvalues and argument counts and
+// two bytes, big endian, for constants and jump offsets.
+
+type opcode byte
+
+const (
+	opConstant       opcode = iota // const16: push the constant
+	opNil                          // push nil
+	opTrue                         // push true
+	opFalse                        // push false
+	opUnassigned                   // push the value of a variable with no initializer
+	opPop                          // discard the top of the stack
+	opGetLocal                     // slot: push the local
+	opGetLocalSafe                 // slot, const16: push the local, fail if unassigned
+	opSetLocal                     // slot: store the top of the stack in the local
+	opGetUpvalue                   // index: push the captured variable
+	opGetUpvalueSafe               // index, const16: push the captured variable, fail if unassigned
+	opSetUpvalue                   // index: store the top of the stack in the captured variable
+	opGetGlobal                    // const16: push the global named by the constant
+	opDefineGlobal                 // const16: pop into the global named by the constant
+	opSetGlobal                    // const16: store the top of the stack in an existing global
+	opEqual
+	opGreater
+	opGreaterEqual
+	opLess
+	opLessEqual
+	opAdd
+	opSubtract
+	opMultiply
+	opDivide
+	opNot
+	opNegate
+	opPrint
+	opJump        // offset16: jump forward
+	opJumpIfFalse // offset16: jump forward if the top of the stack is falsey
+	opLoop        // offset16: jump backward
+	opCall        // argc, site16: call the value below the arguments
+	opClosure     // fn16, then isLocal and index for every upvalue
+	opCloseUpvalue
+	opReturn
+	opStatement // call the OnStatement hook, only compiled in with hooks
+)
+
+var opcodeNames = [...]string{
+	opConstant:       "CONSTANT",
+	opNil:            "NIL",
+	opTrue:           "TRUE",
+	opFalse:          "FALSE",
+	opUnassigned:     "UNASSIGNED",
+	opPop:            "POP",
+	opGetLocal:       "GET_LOCAL",
+	opGetLocalSafe:   "GET_LOCAL_SAFE",
+	opSetLocal:       "SET_LOCAL",
+	opGetUpvalue:     "GET_UPVALUE",
+	opGetUpvalueSafe: "GET_UPVALUE_SAFE",
+	opSetUpvalue:     "SET_UPVALUE",
+	opGetGlobal:      "GET_GLOBAL",
+	opDefineGlobal:   "DEFINE_GLOBAL",
+	opSetGlobal:      "SET_GLOBAL",
+	opEqual:          "EQUAL",
+	opGreater:        "GREATER",
+	opGreaterEqual:   "GREATER_EQUAL",
+	opLess:           "LESS",
+	opLessEqual:      "LESS_EQUAL",
+	opAdd:            "ADD",
+	opSubtract:       "SUBTRACT",
+	opMultiply:       "MULTIPLY",
+	opDivide:         "DIVIDE",
+	opNot:            "NOT",
+	opNegate:         "NEGATE",
+	opPrint:          "PRINT",
+	opJump:           "JUMP",
+	opJumpIfFalse:    "JUMP_IF_FALSE",
+	opLoop:           "LOOP",
+	opCall:           "CALL",
+	opClosure:        "CLOSURE",
+	opCloseUpvalue:   "CLOSE_UPVALUE",
+	opReturn:         "RETURN",
+	opStatement:      "STATEMENT",
+}
+
+func (op opcode) String() string {
+	if int(op) < len(opcodeNames) {
+		return opcodeNames[op]
+	}
+	return fmt.Sprintf("opcode(%d)", op)
+}
+
+type chunk struct {
+	code      []byte
+	lines     []int // source line of every byte of code
+	constants []Value
+	strings   map[string]int // index of the string constants
+	functions []*function    // nested functions that opClosure makes closures of
+
+	// cells of the globals named by the constants, filled in by the VM as
+	// the code runs
+	cells []*globalCell
+
+	calls []callCache // one for every call instruction
+
+	// names of the locals in the slots after the function at every
+	// opStatement, by its offset, for Hooks.OnScope
+	locals map[int][]string
+}
+
+// callCache remembers what the last call at a call instruction called. If
+// the next call there is to a closure of the same function or to the same
+// native, the callee was already checked and the VM can call it right away.
+type callCache struct {
+	fn     *function
+	native *nativeFn
+}
+
+func (c *chunk) write(b byte, line int) {
+	c.code = append(c.code, b)
+	c.lines = append(c.lines, line)
+}
+
+// addConstant returns the index of v among the constants. Strings, which
+// are mostly the names of globals, are only added once.
+func (c *chunk) addConstant(v Value) int {
+	if v.kind == strKind {
+		if i, ok := c.strings[v.asString()]; ok {
+			return i
+		}
+		if c.strings == nil {
+			c.strings = make(map[string]int)
+		}
+		c.strings[v.asString()] = len(c.constants)
+	}
+	c.constants = append(c.constants, v)
+	return len(c.constants) - 1
+}
+
+func (c *chunk) uint16At(offset int) int {
+	return int(c.code[offset])<<8 | int(c.code[offset+1])
+}
+
+// disassemble writes a listing of the code of fn and the functions nested in
+// it to w.
+func disassemble(w io.Writer, fn *function) {
+	fmt.Fprintf(w, "== %v ==\n", fn)
+	c := &fn.chunk
+	for offset := 0; offset < len(c.code); {
+		offset = c.disassembleAt(w, offset)
+	}
+	for _, nested := range c.functions {
+		disassemble(w, nested)
+	}
+}
+
+// disassembleAt writes the instruction at offset to w and returns the offset
+// of the next one.
+func (c *chunk) disassembleAt(w io.Writer, offset int) int {
+	fmt.Fprintf(w, "%04d ", offset)
+	if offset > 0 && c.lines[offset] == c.lines[offset-1] {
+		fmt.Fprint(w, "   | ")
+	} else {
+		fmt.Fprintf(w, "%4d ", c.lines[offset])
+	}
+	op := opcode(c.code[offset])
+	switch op {
+	case opConstant, opGetGlobal, opDefineGlobal, opSetGlobal:
+		k := c.uint16At(offset + 1)
+		fmt.Fprintf(w, "%-16v %4d '%v'\n", op, k, c.constants[k])
+		return offset + 3
+	case opGetLocal, opSetLocal, opGetUpvalue, opSetUpvalue:
+		fmt.Fprintf(w, "%-16v %4d\n", op, c.code[offset+1])
+		return offset + 2
+	case opCall:
+		fmt.Fprintf(w, "%-16v %4d site %d\n", op, c.code[offset+1], c.uint16At(offset+2))
+		return offset + 4
+	case opGetLocalSafe, opGetUpvalueSafe:
+		k := c.uint16At(offset + 2)
+		fmt.Fprintf(w, "%-16v %4d '%v'\n", op, c.code[offset+1], c.constants[k])
+		return offset + 4
+	case opJump, opJumpIfFalse:
+		fmt.Fprintf(w, "%-16v %4d -> %d\n", op, offset, offset+3+c.uint16At(offset+1))
+		return offset + 3
+	case opLoop:
+		fmt.Fprintf(w, "%-16v %4d -> %d\n", op, offset, offset+3-c.uint16At(offset+1))
+		return offset + 3
+	case opClosure:
+		k := c.uint16At(offset + 1)
+		fn := c.functions[k]
+		fmt.Fprintf(w, "%-16v %4d %v\n", op, k, fn)
+		offset += 3
+		for i := 0; i < fn.upvalues; i++ {
+			kind := "upvalue"
+			if c.code[offset] == 1 {
+				kind = "local"
+			}
+			fmt.Fprintf(w, "%04d    |                     %v %d\n", offset, kind, c.code[offset+1])
+			offset += 2
+		}
+		return offset
+	}
+	fmt.Fprintf(w, "%v\n", op)
+	return offset + 1
+}
@@ -0,0 +1,554 @@
+package lox
+
+import (
+	"fmt"
+	"math"
+)
+
+// Compiler
+//
+// The compiler turns the AST into bytecode for the VM. It does its own
+// scoping instead of using the slots of the resolver: locals live on the VM
+// stack, in the order they are declared, and the locals of enclosing
+// functions that a function uses become its upvalues, like in clox.
+//
+// The scoping rules are those of the tree-walker, which stays the reference
+// implementation: the initializer of a local sees the outer variable of the
+// same name, a function sees itself, and declaring a name again in the same
+// scope reuses the variable.
+
+// function is a compiled Lox function, the closures made of it at run time
+// share its code.
+type function struct {
+	name     string // empty for anonymous functions
+	params   []string
+	upvalues int
+	chunk    chunk
+	script   bool // the top-level code
+}
+
+func (f *function) String() string {
+	switch {
+	case f.script:
+		return "<script>"
+	case f.name == "":
+		return fmt.Sprintf("<lambda (%v)>", joinNames(f.params))
+	}
+	return fmt.Sprintf("<fn %v>", f.name)
+}
+
+func joinNames(names []string) string {
+	s := ""
+	for i, n := range names {
+		if i > 0 {
+			s += ","
+		}
+		s += n
+	}
+	return s
+}
+
+type local struct {
+	name     string
+	depth    int
+	captured bool // by a closure, so it must be moved off the stack
+	checked  bool // declared without an initializer, reads must check it
+}
+
+type upvalueRef struct {
+	index   int  // of the local in the enclosing function or of its upvalue
+	isLocal bool // index is a local slot of the enclosing function
+	checked bool
+}
+
+type loopInfo struct {
+	start  int // offset of the condition
+	depth  int // scope depth of the loop
+	breaks []int
+}
+
+type compiler struct {
+	enclosing *compiler
+	fn        *function
+	locals    []local
+	upvalues  []upvalueRef
+	depth     int // of the current scope, 0 is the global scope
+	loops     []*loopInfo
+	line      int
+	errs      *[]error // shared by the compilers of nested functions
+	hooks     bool     // emit opStatement for the OnStatement and OnScope hooks
+}
+
+// compile compiles a resolved program into the function that runs its
+// top-level code, with the instructions for the hooks if hooks is set.
+func compile(stmts []Stmt, hooks bool) (*function, []error) {
+	var errs []error
+	c := newCompiler(nil, &function{script: true}, &errs)
+	c.hooks = hooks
+	// The script returns the value of its last statement if that is an
+	// expression.
+	if n := len(stmts); n > 0 {
+		if e, ok := stmts[n-1].(*ExprStmt); ok {
+			c.stmts(stmts[:n-1])
+			c.statement(e)
+			c.expr(e.expression)
+			c.emit(opReturn)
+			return c.fn, errs
+		}
+	}
+	c.stmts(stmts)
+	c.emit(opNil)
+	c.emit(opReturn)
+	return c.fn, errs
+}
+
+func newCompiler(enclosing *compiler, fn *function, errs *[]error) *compiler {
+	c := &compiler{enclosing: enclosing, fn: fn, errs: errs}
+	if enclosing != nil {
+		c.line = enclosing.line
+		c.hooks = enclosing.hooks
+	}
+	// slot 0 holds the function being called
+	c.locals = append(c.locals, local{name: "", depth: 0})
+	return c
+}
+
+func (c *compiler) error(msg string) {
+	*c.errs = append(*c.errs, ParsingError(errorAt(c.line, "", msg)))
+}
+
+func (c *compiler) at(t *Token) {
+	if t != nil {
+		c.line = t.line
+	}
+}
+
+// ------------------------------------------
+// emitting code
+
+func (c *compiler) chunk() *chunk {
+	return &c.fn.chunk
+}
+
+func (c *compiler) emit(op opcode, operands ...byte) {
+	c.chunk().write(byte(op), c.line)
+	for _, b := range operands {
+		c.chunk().write(b, c.line)
+	}
+}
+
+func (c *compiler) emitShort(op opcode, n int) {
+	c.emit(op, byte(n>>8), byte(n))
+}
+
+func (c *compiler) constant(v Value) int {
+	k := c.chunk().addConstant(v)
+	if k > math.MaxUint16 {
+		c.error("too many constants in one function")
+		return 0
+	}
+	return k
+}
+
+func (c *compiler) name(s string) int {
+	return c.constant(StringValue(s))
+}
+
+// emitJump emits a forward jump and returns the offset of its operand for
+// patchJump.
+func (c *compiler) emitJump(op opcode) int {
+	c.emit(op, 0xff, 0xff)
+	return len(c.chunk().code) - 2
+}
+
+// patchJump makes the jump at offset land at the current end of the code.
+func (c *compiler) patchJump(offset int) {
+	jump := len(c.chunk().code) - offset - 2
+	if jump > math.MaxUint16 {
+		c.error("too much code to jump over")
+	}
+	c.chunk().code[offset] = byte(jump >> 8)
+	c.chunk().code[offset+1] = byte(jump)
+}
+
+func (c *compiler) emitLoop(start int) {
+	jump := len(c.chunk().code) + 3 - start
+	if jump > math.MaxUint16 {
+		c.error("loop body too large")
+	}
+	c.emitShort(opLoop, jump)
+}
+
+// ------------------------------------------
+// scopes
+
+func (c *compiler) beginScope() {
+	c.depth++
+}
+
+func (c *compiler) endScope() {
+	c.depth--
+	n := len(c.locals)
+	for n > 0 && c.locals[n-1].depth > c.depth {
+		c.popLocal(c.locals[n-1])
+		n--
+	}
+	c.locals = c.locals[:n]
+}
+
+// popLocal emits the code that discards a local going out of scope.
+func (c *compiler) popLocal(l local) {
+	if l.captured {
+		c.emit(opCloseUpvalue)
+	} else {
+		c.emit(opPop)
+	}
+}
+
+// inScope returns the slot of name if it is declared in the current scope,
+// or -1.
+func (c *compiler) inScope(name string) int {
+	for i := len(c.locals) - 1; i > 0 && c.locals[i].depth == c.depth; i-- {
+		if c.locals[i].name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// addLocal declares a new local in the current scope, its slot is the one
+// the next value pushed on the stack lands in.
+func (c *compiler) addLocal(name string, checked bool) {
+	if len(c.locals) > math.MaxUint8 {
+		c.error("too many local variables in function")
+		return
+	}
+	c.locals = append(c.locals, local{name: name, depth: c.depth, checked: checked})
+}
+
+// defineLocal makes the value on top of the stack the local name. Declaring
+// a name again in the same scope stores the value in the existing variable.
+func (c *compiler) defineLocal(name string, checked bool) {
+	if i := c.inScope(name); i >= 0 {
+		c.locals[i].checked = c.locals[i].checked || checked
+		c.emit(opSetLocal, byte(i))
+		c.emit(opPop)
+		return
+	}
+	c.addLocal(name, checked)
+}
+
+func (c *compiler) resolveLocal(name string) int {
+	for i := len(c.locals) - 1; i > 0; i-- {
+		if c.locals[i].name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *compiler) resolveUpvalue(name string) int {
+	if c.enclosing == nil {
+		return -1
+	}
+	if i := c.enclosing.resolveLocal(name); i >= 0 {
+		l := &c.enclosing.locals[i]
+		l.captured = true
+		return c.addUpvalue(upvalueRef{index: i, isLocal: true, checked: l.checked})
+	}
+	if i := c.enclosing.resolveUpvalue(name); i >= 0 {
+		return c.addUpvalue(upvalueRef{index: i, checked: c.enclosing.upvalues[i].checked})
+	}
+	return -1
+}
+
+func (c *compiler) addUpvalue(u upvalueRef) int {
+	for i, v := range c.upvalues {
+		if v.index == u.index && v.isLocal == u.isLocal {
+			return i
+		}
+	}
+	if len(c.upvalues) > math.MaxUint8 {
+		c.error("too many closure variables in function")
+		return 0
+	}
+	c.upvalues = append(c.upvalues, u)
+	c.fn.upvalues = len(c.upvalues)
+	return len(c.upvalues) - 1
+}
+
+// ------------------------------------------
+// statements
+
+func (c *compiler) stmts(list []Stmt) {
+	for _, s := range list {
+		c.stmt(s)
+	}
+}
+
+// statement marks the start of s for the OnStatement and OnScope hooks.
+func (c *compiler) statement(s Stmt) {
+	if first, _ := s.span(); first != nil {
+		c.at(first)
+	}
+	if c.hooks && traced(s) {
+		names := make([]string, len(c.locals)-1)
+		for i, l := range c.locals[1:] {
+			names[i] = l.name
+		}
+		if c.chunk().locals == nil {
+			c.chunk().locals = make(map[int][]string)
+		}
+		c.chunk().locals[len(c.chunk().code)] = names
+		c.emit(opStatement)
+	}
+}
+
+func (c *compiler) stmt(s Stmt) {
+	c.statement(s)
+	switch s := s.(type) {
+	case *BlockStmt:
+		c.beginScope()
+		c.stmts(s.list)
+		c.endScope()
+	case *BreakStmt:
+		c.at(s.keyword)
+		loop := c.loops[len(c.loops)-1]
+		c.popLocalsOf(loop)
+		loop.breaks = append(loop.breaks, c.emitJump(opJump))
+	case *ContinueStmt:
+		c.at(s.keyword)
+		loop := c.loops[len(c.loops)-1]
+		c.popLocalsOf(loop)
+		c.emitLoop(loop.start)
+	case *ExprStmt:
+		c.expr(s.expression)
+		c.emit(opPop)
+	case *FunStmt:
+		c.at(s.name)
+		if c.depth > 0 {
+			if i := c.inScope(s.name.lexeme); i >= 0 {
+				c.function(s.name.lexeme, s.params, s.body)
+				c.emit(opSetLocal, byte(i))
+				c.emit(opPop)
+				return
+			}
+			// declared first, so that the function can refer to itself
+			c.addLocal(s.name.lexeme, false)
+			c.function(s.name.lexeme, s.params, s.body)
+			return
+		}
+		c.function(s.name.lexeme, s.params, s.body)
+		c.emitShort(opDefineGlobal, c.name(s.name.lexeme))
+	case *IfStmt:
+		c.expr(s.condition)
+		then := c.emitJump(opJumpIfFalse)
+		c.emit(opPop)
+		c.stmt(s.block1)
+		end := c.emitJump(opJump)
+		c.patchJump(then)
+		c.emit(opPop)
+		if s.block2 != nil {
+			c.stmt(s.block2)
+		}
+		c.patchJump(end)
+	case *PrintStmt:
+		c.expr(s.expression)
+		c.emit(opPrint)
+	case *ReturnStmt:
+		if s.value != nil {
+			c.expr(s.value)
+		} else {
+			c.emit(opNil)
+		}
+		c.at(s.keyword)
+		c.emit(opReturn)
+	case *VarStmt:
+		if s.init != nil {
+			c.expr(s.init)
+		} else {
+			c.emit(opUnassigned)
+		}
+		c.at(s.name)
+		if c.depth > 0 {
+			c.defineLocal(s.name.lexeme, s.init == nil)
+			return
+		}
+		c.emitShort(opDefineGlobal, c.name(s.name.lexeme))
+	case *WhileStmt:
+		loop := &loopInfo{start: len(c.chunk().code), depth: c.depth}
+		c.expr(s.condition)
+		exit := c.emitJump(opJumpIfFalse)
+		c.emit(opPop)
+		c.loops = append(c.loops, loop)
+		c.stmt(s.body)
+		c.loops = c.loops[:len(c.loops)-1]
+		c.at(s.keyword)
+		c.emitLoop(loop.start)
+		c.patchJump(exit)
+		c.emit(opPop)
+		for _, b := range loop.breaks {
+			c.patchJump(b)
+		}
+	}
+}
+
+// popLocalsOf emits the code that discards the locals declared inside of
+// loop, for jumping out of its body.
+func (c *compiler) popLocalsOf(loop *loopInfo) {
+	for i := len(c.locals) - 1; i > 0 && c.locals[i].depth > loop.depth; i-- {
+		c.popLocal(c.locals[i])
+	}
+}
+
+// function compiles a function and emits the code that makes a closure of
+// it.
+func (c *compiler) function(name string, params []*Token, body []Stmt) {
+	fn := &function{name: name}
+	for _, p := range params {
+		fn.params = append(fn.params, p.lexeme)
+	}
+	sub := newCompiler(c, fn, c.errs)
+	sub.beginScope()
+	for _, p := range params {
+		// every parameter gets a slot, even if the name repeats
+		sub.addLocal(p.lexeme, false)
+	}
+	sub.stmts(body)
+	sub.emit(opNil)
+	sub.emit(opReturn)
+
+	c.chunk().functions = append(c.chunk().functions, fn)
+	k := len(c.chunk().functions) - 1
+	if k > math.MaxUint16 {
+		c.error("too many functions in one function")
+	}
+	c.emitShort(opClosure, k)
+	for _, u := range sub.upvalues {
+		isLocal := byte(0)
+		if u.isLocal {
+			isLocal = 1
+		}
+		c.chunk().write(isLocal, c.line)
+		c.chunk().write(byte(u.index), c.line)
+	}
+}
+
+// ------------------------------------------
+// expressions
+
+func (c *compiler) expr(e Expr) {
+	switch e := e.(type) {
+	case *AssignExpr:
+		c.expr(e.value)
+		c.at(e.name)
+		name := e.name.lexeme
+		if i := c.resolveLocal(name); i >= 0 {
+			c.emit(opSetLocal, byte(i))
+		} else if i := c.resolveUpvalue(name); i >= 0 {
+			c.emit(opSetUpvalue, byte(i))
+		} else {
+			c.emitShort(opSetGlobal, c.name(name))
+		}
+	case *BinaryExpr:
+		c.expr(e.left)
+		c.expr(e.right)
+		c.at(e.operator)
+		switch e.operator.tok {
+		case Plus:
+			c.emit(opAdd)
+		case Minus:
+			c.emit(opSubtract)
+		case Star:
+			c.emit(opMultiply)
+		case Slash:
+			c.emit(opDivide)
+		case Greater:
+			c.emit(opGreater)
+		case GreaterEqual:
+			c.emit(opGreaterEqual)
+		case Less:
+			c.emit(opLess)
+		case LessEqual:
+			c.emit(opLessEqual)
+		case EqualEqual:
+			c.emit(opEqual)
+		case BangEqual:
+			c.emit(opEqual)
+			c.emit(opNot)
+		}
+	case *CallExpr:
+		c.expr(e.callee)
+		for _, a := range e.args {
+			c.expr(a)
+		}
+		c.at(e.paren)
+		site := len(c.chunk().calls)
+		if site > math.MaxUint16 {
+			c.error("too many calls in one function")
+		}
+		c.chunk().calls = append(c.chunk().calls, callCache{})
+		c.emit(opCall, byte(len(e.args)), byte(site>>8), byte(site))
+	case *FunExpr:
+		c.function("", e.params, e.body)
+	case *GroupingExpr:
+		c.expr(e.e)
+	case *LiteralExpr:
+		switch e.value.kind {
+		case nilKind:
+			c.emit(opNil)
+		case boolKind:
+			if e.value.asBool() {
+				c.emit(opTrue)
+			} else {
+				c.emit(opFalse)
+			}
+		default:
+			c.emitShort(opConstant, c.constant(e.value))
+		}
+	case *LogicalExpr:
+		c.expr(e.left)
+		c.at(e.operator)
+		if e.operator.tok == And {
+			end := c.emitJump(opJumpIfFalse)
+			c.emit(opPop)
+			c.expr(e.right)
+			c.patchJump(end)
+			return
+		}
+		right := c.emitJump(opJumpIfFalse)
+		end := c.emitJump(opJump)
+		c.patchJump(right)
+		c.emit(opPop)
+		c.expr(e.right)
+		c.patchJump(end)
+	case *UnaryExpr:
+		c.expr(e.right)
+		c.at(e.operator)
+		if e.operator.tok == Minus {
+			c.emit(opNegate)
+		} else {
+			c.emit(opNot)
+		}
+	case *VarExpr:
+		c.at(e.name)
+		name := e.name.lexeme
+		if i := c.resolveLocal(name); i >= 0 {
+			if c.locals[i].checked {
+				k := c.name(name)
+				c.emit(opGetLocalSafe, byte(i), byte(k>>8), byte(k))
+			} else {
+				c.emit(opGetLocal, byte(i))
+			}
+		} else if i := c.resolveUpvalue(name); i >= 0 {
+			if c.upvalues[i].checked {
+				k := c.name(name)
+				c.emit(opGetUpvalueSafe, byte(i), byte(k>>8), byte(k))
+			} else {
+				c.emit(opGetUpvalue, byte(i))
+			}
+		} else {
+			c.emitShort(opGetGlobal, c.name(name))
+		}
+	}
+}
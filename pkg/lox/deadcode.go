@@ -0,0 +1,190 @@
+package lox
+
+import "sort"
+
+// Finding is a piece of code that can never run.
+type Finding struct {
+	At  *Token // where the code starts
+	Msg string
+}
+
+type deadcodeFinder struct {
+	table    *SymbolTable
+	calls    map[*Symbol][]*Symbol // names used in the body of a function
+	roots    []*Symbol             // names used by the top-level code
+	owner    *Symbol               // function whose body is being walked
+	findings []Finding
+}
+
+// DeadCode returns the code in stmts that can never run in source order.
+// The table is the result of resolving stmts.
+func DeadCode(stmts []Stmt, table *SymbolTable) []Finding {
+	d := &deadcodeFinder{table: table, calls: make(map[*Symbol][]*Symbol)}
+	d.stmts(stmts)
+
+	reached := make(map[*Symbol]bool)
+	queue := d.roots
+	for len(queue) > 0 {
+		sym := queue[0]
+		queue = queue[1:]
+		if !reached[sym] {
+			reached[sym] = true
+			queue = append(queue, d.calls[sym]...)
+		}
+	}
+	for _, sym := range table.symbols {
+		if sym.kind == FunSymbol && !reached[sym] {
+			d.report(sym.decl, "function '"+sym.name+"' is never called")
+		}
+	}
+
+	sort.Slice(d.findings, func(i, j int) bool {
+		return d.findings[i].At.pos < d.findings[j].At.pos
+	})
+	return d.findings
+}
+
+func (d *deadcodeFinder) report(at *Token, msg string) {
+	if at == nil {
+		return
+	}
+	d.findings = append(d.findings, Finding{at, msg})
+}
+
+func (d *deadcodeFinder) use(name *Token) {
+	sym := d.table.Lookup(name)
+	if sym == nil {
+		return
+	}
+	if d.owner == nil {
+		d.roots = append(d.roots, sym)
+	} else {
+		d.calls[d.owner] = append(d.calls[d.owner], sym)
+	}
+}
+
+// stmts walks a list of statements and reports the ones that follow a jump.
+func (d *deadcodeFinder) stmts(list []Stmt) {
+	for i, s := range list {
+		d.stmt(s)
+		var jump *Token
+		switch s := s.(type) {
+		case *ReturnStmt:
+			jump = s.keyword
+		case *BreakStmt:
+			jump = s.keyword
+		case *ContinueStmt:
+			jump = s.keyword
+		}
+		if jump != nil && i+1 < len(list) {
+			d.report(firstToken(list[i+1]), "unreachable code after '"+jump.lexeme+"'")
+			return
+		}
+	}
+}
+
+func (d *deadcodeFinder) stmt(s Stmt) {
+	switch s := s.(type) {
+	case *BlockStmt:
+		d.stmts(s.list)
+	case *ExprStmt:
+		d.expr(s.expression)
+	case *FunStmt:
+		owner := d.owner
+		d.owner = d.table.Lookup(s.name)
+		d.stmts(s.body)
+		d.owner = owner
+	case *IfStmt:
+		d.expr(s.condition)
+		v, ok := constCondition(s.condition)
+		if ok && !v {
+			d.report(firstToken(s.block1), "then branch never runs, the condition is always false")
+		} else {
+			d.stmt(s.block1)
+		}
+		if s.block2 == nil {
+			return
+		}
+		if ok && v {
+			d.report(firstToken(s.block2), "else branch never runs, the condition is always true")
+		} else {
+			d.stmt(s.block2)
+		}
+	case *PrintStmt:
+		d.expr(s.expression)
+	case *ReturnStmt:
+		if s.value != nil {
+			d.expr(s.value)
+		}
+	case *VarStmt:
+		if s.init != nil {
+			d.expr(s.init)
+		}
+	case *WhileStmt:
+		d.expr(s.condition)
+		if v, ok := constCondition(s.condition); ok && !v {
+			d.report(firstToken(s.body), "loop body never runs, the condition is always false")
+			return
+		}
+		d.stmt(s.body)
+	}
+}
+
+func (d *deadcodeFinder) expr(e Expr) {
+	switch e := e.(type) {
+	case *AssignExpr:
+		d.expr(e.value)
+		d.use(e.name)
+	case *BinaryExpr:
+		d.expr(e.left)
+		d.expr(e.right)
+	case *CallExpr:
+		d.expr(e.callee)
+		for _, a := range e.args {
+			d.expr(a)
+		}
+	case *FunExpr:
+		// the body of a lambda runs on behalf of whoever creates it
+		d.stmts(e.body)
+	case *GroupingExpr:
+		d.expr(e.e)
+	case *LogicalExpr:
+		d.expr(e.left)
+		d.expr(e.right)
+	case *UnaryExpr:
+		d.expr(e.right)
+	case *VarExpr:
+		d.use(e.name)
+	}
+}
+
+// constCondition returns the truthiness of a condition made of literals
+// only.
+func constCondition(e Expr) (truthy bool, ok bool) {
+	switch e := e.(type) {
+	case *LiteralExpr:
+		return isTruthy(e.value), true
+	case *GroupingExpr:
+		return constCondition(e.e)
+	case *UnaryExpr:
+		if e.operator.tok == Bang {
+			v, ok := constCondition(e.right)
+			return !v, ok
+		}
+	}
+	return false, false
+}
+
+// firstToken returns the first token of s, for statements made up while
+// desugaring it is the first one of the code they were made of.
+func firstToken(s Stmt) *Token {
+	if first, _ := s.span(); first != nil {
+		return first
+	}
+	for _, c := range children(s) {
+		if t := firstToken(c); t != nil {
+			return t
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package lox
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDeadCode(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"fun used() {}\nfun unused() {}\nused();", "2:5: function 'unused' is never called\n"},
+		// calling each other does not make them used
+		{"fun ping(n) { if (n > 0) pong(n - 1); }\nfun pong(n) { ping(n); }", "1:5: function 'ping' is never called\n2:5: function 'pong' is never called\n"},
+		{"fun ping(n) { if (n > 0) pong(n - 1); }\nfun pong(n) { ping(n); }\nping(3);", ""},
+		{"fun a() { b(); }\nfun b() {}\na();", ""},
+		{"if (false) print 1; else print 2;", "1:12: then branch never runs, the condition is always false\n"},
+		{"if (nil) { print 1; }", "1:10: then branch never runs, the condition is always false\n"},
+		{"while (false) print 1;", "1:15: loop body never runs, the condition is always false\n"},
+		{"fun f() {\n  return 1;\n  print 2;\n}\nf();", "3:3: unreachable code after 'return'\n"},
+		{"while (true) { break; print 1; }", "1:23: unreachable code after 'break'\n"},
+		{"var x = 1; if (x) print x;", ""},
+	}
+	for _, tt := range tests {
+		stmts := parseProgram(t, tt.src)
+		var b strings.Builder
+		for _, f := range DeadCode(stmts, Resolve(stmts)) {
+			fmt.Fprintf(&b, "%v:%v: %v\n", f.At.Line(), f.At.Col(), f.Msg)
+		}
+		if got := b.String(); got != tt.want {
+			t.Errorf("%q:\ngot  %q\nwant %q", tt.src, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package lox
+
+// The nodes are declared in ast.go, which genast writes from ast.def with
+// their constructors, the walk of their children and their JSON form.
+
+//go:generate go run ../../cmd/genast -o ast.go ast.def
+
+// Node is an expression or a statement, see Walk.
+type Node interface {
+	aNode()
+}
+
+type (
+	Expr interface {
+		Node
+		aExpr()
+		eval(*Env) Value
+	}
+
+	expr struct{}
+
+	// binding is where the resolver found a variable: the slot in the
+	// environment depth scopes out, or a global if the slot is negative.
+	binding struct {
+		depth, slot int
+		global      *globalCell // cached by the interpreter
+	}
+)
+
+func (*expr) aNode()          {}
+func (*expr) aExpr()          {}
+func (*expr) eval(*Env) Value { return nilValue }
+
+type (
+	Stmt interface {
+		Node
+		aStmt()
+		execute(*Env)
+		span() (first, last *Token)
+		setSpan(first, last *Token)
+	}
+
+	stmt struct {
+		// source span set by the parser, nil for the statements that are
+		// made up while desugaring
+		first, last *Token
+	}
+)
+
+func (*stmt) aNode()       {}
+func (*stmt) aStmt()       {}
+func (*stmt) execute(*Env) {}
+
+func (s *stmt) span() (first, last *Token) {
+	return s.first, s.last
+}
+
+func (s *stmt) setSpan(first, last *Token) {
+	s.first, s.last = first, last
+}
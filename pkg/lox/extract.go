@@ -0,0 +1,217 @@
+package lox
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Extract returns source with the statements on lines start to end moved
+// into a new function called name.
+func Extract(source string, start, end int, name string) (string, error) {
+	if !isIdentifier(name) {
+		return "", fmt.Errorf("'%v' is not a valid identifier", name)
+	}
+	tokens, err := NewScanner(source).Scan()
+	if err != nil {
+		return "", err
+	}
+	stmts, errs := NewParser(tokens).Parse()
+	if len(errs) > 0 {
+		return "", errs[0]
+	}
+	table := Resolve(stmts)
+	for _, sym := range table.symbols {
+		if sym.name == name {
+			return "", fmt.Errorf("'%v' is already used in the program", name)
+		}
+	}
+
+	sel, top, err := selectStmts(stmts, start, end)
+	if err != nil {
+		return "", err
+	}
+	first, _ := sel[0].span()
+	_, last := sel[len(sel)-1].span()
+	inside := func(t *Token) bool {
+		return first.pos <= t.pos && t.pos <= last.pos
+	}
+
+	// The selection is cut out as whole lines, nothing else may share them.
+	for _, t := range tokens {
+		if t.tok != EOF && !inside(t) && first.line <= t.line && t.line <= last.line {
+			return "", fmt.Errorf("line %v has code outside of the selection", t.line)
+		}
+	}
+
+	for _, s := range sel {
+		if t := escapes(s, 0); t != nil {
+			return "", fmt.Errorf("[line %v] '%v' cannot leave the extracted function", t.line, t.lexeme)
+		}
+	}
+
+	var params []string
+	seen := make(map[*Symbol]bool)
+	for i, t := range tokens {
+		if t.tok != Identifier || !inside(t) {
+			continue
+		}
+		sym := table.Lookup(t)
+		if sym == nil || sym.decl == nil {
+			continue
+		}
+		if inside(sym.decl) {
+			for _, r := range sym.refs {
+				if !inside(r) {
+					return "", fmt.Errorf("[line %v] '%v' is declared in the selection but used after it", r.line, r.lexeme)
+				}
+			}
+			continue
+		}
+		if sym.global {
+			continue
+		}
+		if tokens[i+1].tok == Equal {
+			return "", fmt.Errorf("[line %v] the selection assigns to '%v' that is declared outside of it", t.line, t.lexeme)
+		}
+		if !seen[sym] {
+			seen[sym] = true
+			params = append(params, sym.name)
+		}
+	}
+
+	// Cut the selected lines, put the call in their place and the function
+	// in front of the top-level statement that contains the selection.
+	from := lineStart(source, first.pos)
+	to := lineEnd(source, last.pos)
+	lines := strings.SplitAfter(strings.TrimSuffix(source[from:to], "\n"), "\n")
+	indent := leadingSpace(lines[0])
+	for _, l := range lines {
+		if strings.TrimSpace(l) != "" && len(leadingSpace(l)) < len(indent) {
+			indent = leadingSpace(l)
+		}
+	}
+	var fn strings.Builder
+	fmt.Fprintf(&fn, "fun %v(%v) {\n", name, strings.Join(params, ", "))
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			fn.WriteString("\n")
+			continue
+		}
+		fn.WriteString("  " + strings.TrimPrefix(l, indent))
+	}
+	fn.WriteString("\n}\n\n")
+	call := fmt.Sprintf("%v%v(%v);\n", leadingSpace(lines[0]), name, strings.Join(params, ", "))
+	if to == len(source) && !strings.HasSuffix(source, "\n") {
+		call = strings.TrimSuffix(call, "\n")
+	}
+
+	topFirst, _ := top.span()
+	at := lineStart(source, topFirst.pos)
+	return source[:at] + fn.String() + source[at:from] + call + source[to:], nil
+}
+
+// selectStmts finds the innermost statement list with statements on the
+// lines start to end and returns them, together with the top-level
+// statement they belong to.
+func selectStmts(stmts []Stmt, start, end int) (sel []Stmt, top Stmt, err error) {
+	list := stmts
+	for {
+		var inner []Stmt
+		for _, s := range list {
+			first, last := s.span()
+			switch {
+			case first == nil:
+				// made up by desugaring, look into it instead
+				inner = append(inner, children(s)...)
+			case last.line < start || first.line > end:
+			case start <= first.line && last.line <= end:
+				sel = append(sel, s)
+			case first.line <= start && end <= last.line:
+				inner = append(inner, children(s)...)
+			default:
+				return nil, nil, fmt.Errorf("lines %v-%v do not cover whole statements", start, end)
+			}
+		}
+		if len(sel) > 0 {
+			return sel, topOf(stmts, sel[0]), nil
+		}
+		if len(inner) == 0 {
+			return nil, nil, fmt.Errorf("no statements on lines %v-%v", start, end)
+		}
+		list = inner
+	}
+}
+
+// topOf returns the statement in stmts that contains s.
+func topOf(stmts []Stmt, s Stmt) Stmt {
+	first, _ := s.span()
+	for _, t := range stmts {
+		a, b := t.span()
+		if a != nil && a.pos <= first.pos && first.pos <= b.pos {
+			return t
+		}
+	}
+	return s
+}
+
+// children returns the statements directly nested in s, including the
+// bodies of anonymous functions in its expressions.
+func children(s Stmt) []Stmt {
+	var list []Stmt
+	Inspect(s, func(n Node) bool {
+		if c, ok := n.(Stmt); ok && c != s {
+			list = append(list, c)
+			return false
+		}
+		return true
+	})
+	return list
+}
+
+// escapes returns the keyword of a return, break or continue in s that
+// would jump out of s, loops is the number of loops around s.
+func escapes(s Stmt, loops int) *Token {
+	switch s := s.(type) {
+	case *ReturnStmt:
+		return s.keyword
+	case *BreakStmt:
+		if loops == 0 {
+			return s.keyword
+		}
+	case *ContinueStmt:
+		if loops == 0 {
+			return s.keyword
+		}
+	case *BlockStmt:
+		for _, c := range s.list {
+			if t := escapes(c, loops); t != nil {
+				return t
+			}
+		}
+	case *IfStmt:
+		if t := escapes(s.block1, loops); t != nil {
+			return t
+		}
+		if s.block2 != nil {
+			return escapes(s.block2, loops)
+		}
+	case *WhileStmt:
+		return escapes(s.body, loops+1)
+	}
+	return nil
+}
+
+func lineStart(s string, pos int) int {
+	return strings.LastIndexByte(s[:pos], '\n') + 1
+}
+
+func lineEnd(s string, pos int) int {
+	if i := strings.IndexByte(s[pos:], '\n'); i >= 0 {
+		return pos + i + 1
+	}
+	return len(s)
+}
+
+func leadingSpace(s string) string {
+	return s[:len(s)-len(strings.TrimLeft(s, " \t"))]
+}
@@ -0,0 +1,43 @@
+package lox
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		src        string
+		start, end int
+		name       string
+		want       string // the source with the function or the error
+	}{
+		{"var a = 1;\nprint a;\nprint a + 1;\n", 2, 3, "show",
+			"var a = 1;\nfun show() {\n  print a;\n  print a + 1;\n}\n\nshow();\n"},
+		// the locals it uses become parameters, the function goes before the
+		// top-level statement
+		{"fun f(x) {\n  var y = x * 2;\n  print x + y;\n}\n", 3, 3, "report",
+			"fun report(x, y) {\n  print x + y;\n}\n\nfun f(x) {\n  var y = x * 2;\n  report(x, y);\n}\n"},
+		{"{\n  var n = 1;\n  if (n > 0) {\n    print n;\n  }\n}", 3, 5, "check",
+			"fun check(n) {\n  if (n > 0) {\n    print n;\n  }\n}\n\n{\n  var n = 1;\n  check(n);\n}"},
+
+		{"print 1;", 1, 1, "1f", "'1f' is not a valid identifier"},
+		{"var f = 1;\nprint f;", 2, 2, "f", "'f' is already used in the program"},
+		{"print 1;\n\nprint 2;", 2, 2, "g", "no statements on lines 2-2"},
+		{"if (true) {\n  print 1;\n}\nprint 2;", 2, 4, "g", "lines 2-4 do not cover whole statements"},
+		{"{ print 1;\n  print 2; }", 2, 2, "g", "line 2 has code outside of the selection"},
+		{"fun f() {\n  return 1;\n}", 2, 2, "g", "[line 2] 'return' cannot leave the extracted function"},
+		{"while (true) {\n  break;\n}", 2, 2, "g", "[line 2] 'break' cannot leave the extracted function"},
+		{"{\n  var a = 1;\n  print a;\n}", 2, 2, "g", "[line 3] 'a' is declared in the selection but used after it"},
+		{"{\n  var a = 1;\n  a = 2;\n}", 3, 3, "g", "[line 3] the selection assigns to 'a' that is declared outside of it"},
+	}
+	for _, tt := range tests {
+		got, err := Extract(tt.src, tt.start, tt.end, tt.name)
+		if err != nil {
+			got = err.Error()
+		}
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("Extract(%q, %v-%v, %v):\ngot  %q\nwant %q", tt.src, tt.start, tt.end, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package lox
+
+import (
+	"errors"
+	"strings"
+)
+
+// Format reprints the program in source in the canonical layout: a
+// statement on every line, blocks indented with tabs, the bodies of if,
+// else, while and for that are not blocks on a line of their own, one
+// space around binary operators and after commas and keywords. The
+// comments are kept, and so are single blank lines between statements.
+// Long lines are not broken up. A program with syntax errors is left
+// alone and the errors are returned.
+func Format(source string) (string, error) {
+	sc := NewScanner(source)
+	sc.KeepComments = true
+	all, err := sc.Scan()
+	if err != nil {
+		return "", err
+	}
+	f := &formatter{}
+	for _, t := range all {
+		if t.tok == Comment {
+			f.comments = append(f.comments, t)
+		} else {
+			f.toks = append(f.toks, t)
+		}
+	}
+	if _, errs := NewParser(f.toks).Parse(); len(errs) > 0 {
+		return "", errors.Join(errs...)
+	}
+	if strings.HasPrefix(source, "#!") {
+		// the scanner skipped the interpreter line
+		line, _, _ := strings.Cut(source, "\n")
+		f.b.WriteString(strings.TrimRight(line, "\r") + "\n")
+		f.last = 1
+	}
+	f.lineStart, f.empty = true, true
+	f.stmts(EOF)
+	return f.b.String(), nil
+}
+
+// formatter writes the tokens of a program that is known to parse. It
+// follows the statements just closely enough to lay them out, the
+// expressions are written token by token.
+type formatter struct {
+	toks     []*Token // without the comments, ending with EOF
+	comments []*Token
+	i, c     int // the next token and comment
+
+	b         strings.Builder
+	indent    int
+	lineStart bool   // nothing is written on the current line, not even the indentation
+	empty     bool   // no token is written on the current line yet
+	prev      *Token // the last token written in the statement, nil at its start
+	unary     bool   // prev is a unary operator
+	last      int    // source line at which the last token or comment written ends
+}
+
+func (f *formatter) peek() *Token {
+	return f.toks[f.i]
+}
+
+func (f *formatter) next() *Token {
+	t := f.toks[f.i]
+	f.i++
+	return t
+}
+
+func (f *formatter) write(s string) {
+	if f.lineStart {
+		f.b.WriteString(strings.Repeat("\t", f.indent))
+		f.lineStart = false
+	}
+	f.b.WriteString(s)
+	f.empty = false
+}
+
+// space writes a space unless the line is empty or already ends with one.
+func (f *formatter) space() {
+	if !f.empty && !strings.HasSuffix(f.b.String(), " ") {
+		f.write(" ")
+	}
+}
+
+// emit writes t and the comments before it, each set off by a single space.
+func (f *formatter) emit(t *Token) {
+	block := false
+	for f.c < len(f.comments) && f.comments[f.c].pos < t.pos {
+		f.space()
+		f.writeComment(f.nextComment())
+		block = f.comments[f.c-1].lexeme[1] != '/'
+		if !block {
+			// the rest of the line is the comment, go on with a
+			// continuation line
+			f.b.WriteString("\n" + strings.Repeat("\t", f.indent+1))
+			f.empty = true
+		}
+	}
+	if block {
+		switch t.tok {
+		case RightParen, Comma, Semicolon, Dot:
+		default:
+			f.space()
+		}
+	}
+	f.write(t.lexeme)
+	f.unary = (t.tok == Minus || t.tok == Bang) && !endsOperand(f.prev)
+	f.prev = t
+	f.last = t.line + strings.Count(t.lexeme, "\n")
+}
+
+// nextComment returns the next comment.
+func (f *formatter) nextComment() *Token {
+	t := f.comments[f.c]
+	f.c++
+	return t
+}
+
+func (f *formatter) writeComment(t *Token) {
+	f.write(t.lexeme)
+	f.last = t.line + strings.Count(t.lexeme, "\n")
+}
+
+// endLine ends the current line after the comments that follow on the same
+// line of the source.
+func (f *formatter) endLine() {
+	for f.c < len(f.comments) && f.comments[f.c].line == f.last && f.comments[f.c].pos < f.peek().pos {
+		f.space()
+		f.writeComment(f.nextComment())
+	}
+	if !f.lineStart {
+		f.b.WriteString("\n")
+		f.lineStart, f.empty = true, true
+	}
+}
+
+// leading writes the comments on the lines before t and keeps a blank line
+// before them and before t if there was one, unless t is the first in its
+// block or ends it.
+func (f *formatter) leading(t *Token, first bool) {
+	blank := func(line int) {
+		if !first && line > f.last+1 && f.b.Len() > 0 {
+			f.b.WriteString("\n")
+		}
+	}
+	for f.c < len(f.comments) && f.comments[f.c].pos < t.pos {
+		blank(f.comments[f.c].line)
+		f.writeComment(f.nextComment())
+		f.endLine()
+		first = false
+	}
+	if t.tok != RightBrace && t.tok != EOF {
+		blank(t.line)
+	}
+}
+
+// stmts writes the statements up to end, a closing brace or EOF.
+func (f *formatter) stmts(end TokenType) {
+	for first := true; ; first = false {
+		f.leading(f.peek(), first)
+		if f.peek().tok == end {
+			return
+		}
+		f.stmt()
+		f.endLine()
+	}
+}
+
+func (f *formatter) stmt() {
+	f.prev = nil
+	switch t := f.peek(); t.tok {
+	case LeftBrace:
+		f.block()
+	case If:
+		f.emit(f.next())
+		f.space()
+		f.parens()
+		braced := f.body()
+		if f.peek().tok != Else {
+			return
+		}
+		if braced {
+			f.space()
+		} else {
+			f.endLine()
+		}
+		f.emit(f.next())
+		if f.peek().tok == If {
+			f.space()
+			f.stmt()
+		} else {
+			f.body()
+		}
+	case While, For:
+		f.emit(f.next())
+		f.space()
+		f.parens()
+		f.body()
+	case Fun:
+		if f.toks[f.i+1].tok != Identifier {
+			f.simple()
+			return
+		}
+		f.emit(f.next())
+		f.space()
+		f.emit(f.next())
+		f.parens()
+		f.space()
+		f.block()
+	default:
+		f.simple()
+	}
+}
+
+// body writes the body of an if, else or loop and tells if it is a block.
+func (f *formatter) body() bool {
+	if f.peek().tok == LeftBrace {
+		f.space()
+		f.block()
+		return true
+	}
+	f.endLine()
+	f.indent++
+	f.leading(f.peek(), true)
+	f.stmt()
+	f.indent--
+	return false
+}
+
+func (f *formatter) block() {
+	f.emit(f.next())
+	if f.peek().tok == RightBrace && (f.c == len(f.comments) || f.comments[f.c].pos > f.peek().pos) {
+		f.emit(f.next())
+		return
+	}
+	f.endLine()
+	f.indent++
+	f.stmts(RightBrace)
+	f.indent--
+	f.emit(f.next())
+}
+
+// simple writes a statement that ends with a semicolon.
+func (f *formatter) simple() {
+	f.expr(Semicolon)
+	f.emit(f.next())
+}
+
+// parens writes the parenthesized tokens that follow.
+func (f *formatter) parens() {
+	f.emit(f.next())
+	f.expr(RightParen)
+	f.emit(f.next())
+}
+
+// expr writes the tokens up to end outside of parentheses, which is left
+// for the caller.
+func (f *formatter) expr(end TokenType) {
+	for t := f.peek(); t.tok != end; t = f.peek() {
+		if f.spaced(t) {
+			f.space()
+		}
+		switch t.tok {
+		case LeftParen:
+			f.parens()
+		case Fun:
+			f.emit(f.next())
+			f.parens()
+			f.space()
+			f.block()
+		default:
+			f.emit(f.next())
+		}
+	}
+}
+
+// endsOperand tells if t can be the last token of an operand, so that a
+// minus after it is a binary one.
+func endsOperand(t *Token) bool {
+	if t == nil {
+		return false
+	}
+	switch t.tok {
+	case Identifier, Number, String, True, False, Nil, This, Super, RightParen, RightBrace:
+		return true
+	}
+	return false
+}
+
+// spaced tells if there is a space between the previous token and t.
+func (f *formatter) spaced(t *Token) bool {
+	p := f.prev
+	switch {
+	case p == nil:
+		return false
+	case t.tok == RightParen || t.tok == Comma || t.tok == Semicolon || t.tok == Dot:
+		return false
+	case p.tok == LeftParen || p.tok == Dot:
+		return false
+	case t.tok == LeftParen && (p.tok == Identifier || p.tok == RightParen || p.tok == RightBrace || p.tok == Fun):
+		// a call or the parameters of a function
+		return false
+	case (p.tok == Minus || p.tok == Bang) && f.unary:
+		return false
+	}
+	return true
+}
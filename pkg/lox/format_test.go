@@ -0,0 +1,47 @@
+package lox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestFormat formats each program in testdata/format and compares it with
+// the .golden file next to it. The result must also be left as it is when
+// formatted again.
+func TestFormat(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "format", "*.lox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no programs in testdata/format")
+	}
+	for _, name := range files {
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			src, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(strings.TrimSuffix(name, ".lox") + ".golden")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Format(string(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("got:\n%v\nwant:\n%v", got, want)
+			}
+			again, err := Format(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again != got {
+				t.Errorf("formatting again gives:\n%v", again)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package lox
+
+import (
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// FileSystem holds the files that the readFile() and writeFile() natives
+// read and write, so that programs embedding the interpreter can give
+// scripts files that are not those of the host.
+type FileSystem interface {
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte) error
+}
+
+// HostFS is the file system of the host, the default one.
+type HostFS struct{}
+
+func (HostFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+func (HostFS) WriteFile(name string, data []byte) error {
+	return os.WriteFile(name, data, 0644)
+}
+
+// MemFS is a file system that keeps the files in memory, the zero value is
+// empty. It is safe for use by concurrent tasks.
+type MemFS struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (m *MemFS) WriteFile(name string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.files == nil {
+		m.files = make(map[string][]byte)
+	}
+	m.files[name] = append([]byte(nil), data...)
+	return nil
+}
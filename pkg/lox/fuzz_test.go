@@ -0,0 +1,87 @@
+package lox
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// Whatever the input, scanning, parsing and running it must end without a
+// panic, in bounded time and memory. Run them with
+//
+//	go test -fuzz FuzzInterpret ./pkg/lox
+
+// fuzzSeeds are inputs that once took the interpreter down or are close to
+// its limits.
+var fuzzSeeds = []string{
+	`print "unterminated`,
+	`"`,
+	`print 1 + "a;`,
+	strings.Repeat("(", 20000) + "1" + strings.Repeat(")", 20000) + ";",
+	strings.Repeat("{", 20000),
+	strings.Repeat("-", 20000) + "1;",
+	strings.Repeat("!", 20000) + "true;",
+	"var a = " + strings.Repeat("a = ", 20000) + "1;",
+	"print 1" + strings.Repeat("0", 400) + ";",
+	"print 1" + strings.Repeat("0", 400) + ".5;",
+	"print 0." + strings.Repeat("0", 400) + "1;",
+	`print -"a";`,
+	`print "a" < 1;`,
+	`fun f() { return f(); } f();`,
+	`while (true) {}`,
+	`var s = "x"; while (true) s = s + s;`,
+	`fun f(a, b,) { return a + b; } print f(1, 2,);`,
+}
+
+func FuzzScan(f *testing.F) {
+	for _, s := range fuzzSeeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		NewScanner(src).Scan()
+	})
+}
+
+func FuzzParse(f *testing.F) {
+	for _, s := range fuzzSeeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		toks, err := NewScanner(src).Scan()
+		if err != nil {
+			return
+		}
+		stmts, _ := NewParser(toks).Parse()
+		Resolve(stmts)
+	})
+}
+
+// FuzzInterpret runs the input with both backends, with and without the
+// optimizer, limited to a few steps and allocations and without the
+// natives that reach out of the interpreter.
+func FuzzInterpret(f *testing.F) {
+	for _, s := range fuzzSeeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		for _, opts := range [][]Option{
+			{},
+			{WithBackend("vm")},
+			{WithOptimize()},
+			{WithBackend("vm"), WithOptimize()},
+		} {
+			in := New(append(opts,
+				WithStdout(io.Discard),
+				WithStderr(io.Discard),
+				WithStdin(strings.NewReader("")),
+				WithFS(&MemFS{}),
+				WithMaxSteps(10000),
+				WithMaxAlloc(1<<20),
+				WithMaxCallDepth(200),
+				DenyCapabilities(CapTempFile, CapExec, CapNetwork, CapTasks),
+			)...)
+			in.Run(src)
+			in.Cleanup()
+		}
+	})
+}
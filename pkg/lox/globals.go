@@ -0,0 +1,53 @@
+package lox
+
+// globals holds the global variables of a program, or of a whole REPL
+// session. Every name gets a cell the first time it is looked up, and the
+// cell is never removed or replaced: defining the variable again, e.g. in a
+// later line of the REPL, stores into the same cell. So the places in the
+// code that read or write a global keep its cell after the first lookup and
+// skip hashing the name from then on, and the cached cells never go stale.
+type globals struct {
+	cells map[string]*globalCell
+}
+
+type globalCell struct {
+	name    string
+	v       Value
+	defined bool
+	owner   *globals
+}
+
+func newGlobals() *globals {
+	g := &globals{cells: make(map[string]*globalCell)}
+	for _, n := range natives {
+		g.define(n.name, funValue(n))
+	}
+	return g
+}
+
+// cell returns the cell of name, which is not defined if nothing was
+// stored into it yet.
+func (g *globals) cell(name string) *globalCell {
+	c, ok := g.cells[name]
+	if !ok {
+		c = &globalCell{name: name, owner: g}
+		g.cells[name] = c
+	}
+	return c
+}
+
+// cached returns the cell of name, using and updating the one cached at a
+// site of the code.
+func (g *globals) cached(cache **globalCell, name string) *globalCell {
+	if c := *cache; c != nil && c.owner == g {
+		return c
+	}
+	*cache = g.cell(name)
+	return *cache
+}
+
+func (g *globals) define(name string, v Value) {
+	c := g.cell(name)
+	c.v = v
+	c.defined = true
+}
@@ -0,0 +1,393 @@
+package lox
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TranslateGo translates the program in source into the source of a Go
+// program, package main, that does what the script does with the runtime
+// in package loxrt. The functions of the script become closures of Go and
+// its variables variables of Go, the globals are package variables that
+// are bound late like those of the interpreter. The natives of the tasks
+// are not supported. The program denies the natives like an interpreter
+// made with opts would, only the capabilities of opts count.
+func TranslateGo(source string, opts ...Option) ([]byte, error) {
+	toks, err := NewScanner(source).Scan()
+	if err != nil {
+		return nil, err
+	}
+	stmts, errs := NewParser(toks).Parse()
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	g := &goGen{
+		table:    Resolve(stmts),
+		names:    make(map[*Symbol]string),
+		checked:  make(map[*Symbol]bool),
+		declared: make(map[*Symbol]bool),
+	}
+	for _, s := range stmts {
+		Inspect(s, func(n Node) bool {
+			if v, ok := n.(*VarStmt); ok && v.init == nil {
+				g.checked[g.table.Lookup(v.name)] = true
+			}
+			return true
+		})
+	}
+	for _, sym := range g.table.Symbols() {
+		if !sym.global {
+			continue
+		}
+		if sym.kind == NativeSymbol && lookupNative(sym.name).cap == CapTasks {
+			g.errs = append(g.errs, fmt.Errorf("[line %v] error: the native '%v' of the tasks is not supported",
+				sym.refs[0].line, sym.name))
+		}
+		g.globals = append(g.globals, sym)
+	}
+
+	g.line("package main")
+	g.line(`import "github.com/ysmolsky/glox/pkg/loxrt"`)
+	if len(g.globals) > 0 {
+		g.line("var (")
+		for _, sym := range g.globals {
+			g.line("%v loxrt.Global", g.global(sym.name))
+		}
+		g.line(")\n")
+	}
+	g.line("func main() {")
+	allow, deny := capabilityChanges(New(opts...))
+	if len(allow) > 0 {
+		g.line("loxrt.Allow(%v)", allow)
+	}
+	if len(deny) > 0 {
+		g.line("loxrt.Deny(%v)", deny)
+	}
+	g.line("loxrt.Main(run)")
+	g.line("}\n")
+	g.line("func run() {")
+	for _, sym := range g.globals {
+		if sym.kind == NativeSymbol {
+			g.line("%v.Define(loxrt.Native(%q))", g.global(sym.name), sym.name)
+		}
+	}
+	g.stmts(stmts)
+	g.line("}")
+	if len(g.errs) > 0 {
+		return nil, errors.Join(g.errs...)
+	}
+	out, err := format.Source(g.b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("gogen: %v\n%s", err, g.b.Bytes())
+	}
+	return out, nil
+}
+
+// capabilityChanges returns the capabilities that in allows and New does
+// not, and those it denies and New does not, as quoted lists for the calls
+// of loxrt.Allow and loxrt.Deny.
+func capabilityChanges(in *Interpreter) (allow, deny string) {
+	byDefault := make(map[Capability]bool)
+	for _, c := range deniedByDefault {
+		byDefault[c] = true
+	}
+	var allowed, denied []string
+	for _, c := range deniedByDefault {
+		if !in.denied[c] {
+			allowed = append(allowed, strconv.Quote(string(c)))
+		}
+	}
+	for c := range in.denied {
+		if !byDefault[c] {
+			denied = append(denied, strconv.Quote(string(c)))
+		}
+	}
+	sort.Strings(denied)
+	return strings.Join(allowed, ", "), strings.Join(denied, ", ")
+}
+
+// goGen writes the Go code of a program.
+type goGen struct {
+	b        bytes.Buffer
+	table    *SymbolTable
+	names    map[*Symbol]string // the variables of Go of the locals
+	checked  map[*Symbol]bool   // the locals that may be read before they are assigned
+	declared map[*Symbol]bool   // the locals declared in Go so far
+	globals  []*Symbol
+	n        int // numbers the locals
+	errs     []error
+}
+
+func (g *goGen) line(format string, args ...interface{}) {
+	fmt.Fprintf(&g.b, format+"\n", args...)
+}
+
+// global returns the variable of Go of the global name. The names of the
+// locals end with a number, so they never clash with those of the globals.
+func (g *goGen) global(name string) string {
+	return name + "_g"
+}
+
+// local returns the variable of Go of the local that sym is.
+func (g *goGen) local(sym *Symbol) string {
+	if name, ok := g.names[sym]; ok {
+		return name
+	}
+	g.n++
+	name := fmt.Sprintf("%v_%v", sym.name, g.n)
+	g.names[sym] = name
+	return name
+}
+
+func (g *goGen) stmts(list []Stmt) {
+	for _, s := range list {
+		g.stmt(s)
+	}
+}
+
+// declareBody declares the local that s declares before s is written as
+// the body of an if or a loop, where it belongs to the enclosing scope.
+func (g *goGen) declareBody(s Stmt) {
+	var name *Token
+	switch s := s.(type) {
+	case *FunStmt:
+		name = s.name
+	case *VarStmt:
+		name = s.name
+	default:
+		return
+	}
+	if sym := g.table.Lookup(name); sym != nil && !sym.global && !g.declared[sym] {
+		g.declared[sym] = true
+		g.line("%v := loxrt.Unassigned", g.local(sym))
+		g.line("_ = %v", g.local(sym))
+	}
+}
+
+// body writes the statement of an if or a loop as a block of Go.
+func (g *goGen) body(s Stmt) {
+	g.line("{")
+	if b, ok := s.(*BlockStmt); ok {
+		g.stmts(b.list)
+	} else {
+		g.stmt(s)
+	}
+	g.line("}")
+}
+
+func (g *goGen) stmt(s Stmt) {
+	switch s := s.(type) {
+	case *BlockStmt:
+		g.body(s)
+	case *BreakStmt:
+		g.line("break")
+	case *ContinueStmt:
+		// the increment of a for loop is in its body, so it is skipped as
+		// it is by the interpreter
+		g.line("continue")
+	case *ExprStmt:
+		switch e := s.expression.(type) {
+		case *AssignExpr:
+			if sym := g.table.Lookup(e.name); sym != nil && !sym.global {
+				g.line("%v = %v", g.local(sym), g.expr(e.value))
+				return
+			}
+			g.line("%v", g.expr(e))
+		case *CallExpr:
+			g.line("%v", g.expr(e))
+		default:
+			g.line("_ = %v", g.expr(e))
+		}
+	case *FunStmt:
+		g.define(s.name, g.function(s.name.lexeme, s.params, s.body))
+	case *IfStmt:
+		g.declareBody(s.block1)
+		if s.block2 != nil {
+			g.declareBody(s.block2)
+		}
+		fmt.Fprintf(&g.b, "if loxrt.Truthy(%v) ", g.expr(s.condition))
+		g.body(s.block1)
+		if s.block2 != nil {
+			g.b.Truncate(g.b.Len() - 1) // the else goes on the line of the brace
+			g.b.WriteString(" else ")
+			g.body(s.block2)
+		}
+	case *PrintStmt:
+		g.line("loxrt.Print(%v)", g.expr(s.expression))
+	case *ReturnStmt:
+		if s.value == nil {
+			g.line("return loxrt.Nil")
+		} else {
+			g.line("return %v", g.expr(s.value))
+		}
+	case *VarStmt:
+		if s.init == nil {
+			g.define(s.name, "loxrt.Unassigned")
+		} else {
+			g.define(s.name, g.expr(s.init))
+		}
+	case *WhileStmt:
+		g.declareBody(s.body)
+		fmt.Fprintf(&g.b, "for loxrt.Truthy(%v) ", g.expr(s.condition))
+		g.body(s.body)
+	}
+}
+
+// define writes the declaration of the variable or the function name with
+// the value in the code v.
+func (g *goGen) define(name *Token, v string) {
+	sym := g.table.Lookup(name)
+	switch {
+	case sym.global:
+		g.line("%v.Define(%v)", g.global(name.lexeme), v)
+	case g.declared[sym]:
+		g.line("%v = %v", g.local(sym), v)
+	case sym.kind == FunSymbol:
+		// declared first, so that the function can call itself
+		g.declared[sym] = true
+		g.line("var %v loxrt.Value", g.local(sym))
+		g.line("%v = %v", g.local(sym), v)
+		g.line("_ = %v", g.local(sym))
+	default:
+		g.declared[sym] = true
+		g.line("%v := %v", g.local(sym), v)
+		g.line("_ = %v", g.local(sym))
+	}
+}
+
+// function returns the code of a function of the script.
+func (g *goGen) function(name string, params []*Token, body []Stmt) string {
+	outer := g.b
+	g.b = bytes.Buffer{}
+
+	quoted := make([]string, len(params))
+	for i, p := range params {
+		quoted[i] = strconv.Quote(p.lexeme)
+	}
+	g.line("loxrt.Function(%q, []string{%v}, func(args []loxrt.Value) loxrt.Value {", name, strings.Join(quoted, ", "))
+	for i, p := range params {
+		sym := g.table.Lookup(p)
+		g.declared[sym] = true
+		g.line("%v := args[%v]", g.local(sym), i)
+		g.line("_ = %v", g.local(sym))
+	}
+	g.stmts(body)
+	if len(body) == 0 || !isReturn(body[len(body)-1]) {
+		g.line("return loxrt.Nil")
+	}
+	g.b.WriteString("})")
+
+	code := g.b.String()
+	g.b = outer
+	return code
+}
+
+func isReturn(s Stmt) bool {
+	_, ok := s.(*ReturnStmt)
+	return ok
+}
+
+// operands returns the code of the operands list, which are evaluated from
+// left to right. Go reads variables at any time among the calls of an
+// expression, so the locals read before an operand that may change them
+// are read by a call.
+func (g *goGen) operands(list ...Expr) []string {
+	code := make([]string, len(list))
+	effects := false
+	for i := len(list) - 1; i >= 0; i-- {
+		code[i] = g.expr(list[i])
+		if v, ok := list[i].(*VarExpr); ok && effects {
+			if sym := g.table.Lookup(v.name); sym != nil && !sym.global && !g.checked[sym] {
+				code[i] = fmt.Sprintf("loxrt.Load(%v)", code[i])
+			}
+		}
+		effects = effects || sideEffects(list[i])
+	}
+	return code
+}
+
+// sideEffects tells if evaluating e may assign variables.
+func sideEffects(e Expr) bool {
+	found := false
+	Inspect(e, func(n Node) bool {
+		switch n.(type) {
+		case *CallExpr, *AssignExpr:
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// expr returns the code of e.
+func (g *goGen) expr(e Expr) string {
+	switch e := e.(type) {
+	case *AssignExpr:
+		sym := g.table.Lookup(e.name)
+		if sym != nil && !sym.global {
+			return fmt.Sprintf("loxrt.Set(&%v, %v)", g.local(sym), g.expr(e.value))
+		}
+		return fmt.Sprintf("%v.Set(%v, %q, %v)", g.global(e.name.lexeme), e.name.line, e.name.lexeme, g.expr(e.value))
+	case *BinaryExpr:
+		ops := g.operands(e.left, e.right)
+		x, y, line := ops[0], ops[1], e.operator.line
+		switch e.operator.tok {
+		case EqualEqual:
+			return fmt.Sprintf("loxrt.Bool(loxrt.Equal(%v, %v))", x, y)
+		case BangEqual:
+			return fmt.Sprintf("loxrt.Bool(!loxrt.Equal(%v, %v))", x, y)
+		}
+		op := map[TokenType]string{
+			Plus: "Add", Minus: "Sub", Star: "Mul", Slash: "Div",
+			Less: "Less", LessEqual: "LessEqual", Greater: "Greater", GreaterEqual: "GreaterEqual",
+		}[e.operator.tok]
+		return fmt.Sprintf("loxrt.%v(%v, %v, %v)", op, line, x, y)
+	case *CallExpr:
+		args := append([]string{strconv.Itoa(e.paren.line)}, g.operands(append([]Expr{e.callee}, e.args...)...)...)
+		return fmt.Sprintf("loxrt.Call(%v)", strings.Join(args, ", "))
+	case *FunExpr:
+		return g.function("", e.params, e.body)
+	case *GroupingExpr:
+		return g.expr(e.e)
+	case *LiteralExpr:
+		switch e.value.kind {
+		case boolKind:
+			if e.value.asBool() {
+				return "loxrt.True"
+			}
+			return "loxrt.False"
+		case numKind:
+			return fmt.Sprintf("loxrt.Number(%v)", strconv.FormatFloat(e.value.num, 'g', -1, 64))
+		case strKind:
+			return fmt.Sprintf("loxrt.String(%v)", strconv.Quote(e.value.asString()))
+		}
+		return "loxrt.Nil"
+	case *LogicalExpr:
+		cond := "!loxrt.Truthy(x)"
+		if e.operator.tok == Or {
+			cond = "loxrt.Truthy(x)"
+		}
+		return fmt.Sprintf("func() loxrt.Value {\nif x := %v; %v {\nreturn x\n}\nreturn %v\n}()",
+			g.expr(e.left), cond, g.expr(e.right))
+	case *UnaryExpr:
+		if e.operator.tok == Bang {
+			return fmt.Sprintf("loxrt.Not(%v)", g.expr(e.right))
+		}
+		return fmt.Sprintf("loxrt.Neg(%v, %v)", e.operator.line, g.expr(e.right))
+	case *VarExpr:
+		sym := g.table.Lookup(e.name)
+		if sym == nil || sym.global {
+			return fmt.Sprintf("%v.Get(%v, %q)", g.global(e.name.lexeme), e.name.line, e.name.lexeme)
+		}
+		if g.checked[sym] {
+			return fmt.Sprintf("loxrt.Check(%v, %q, %v)", e.name.line, e.name.lexeme, g.local(sym))
+		}
+		return g.local(sym)
+	}
+	return "loxrt.Nil"
+}
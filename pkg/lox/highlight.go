@@ -0,0 +1,107 @@
+package lox
+
+// TokenClass is what a token of the source is to an editor that highlights
+// it.
+type TokenClass int
+
+const (
+	KeywordClass TokenClass = iota
+	FunctionClass
+	ParameterClass
+	VariableClass
+	StringClass
+	NumberClass
+	CommentClass
+	OperatorClass
+)
+
+var tokenClassNames = [...]string{
+	KeywordClass:   "keyword",
+	FunctionClass:  "function",
+	ParameterClass: "parameter",
+	VariableClass:  "variable",
+	StringClass:    "string",
+	NumberClass:    "number",
+	CommentClass:   "comment",
+	OperatorClass:  "operator",
+}
+
+func (c TokenClass) String() string {
+	return tokenClassNames[c]
+}
+
+// TokenClasses returns the names of the classes by their values.
+func TokenClasses() []string {
+	return tokenClassNames[:]
+}
+
+// Highlight is a token of the source with its class. Decl tells if an
+// identifier declares the name, Native if it names a native function.
+type Highlight struct {
+	Token  *Token
+	Class  TokenClass
+	Decl   bool
+	Native bool
+}
+
+// Highlights classifies the tokens of source for highlighting, in source
+// order. The parentheses, braces and other punctuation are left out. The
+// names are told apart by what the resolver binds them to, so a variable
+// that holds a function is a variable, and the parts of the program that
+// do not parse still get their names classified where possible. If the
+// source does not scan, the tokens before the error are returned with it.
+func Highlights(source string) ([]Highlight, error) {
+	sc := NewScanner(source)
+	sc.KeepComments = true
+	all, err := sc.Scan()
+	var toks []*Token
+	for _, t := range all {
+		if t.tok != Comment {
+			toks = append(toks, t)
+		}
+	}
+	var table *SymbolTable
+	if err == nil {
+		stmts, _ := NewParser(toks).Parse()
+		table = Resolve(stmts)
+	}
+
+	var list []Highlight
+	for _, t := range all {
+		h := Highlight{Token: t}
+		switch {
+		case t.tok == Comment:
+			h.Class = CommentClass
+		case t.tok == String:
+			h.Class = StringClass
+		case t.tok == Number:
+			h.Class = NumberClass
+		case t.tok >= And && t.tok <= While:
+			h.Class = KeywordClass
+		case t.tok == Identifier:
+			h.Class = VariableClass
+			var sym *Symbol
+			if table != nil {
+				sym = table.Lookup(t)
+			}
+			if sym == nil {
+				break
+			}
+			switch sym.kind {
+			case FunSymbol:
+				h.Class = FunctionClass
+			case NativeSymbol:
+				h.Class, h.Native = FunctionClass, true
+			case ParamSymbol:
+				h.Class = ParameterClass
+			}
+			h.Decl = sym.decl == t
+		case t.tok >= Minus && t.tok <= LessEqual && t.tok != Semicolon:
+			h.Class = OperatorClass
+		default:
+			continue
+		}
+		list = append(list, h)
+	}
+	return list, err
+}
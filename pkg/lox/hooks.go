@@ -0,0 +1,172 @@
+package lox
+
+import "sort"
+
+// Hooks are called while the programs run, for tracing them or building
+// profilers. Any of them may be nil. Tasks call them from their own
+// goroutines, one at a time.
+type Hooks struct {
+	// OnStatement is called with the line of every statement before it
+	// runs. Blocks are not reported, their statements are, and neither
+	// are the statements made up for the increment of a for loop.
+	OnStatement func(line int)
+
+	// OnCall is called with the name of the function and the arguments
+	// before a call, the name is empty for anonymous functions.
+	OnCall func(name string, args []Value)
+
+	// OnReturn is called with the result when the call returns. It is not
+	// called when the call fails.
+	OnReturn func(result Value)
+
+	// OnScope is called before OnStatement with the scope of the
+	// statement, through which debuggers read its local variables.
+	OnScope func(sc *Scope)
+}
+
+// Scope is where a statement runs, as given to Hooks.OnScope.
+type Scope struct {
+	// in the tree-walker, the envs of the function up to that of the
+	// call, the innermost last
+	envs []scopeEnv
+	pos  int // of the statement, the locals declared after it are left out
+
+	// in the VM, the stack of the call and the names of its slots
+	vm    *VM
+	base  int
+	names []string
+}
+
+// scopeEnv is an env of the tree-walker with the declarations of the
+// variables in its slots.
+type scopeEnv struct {
+	env   *Env
+	slots []*Token
+	call  bool // the env of a call, with the parameters
+}
+
+// Variable is a local variable and its value.
+type Variable struct {
+	Name  string
+	Value Value
+}
+
+// Locals returns the parameters and the local variables in scope, in the
+// order they are declared, with the values they have now. The variables of
+// enclosing functions are not included, and neither are those that inner
+// scopes shadow. Locals can be called until the call that runs the
+// statement returns, the values are those of the live variables.
+func (sc *Scope) Locals() []Variable {
+	var vars []Variable
+	for _, s := range sc.envs {
+		for i, decl := range s.slots {
+			if decl.pos < sc.pos {
+				vars = append(vars, Variable{decl.lexeme, s.env.values[i]})
+			}
+		}
+	}
+	for i, name := range sc.names {
+		vars = append(vars, Variable{name, sc.vm.stack[sc.base+1+i]})
+	}
+	// the innermost variable of a name is the one in scope
+	seen := make(map[string]bool)
+	var out []Variable
+	for i := len(vars) - 1; i >= 0; i-- {
+		if !seen[vars[i].Name] {
+			seen[vars[i].Name] = true
+			out = append(out, vars[i])
+		}
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// pushScope enters the scope of the block or the call that runs in env.
+func (in *Interpreter) pushScope(env *Env, slots []*Token, call bool) {
+	in.scopes = append(in.scopes, scopeEnv{env, slots, call})
+}
+
+func (in *Interpreter) popScope() {
+	in.scopes = in.scopes[:len(in.scopes)-1]
+}
+
+// StatementLines returns the lines of the statements in stmts that
+// OnStatement reports when they run, in order and without repeats. These
+// are the lines that coverage tools count.
+func StatementLines(stmts []Stmt) []int {
+	var lines []int
+	seen := make(map[int]bool)
+	for _, s := range stmts {
+		Inspect(s, func(n Node) bool {
+			if s, ok := n.(Stmt); ok && traced(s) {
+				first, _ := s.span()
+				if !seen[first.line] {
+					seen[first.line] = true
+					lines = append(lines, first.line)
+				}
+			}
+			return true
+		})
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// traced tells if s is reported to OnStatement.
+func traced(s Stmt) bool {
+	if _, ok := s.(*BlockStmt); ok {
+		return false
+	}
+	first, _ := s.span()
+	return first != nil
+}
+
+func (in *Interpreter) onStatement(s Stmt) {
+	if !traced(s) {
+		return
+	}
+	first, _ := s.span()
+	if in.Hooks.OnScope != nil {
+		// the scopes of the function, or the blocks of the top-level code
+		i := len(in.scopes)
+		for i > 0 && !in.scopes[i-1].call {
+			i--
+		}
+		if i > 0 {
+			i--
+		}
+		envs := append([]scopeEnv(nil), in.scopes[i:]...)
+		in.Hooks.OnScope(&Scope{envs: envs, pos: first.pos})
+	}
+	if in.Hooks.OnStatement != nil {
+		in.Hooks.OnStatement(first.line)
+	}
+}
+
+func (in *Interpreter) onCall(fn Callable, args []Value) {
+	if in.Hooks.OnCall != nil {
+		in.Hooks.OnCall(callName(fn), args)
+	}
+}
+
+func (in *Interpreter) onReturn(result Value) {
+	if in.Hooks.OnReturn != nil {
+		in.Hooks.OnReturn(result)
+	}
+}
+
+// callName returns the name that fn was declared with, empty for anonymous
+// functions.
+func callName(fn Callable) string {
+	switch f := fn.(type) {
+	case *FunObj:
+		return f.decl.name.lexeme
+	case *closure:
+		return f.fn.name
+	case *nativeFn:
+		return f.name
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package lox
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// localsAt runs src and returns the locals that OnScope gives at each
+// statement of line, formatted as name=value.
+func localsAt(t *testing.T, backend, src string, line int) []string {
+	t.Helper()
+	var got []string
+	var scope *Scope
+	hooks := &Hooks{
+		OnScope: func(sc *Scope) { scope = sc },
+		OnStatement: func(l int) {
+			if l != line {
+				return
+			}
+			var vars []string
+			for _, v := range scope.Locals() {
+				vars = append(vars, fmt.Sprintf("%v=%v", v.Name, v.Value.Quoted()))
+			}
+			got = append(got, strings.Join(vars, " "))
+		},
+	}
+	var out bytes.Buffer
+	in := New(WithBackend(backend), WithHooks(hooks), WithStdout(&out), WithStderr(&out))
+	if err := in.Run(src); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestScopeLocals(t *testing.T) {
+	tests := []struct {
+		src  string
+		line int
+		want []string
+	}{
+		{"fun f(a, b) {\n var c = a + b;\n print c;\n}\nf(1, 2);", 3, []string{"a=1 b=2 c=3"}},
+		// locals declared after the statement are not in scope yet
+		{"fun f(a) {\n print a;\n var later = 1;\n}\nf(\"x\");", 2, []string{`a="x"`}},
+		// the inner x shadows the parameter
+		{"fun f(x) {\n var y = 1;\n {\n  var x = 2;\n  print x + y;\n }\n}\nf(0);", 5, []string{"y=1 x=2"}},
+		// each iteration of a loop has its own block
+		{"fun f() {\n for (var i = 0; i < 2; i = i + 1) {\n  print i;\n  var j = i;\n }\n}\nf();", 3, []string{"i=0", "i=1"}},
+		// the variables of the enclosing function are not locals of the closure
+		{"fun f(a) {\n fun g(b) {\n  print b;\n }\n g(a + 1);\n}\nf(1);", 3, []string{"b=2"}},
+		{"{\n var top = 1;\n print top;\n}", 3, []string{"top=1"}},
+		{"var g = 1;\nprint g;", 2, []string{""}},
+	}
+	for _, backend := range backends {
+		for _, tt := range tests {
+			got := localsAt(t, backend, tt.src, tt.line)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("%v backend, %q: got %q, want %q", backend, tt.src, got, tt.want)
+			}
+		}
+	}
+}
+
+// TestScopeLive reads the locals of a caller after a call it made changed
+// them, which must give the new values.
+func TestScopeLive(t *testing.T) {
+	const src = "fun f() {\n var n = 1;\n fun inc() {\n  n = n + 1;\n }\n inc();\n inc();\n}\nf();"
+	for _, backend := range backends {
+		var caller *Scope
+		var got []string
+		hooks := &Hooks{
+			OnScope: func(sc *Scope) {
+				if caller == nil && len(sc.Locals()) == 2 {
+					caller = sc // at the first inc() in f
+				}
+			},
+			OnStatement: func(line int) {
+				if line == 4 {
+					got = append(got, fmt.Sprint(caller.Locals()[0].Value))
+				}
+			},
+		}
+		in := New(WithBackend(backend), WithHooks(hooks))
+		if err := in.Run(src); err != nil {
+			t.Fatal(err)
+		}
+		if want := "1 2"; strings.Join(got, " ") != want {
+			t.Errorf("%v backend: n was %q, want %q", backend, got, want)
+		}
+	}
+}
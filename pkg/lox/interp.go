@@ -0,0 +1,842 @@
+package lox
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type RuntimeError struct {
+	line  int
+	msg   string
+	trace []frame // call stack at the moment of the error
+	depth int     // see Interpreter.TraceDepth
+	cause error   // that stopped the program from outside, if any
+	task  bool    // the error happened in a task rather than the script
+}
+
+func (e RuntimeError) Unwrap() error {
+	return e.cause
+}
+
+func (e RuntimeError) Error() string {
+	s := fmt.Sprintf("[line %v] runtime error: %v", e.line, e.msg)
+	if len(e.trace) == 0 {
+		return s
+	}
+
+	// Walk from the innermost call outwards, each frame is at the line of the
+	// call into the frame above it.
+	lines := make([]string, 0, len(e.trace)+1)
+	line := e.line
+	for i := len(e.trace) - 1; i >= 0; i-- {
+		lines = append(lines, fmt.Sprintf("  [line %v] in %v", line, frameName(e.trace[i].fn)))
+		line = e.trace[i].line
+	}
+	bottom := "script"
+	if e.task {
+		bottom = "task"
+	}
+	lines = append(lines, fmt.Sprintf("  [line %v] in %v", line, bottom))
+
+	if n, d := len(lines), e.depth; d > 0 && n > 2*d {
+		elided := fmt.Sprintf("  ... %v frames elided ...", commas(n-2*d))
+		lines = append(append(lines[:d:d], elided), lines[n-d:]...)
+	}
+	return s + "\n" + strings.Join(lines, "\n")
+}
+
+func (e *Env) runtimeErr(t *Token, msg string) {
+	panic(e.newRuntimeError(t, msg))
+}
+
+func (e *Env) newRuntimeError(t *Token, msg string) RuntimeError {
+	trace := make([]frame, len(e.in.callStack))
+	copy(trace, e.in.callStack)
+	return RuntimeError{line: t.line, msg: msg, trace: trace, depth: e.in.TraceDepth, task: e.in.isTask}
+}
+
+// step counts a step of the program at t and stops the program if it ran
+// out of steps or its context is done.
+func (e *Env) step(t *Token) {
+	if err := e.in.step(); err != nil {
+		e.stop(t, err)
+	}
+}
+
+// allocate counts n bytes allocated at t and stops the program if it goes
+// over the limit.
+func (e *Env) allocate(t *Token, n int) {
+	if err := e.in.allocate(n); err != nil {
+		e.stop(t, err)
+	}
+}
+
+// stop stops the program at t because of err.
+func (e *Env) stop(t *Token, err error) {
+	re := e.newRuntimeError(t, e.in.stopMsg(err))
+	re.cause = err
+	panic(re)
+}
+
+// commas formats n with thousands separators.
+func commas(n int) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// ------------------------------------------
+// call stack
+
+// frame is an active call of a Lox function.
+type frame struct {
+	fn   Callable
+	line int // line of the call site in the caller
+}
+
+func frameName(fn Callable) string {
+	switch f := fn.(type) {
+	case *FunObj:
+		return f.decl.name.lexeme + "()"
+	case *FunAnon:
+		return "anonymous function"
+	case *closure:
+		if f.fn.name == "" {
+			return "anonymous function"
+		}
+		return f.fn.name + "()"
+	}
+	return fmt.Sprint(fn)
+}
+
+type ReturnHack struct{ v Value }
+type BreakErr struct{ t *Token }
+type ContinueErr struct{ t *Token }
+
+type Callable interface {
+	arity() int
+	call(*Env, []Value) Value
+}
+
+// ------------------------------------------
+// env
+
+// env contains bindings for variables. Locals live in slots that the
+// resolver assigned to them, globals are looked up by name.
+type Env struct {
+	values []Value
+
+	enclosing *Env
+	globals   *globals     // shared by all the envs of a program
+	in        *Interpreter // that runs the program
+}
+
+// NewEnv returns an env with size slots for locals nested in enclosing.
+func NewEnv(enclosing *Env, size int) *Env {
+	return &Env{make([]Value, size), enclosing, enclosing.globals, enclosing.in}
+}
+
+// callEnv returns the env of a call from caller into a function that closes
+// over closure. The call runs on the thread of the caller, which is not the
+// one that made the function if a task calls it.
+func callEnv(caller, closure *Env, size int) *Env {
+	env := NewEnv(closure, size)
+	if caller != nil {
+		env.in = caller.in
+	}
+	return env
+}
+
+func (e *Env) ancestor(depth int) *Env {
+	for ; depth > 0; depth-- {
+		e = e.enclosing
+	}
+	return e
+}
+
+// define sets the variable declared in slot of this env, or the global
+// variable name if slot is negative.
+func (e *Env) define(slot int, name string, v Value) {
+	if slot < 0 {
+		e.globals.define(name, v)
+		return
+	}
+	e.values[slot] = v
+}
+
+func (e *Env) get(name *Token, b *binding) Value {
+	var v Value
+	if b.slot < 0 {
+		c := e.globals.cached(&b.global, name.lexeme)
+		if !c.defined {
+			e.runtimeErr(name, "undefined variable '"+name.lexeme+"'")
+		}
+		v = c.v
+	} else {
+		v = e.ancestor(b.depth).values[b.slot]
+	}
+	if v.kind == unassignedKind {
+		e.runtimeErr(name, "variable '"+name.lexeme+"' used before assignment")
+	}
+	return v
+}
+
+func (e *Env) assign(name *Token, b *binding, v Value) {
+	if b.slot >= 0 {
+		e.ancestor(b.depth).values[b.slot] = v
+		return
+	}
+	c := e.globals.cached(&b.global, name.lexeme)
+	if !c.defined {
+		e.runtimeErr(name, "undefined variable '"+name.lexeme+"'")
+	}
+	c.v = v
+}
+
+// ------------------------------------------
+// interpret
+
+// interpret runs stmt in env. The result is the value of the last statement
+// if it is an expression, nil otherwise.
+func interpret(stmt []Stmt, env *Env) (result Value, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			if b, ok := e.(BreakErr); ok {
+				s := fmt.Sprintf("expected a while loop to break from at line %v ", b.t.line)
+				err = errors.New(s)
+				return
+			}
+			re, ok := e.(RuntimeError)
+			if !ok {
+				panic(e)
+			}
+			err = re
+		}
+	}()
+	for i, s := range stmt {
+		if env.in.Hooks != nil {
+			env.in.onStatement(s)
+		}
+		if e, ok := s.(*ExprStmt); ok && i == len(stmt)-1 {
+			return e.expression.eval(env), nil
+		}
+		s.execute(env)
+	}
+	return nilValue, nil
+}
+
+// ------------------------------------------
+// natives
+
+// Capability is the kind of access to the host that a native gives to
+// scripts. An interpreter can deny capabilities, see Interpreter.Deny.
+type Capability string
+
+const (
+	CapTime     Capability = "time"
+	CapTempFile Capability = "tempfile" // only inside of the sandbox
+	CapFile     Capability = "file"
+	CapExec     Capability = "exec"
+	CapNetwork  Capability = "network"
+	CapStdin    Capability = "stdin"
+	CapTasks    Capability = "tasks"
+	CapArgs     Capability = "args"
+	CapHost     Capability = "host" // registered by the embedding program
+)
+
+// nativeFn is a builtin function implemented in Go.
+type nativeFn struct {
+	name  string
+	nargs int
+	cap   Capability
+	fn    func(in *Interpreter, args []Value) (Value, error)
+}
+
+var natives = []*nativeFn{
+	{"clock", 0, CapTime, func(*Interpreter, []Value) (Value, error) {
+		return NumberValue(float64(time.Now().UnixNano())), nil
+	}},
+	{"tempDir", 0, CapTempFile, func(in *Interpreter, _ []Value) (Value, error) {
+		dir, err := in.sh.sandbox.tempDir()
+		return StringValue(dir), err
+	}},
+	{"tempFile", 0, CapTempFile, func(in *Interpreter, _ []Value) (Value, error) {
+		file, err := in.sh.sandbox.tempFile()
+		return StringValue(file), err
+	}},
+	{"readFile", 1, CapFile, func(in *Interpreter, args []Value) (Value, error) {
+		path, err := stringArg(args, 0)
+		if err != nil {
+			return nilValue, err
+		}
+		data, err := in.FS.ReadFile(path)
+		return StringValue(string(data)), err
+	}},
+	{"writeFile", 2, CapFile, func(in *Interpreter, args []Value) (Value, error) {
+		path, err := stringArg(args, 0)
+		if err != nil {
+			return nilValue, err
+		}
+		text, err := stringArg(args, 1)
+		if err != nil {
+			return nilValue, err
+		}
+		return nilValue, in.FS.WriteFile(path, []byte(text))
+	}},
+	{"exec", 1, CapExec, func(in *Interpreter, args []Value) (Value, error) {
+		// The command line is split at spaces and run without a shell.
+		line, err := stringArg(args, 0)
+		if err != nil {
+			return nilValue, err
+		}
+		argv := strings.Fields(line)
+		if len(argv) == 0 {
+			return nilValue, errors.New("empty command")
+		}
+		out, err := exec.CommandContext(in.context(), argv[0], argv[1:]...).CombinedOutput()
+		return StringValue(string(out)), err
+	}},
+	{"httpGet", 1, CapNetwork, func(in *Interpreter, args []Value) (Value, error) {
+		url, err := stringArg(args, 0)
+		if err != nil {
+			return nilValue, err
+		}
+		req, err := http.NewRequestWithContext(in.context(), "GET", url, nil)
+		if err != nil {
+			return nilValue, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nilValue, err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err == nil && resp.StatusCode != http.StatusOK {
+			err = errors.New(resp.Status)
+		}
+		return StringValue(string(body)), err
+	}},
+	{"spawn", 1, CapTasks, func(in *Interpreter, args []Value) (Value, error) {
+		return in.spawn(args[0])
+	}},
+	{"join", 1, CapTasks, func(in *Interpreter, args []Value) (Value, error) {
+		return in.join(args[0])
+	}},
+	{"channel", 1, CapTasks, func(in *Interpreter, args []Value) (Value, error) {
+		return in.makeChannel(args[0])
+	}},
+	{"send", 2, CapTasks, func(in *Interpreter, args []Value) (Value, error) {
+		c, err := channelArg(args, 0)
+		if err != nil {
+			return nilValue, err
+		}
+		return nilValue, in.send(c, args[1])
+	}},
+	{"receive", 1, CapTasks, func(in *Interpreter, args []Value) (Value, error) {
+		c, err := channelArg(args, 0)
+		if err != nil {
+			return nilValue, err
+		}
+		return in.receive(c)
+	}},
+	{"close", 1, CapTasks, func(in *Interpreter, args []Value) (Value, error) {
+		c, err := channelArg(args, 0)
+		if err != nil {
+			return nilValue, err
+		}
+		return nilValue, in.closeChannel(c)
+	}},
+	{"args", 0, CapArgs, func(in *Interpreter, _ []Value) (Value, error) {
+		return NumberValue(float64(len(in.Args))), nil
+	}},
+	{"arg", 1, CapArgs, func(in *Interpreter, args []Value) (Value, error) {
+		i, ok := args[0].Number()
+		if !ok || i != float64(int(i)) {
+			return nilValue, fmt.Errorf("argument 1 must be an integer")
+		}
+		if i < 0 || i >= float64(len(in.Args)) {
+			return nilValue, nil
+		}
+		return StringValue(in.Args[int(i)]), nil
+	}},
+	{"toString", 1, "", func(_ *Interpreter, args []Value) (Value, error) {
+		return StringValue(args[0].String()), nil
+	}},
+	{"deepEquals", 2, "", func(_ *Interpreter, args []Value) (Value, error) {
+		return BoolValue(deepEqual(args[0], args[1])), nil
+	}},
+	{"readLine", 0, CapStdin, func(in *Interpreter, _ []Value) (Value, error) {
+		line, err := in.stdin().ReadString('\n')
+		if err == io.EOF {
+			if line == "" {
+				return nilValue, nil
+			}
+			err = nil
+		}
+		return StringValue(strings.TrimRight(line, "\r\n")), err
+	}},
+}
+
+// stringArg returns argument i of a native, which must be a string.
+func stringArg(args []Value, i int) (string, error) {
+	s, ok := args[i].Str()
+	if !ok {
+		return "", fmt.Errorf("argument %v must be a string", i+1)
+	}
+	return s, nil
+}
+
+func lookupNative(name string) *nativeFn {
+	for _, n := range natives {
+		if n.name == name {
+			return n
+		}
+	}
+	return nil
+}
+
+// NativeCapability returns the kind of host access that the native called
+// name gives to scripts, or "" if it gives none or there is no such native.
+func NativeCapability(name string) Capability {
+	if n := lookupNative(name); n != nil {
+		return n.cap
+	}
+	return ""
+}
+
+func (n *nativeFn) arity() int {
+	return n.nargs
+}
+
+func (n *nativeFn) call(env *Env, args []Value) Value {
+	return n.run(env.in, args)
+}
+
+// run calls n for the interpreter in, panicking with its error. The natives
+// that wait for the outside world let the other tasks run meanwhile.
+func (n *nativeFn) run(in *Interpreter, args []Value) Value {
+	var v Value
+	var err error
+	if n.cap == CapExec || n.cap == CapNetwork {
+		in.blocking(func() { v, err = n.fn(in, args) })
+	} else {
+		v, err = n.fn(in, args)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// callNative calls n and converts whatever goes wrong inside of it, be it a
+// returned error or a Go panic, into a runtime error at the call site
+// instead of letting it take the whole process down.
+func callNative(paren *Token, n *nativeFn, env *Env, args []Value) Value {
+	if err := env.in.permit(n); err != nil {
+		env.runtimeErr(paren, err.Error())
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			if re, ok := e.(RuntimeError); ok {
+				panic(re) // already a Lox error, keep its location
+			}
+			if err, ok := e.(error); ok && stops(err) {
+				env.stop(paren, err)
+			}
+			env.runtimeErr(paren, fmt.Sprintf("native '%v' failed: %v", n.name, e))
+		}
+	}()
+	v := n.call(env, args)
+	if s, ok := v.Str(); ok {
+		// what natives read from outside counts like any other string
+		env.allocate(paren, stringSize+len(s))
+	}
+	return v
+}
+
+func (n *nativeFn) String() string {
+	return fmt.Sprintf("<native fn %v>", n.name)
+}
+
+// ------------------------------------------
+// Function
+
+type FunObj struct {
+	decl    *FunStmt
+	closure *Env
+}
+
+func (f *FunObj) arity() int {
+	return len(f.decl.params)
+}
+
+func (f *FunObj) call(e *Env, args []Value) (v Value) {
+	// parameters take the first slots
+	env := callEnv(e, f.closure, len(f.decl.slots))
+	copy(env.values, args)
+	if env.in.Hooks != nil {
+		env.in.pushScope(env, f.decl.slots, true)
+		defer env.in.popScope()
+	}
+
+	defer func() {
+		if e := recover(); e != nil {
+			// return whatever value is being panicked at us from return stmt
+			r, ok := e.(ReturnHack)
+			if !ok {
+				panic(e)
+			}
+			v = r.v
+		}
+	}()
+	execBlock(f.decl.body, env)
+	return nilValue
+}
+
+func (f *FunObj) String() string {
+	return fmt.Sprintf("<fn %v>", f.decl.name.lexeme)
+}
+
+type FunAnon struct {
+	decl    *FunExpr
+	closure *Env
+}
+
+func (f *FunAnon) arity() int {
+	return len(f.decl.params)
+}
+
+func (f *FunAnon) call(e *Env, args []Value) (v Value) {
+	env := callEnv(e, f.closure, len(f.decl.slots))
+	copy(env.values, args)
+	if env.in.Hooks != nil {
+		env.in.pushScope(env, f.decl.slots, true)
+		defer env.in.popScope()
+	}
+
+	defer func() {
+		if e := recover(); e != nil {
+			// return whatever value is being panicked at us from return stmt
+			r, ok := e.(ReturnHack)
+			if !ok {
+				panic(e)
+			}
+			v = r.v
+		}
+	}()
+	execBlock(f.decl.body, env)
+	return nilValue
+}
+
+func (f *FunAnon) String() string {
+	s := []string{}
+	for _, p := range f.decl.params {
+		s = append(s, p.lexeme)
+	}
+	return fmt.Sprintf("<lambda (%v)>", strings.Join(s, ","))
+}
+
+// ------------------------------------------
+// Expression Eval
+
+func (e *BinaryExpr) eval(env *Env) Value {
+	switch e.operator.tok {
+	case Plus:
+		x := e.left.eval(env)
+		y := e.right.eval(env)
+		switch {
+		case x.kind == numKind && y.kind == numKind:
+			return NumberValue(x.num + y.num)
+		case x.kind == strKind && y.kind == strKind:
+			s := x.asString() + y.asString()
+			env.allocate(e.operator, stringSize+len(s))
+			return StringValue(s)
+		}
+		env.runtimeErr(e.operator, operandsError("+", "two numbers or two strings", x, y))
+	case Minus:
+		xval, yval := e.evalFloats(env)
+		return NumberValue(xval - yval)
+	case Slash:
+		xval, yval := e.evalFloats(env)
+		if yval == 0 && !env.in.IEEEDivision {
+			env.runtimeErr(e.operator, "division by zero")
+		}
+		return NumberValue(xval / yval)
+	case Star:
+		xval, yval := e.evalFloats(env)
+		return NumberValue(xval * yval)
+	case Greater:
+		xval, yval := e.comparands(env)
+		return BoolValue(xval > yval)
+	case GreaterEqual:
+		xval, yval := e.comparands(env)
+		return BoolValue(xval >= yval)
+	case Less:
+		xval, yval := e.comparands(env)
+		return BoolValue(xval < yval)
+	case LessEqual:
+		xval, yval := e.comparands(env)
+		return BoolValue(xval <= yval)
+	case EqualEqual:
+		return BoolValue(e.equal(env))
+	case BangEqual:
+		return BoolValue(!e.equal(env))
+	}
+	return nilValue // Unreachable?
+}
+
+func (e *BinaryExpr) evalFloats(env *Env) (float64, float64) {
+	x := e.left.eval(env)
+	y := e.right.eval(env)
+	if x.kind != numKind || y.kind != numKind {
+		env.runtimeErr(e.operator, operandsError(e.operator.lexeme, "numbers", x, y))
+	}
+	return x.num, y.num
+}
+
+// comparands evaluates the operands of a comparison into numbers that
+// compare like them, like the function comparands.
+func (e *BinaryExpr) comparands(env *Env) (float64, float64) {
+	x := e.left.eval(env)
+	y := e.right.eval(env)
+	a, b, ok := comparands(x, y)
+	if !ok {
+		env.runtimeErr(e.operator, operandsError(e.operator.lexeme, "two numbers or two strings", x, y))
+	}
+	return a, b
+}
+
+func (e *BinaryExpr) equal(env *Env) bool {
+	return isEqual(e.left.eval(env), e.right.eval(env))
+}
+
+// callSite remembers the function that a CallExpr called last, which was
+// already checked to be callable with the number of arguments there, so that
+// calling it again goes straight to the call.
+type callSite struct {
+	obj    interface{} // the callee as stored in its value
+	fn     Callable
+	native *nativeFn
+}
+
+func (e *CallExpr) eval(env *Env) Value {
+	callee := e.callee.eval(env)
+	args := make([]Value, 0, len(e.args))
+	for _, a := range e.args {
+		args = append(args, a.eval(env))
+	}
+	if callee.kind != funKind || callee.obj != e.site.obj {
+		e.site = e.check(env, callee, len(args))
+	}
+	if env.in.Hooks != nil {
+		env.in.onCall(e.site.fn, args)
+		v := e.call(env, args)
+		env.in.onReturn(v)
+		return v
+	}
+	return e.call(env, args)
+}
+
+// call calls the function of the site, which was checked already.
+func (e *CallExpr) call(env *Env, args []Value) Value {
+	if e.site.native != nil {
+		return callNative(e.paren, e.site.native, env, args)
+	}
+	in := env.in
+	if len(in.callStack) >= in.MaxCallDepth {
+		env.runtimeErr(e.paren, fmt.Sprintf("stack overflow: exceeded %v frames", in.MaxCallDepth))
+	}
+	if in.watch {
+		env.step(e.paren)
+	}
+	in.callStack = append(in.callStack, frame{fn: e.site.fn, line: e.paren.line})
+	defer func() { in.callStack = in.callStack[:len(in.callStack)-1] }()
+	return e.site.fn.call(env, args)
+}
+
+// check makes sure that callee can be called with argc arguments.
+func (e *CallExpr) check(env *Env, callee Value, argc int) callSite {
+	if callee.kind != funKind {
+		env.runtimeErr(e.paren, fmt.Sprintf("'%v' is not a function or class", callee))
+	}
+	fn := callee.asCallable()
+	if argc != fn.arity() {
+		env.runtimeErr(e.paren,
+			fmt.Sprintf("expected %v arguments but got %v", fn.arity(), argc))
+	}
+	n, _ := fn.(*nativeFn)
+	return callSite{obj: callee.obj, fn: fn, native: n}
+}
+
+func (s *FunExpr) eval(env *Env) Value {
+	env.allocate(s.keyword, functionSize)
+	return funValue(&FunAnon{decl: s, closure: env})
+}
+
+func (e *GroupingExpr) eval(env *Env) Value {
+	return e.e.eval(env)
+}
+
+func (e *LiteralExpr) eval(env *Env) Value {
+	return e.value
+}
+
+func (e *LogicalExpr) eval(env *Env) Value {
+	left := e.left.eval(env)
+	if e.operator.tok == Or {
+		if isTruthy(left) {
+			return left
+		}
+	} else {
+		if !isTruthy(left) {
+			return left
+		}
+	}
+	return e.right.eval(env)
+}
+
+func (e *UnaryExpr) eval(env *Env) Value {
+	val := e.right.eval(env)
+	switch e.operator.tok {
+	case Minus:
+		if val.kind != numKind {
+			env.runtimeErr(e.operator, "operand must be a number")
+		}
+		return NumberValue(-val.num)
+	case Bang:
+		return BoolValue(!isTruthy(val))
+	}
+	// unreachable?
+	return nilValue
+}
+
+func (e *VarExpr) eval(env *Env) Value {
+	return env.get(e.name, &e.binding)
+}
+
+func (e *AssignExpr) eval(env *Env) Value {
+	v := e.value.eval(env)
+	env.assign(e.name, &e.binding, v)
+	return v
+}
+
+// --------------------------------------------------------
+// Statements
+
+func (s *ExprStmt) execute(env *Env) {
+	s.expression.eval(env)
+}
+
+func (s *FunStmt) execute(env *Env) {
+	env.allocate(s.name, functionSize)
+	fn := &FunObj{decl: s, closure: env}
+	env.define(s.slot, s.name.lexeme, funValue(fn))
+}
+
+func (s *PrintStmt) execute(env *Env) {
+	v := s.expression.eval(env)
+	fmt.Fprintln(env.in.Stdout, v.String())
+}
+
+func (s *VarStmt) execute(env *Env) {
+	// make distinction between uninitialized value and nil-value
+	if s.init != nil {
+		env.define(s.slot, s.name.lexeme, s.init.eval(env))
+	} else {
+		env.define(s.slot, s.name.lexeme, unassigned)
+	}
+}
+
+func (s *BlockStmt) execute(env *Env) {
+	env = NewEnv(env, len(s.slots))
+	if env.in.Hooks != nil {
+		env.in.pushScope(env, s.slots, false)
+		defer env.in.popScope()
+	}
+	execBlock(s.list, env)
+}
+
+func execBlock(list []Stmt, env *Env) {
+	for _, s := range list {
+		if env.in.Hooks != nil {
+			env.in.onStatement(s)
+		}
+		s.execute(env)
+	}
+}
+
+func (s *IfStmt) execute(env *Env) {
+	if isTruthy(s.condition.eval(env)) {
+		execStmt(s.block1, env)
+	} else if s.block2 != nil {
+		execStmt(s.block2, env)
+	}
+}
+
+// execStmt executes the statement nested in another one.
+func execStmt(s Stmt, env *Env) {
+	if env.in.Hooks != nil {
+		env.in.onStatement(s)
+	}
+	s.execute(env)
+}
+
+func (s *ReturnStmt) execute(env *Env) {
+	var v Value
+	if s.value != nil {
+		v = s.value.eval(env)
+	}
+	// Ugly hack, panic to unwind the stack back to the call
+	panic(ReturnHack{v})
+}
+
+func (s *BreakStmt) execute(env *Env) {
+	panic(BreakErr{t: s.keyword})
+}
+
+func (s *ContinueStmt) execute(env *Env) {
+	panic(ContinueErr{t: s.keyword})
+}
+
+func (s *WhileStmt) execute(env *Env) {
+	for !s.isDone(env) {
+		// continued
+		if env.in.watch {
+			env.step(s.keyword)
+		}
+	}
+}
+
+// isDone returns false when the loop was continued,
+// when loop is done returns true
+func (s *WhileStmt) isDone(env *Env) (done bool) {
+	defer func() {
+		if e := recover(); e != nil {
+			switch e.(type) {
+			case ContinueErr:
+				done = false
+				return
+			case BreakErr:
+				done = true
+				return
+			default:
+				panic(e)
+			}
+		}
+	}()
+	for isTruthy(s.condition.eval(env)) {
+		execStmt(s.body, env)
+		if env.in.watch {
+			env.step(s.keyword)
+		}
+	}
+	return true
+}
@@ -0,0 +1,22 @@
+package lox
+
+import "testing"
+
+// TestUnassigned checks that a variable declared without an initializer
+// cannot be read before something is assigned to it, while an explicit nil
+// reads fine.
+func TestUnassigned(t *testing.T) {
+	tests := []programTest{
+		{`var a = nil; print a;`, "nil\n"},
+		{`var a; a = 1; print a;`, "1\n"},
+		{`var a; a = nil; print a;`, "nil\n"},
+		{`var a; print a;`, "[line 1] runtime error: variable 'a' used before assignment"},
+		{`fun f() { var b; return b; } print f();`, "[line 1] runtime error: variable 'b' used before assignment\n  [line 1] in f()\n  [line 1] in script"},
+		{`var c; if (false) c = 1; print c;`, "[line 1] runtime error: variable 'c' used before assignment"},
+		{`var c; if (true) c = 1; print c;`, "1\n"},
+		{`var d; { var d; d = 2; } print d;`, "[line 1] runtime error: variable 'd' used before assignment"},
+		{`var a; fun f() { return a; } a = 3; print f();`, "3\n"},
+		{`fun f() { var x; fun g() { return x; } x = "late"; return g; } print f()();`, "late\n"},
+	}
+	testPrograms(t, tests)
+}
@@ -0,0 +1,431 @@
+package lox
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//go:embed jsgen.js
+var jsRuntime string
+
+// jsNatives are the natives that the translations to JavaScript have, the
+// others need the host.
+var jsNatives = map[string]bool{"clock": true, "args": true, "arg": true, "toString": true, "deepEquals": true}
+
+// jsReserved are the words that JavaScript does not allow as the names of
+// variables in strict mode, or that would hide its own values.
+var jsReserved = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`arguments await break case catch class
+		const continue debugger default delete do else enum eval export extends
+		false finally for function if implements import in instanceof interface
+		let new null package private protected public return static super switch
+		this throw true try typeof var void while with yield undefined NaN Infinity`) {
+		jsReserved[w] = true
+	}
+}
+
+// TranslateJS translates the program in source into a script of
+// JavaScript that runs in browsers and Node.js without the interpreter.
+// The functions of the script become functions of JavaScript and its
+// variables let bindings, and the runtime that checks the operands and
+// formats the values like the interpreter is included in the script. Of
+// the natives only clock, args, arg, toString and deepEquals are supported.
+func TranslateJS(source string) ([]byte, error) {
+	toks, err := NewScanner(source).Scan()
+	if err != nil {
+		return nil, err
+	}
+	stmts, errs := NewParser(toks).Parse()
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	g := &jsGen{
+		table:    Resolve(stmts),
+		names:    make(map[*Symbol]string),
+		checked:  make(map[*Symbol]bool),
+		declared: make(map[*Symbol]bool),
+		defined:  make(map[string]bool),
+	}
+	for _, s := range stmts {
+		Inspect(s, func(n Node) bool {
+			if v, ok := n.(*VarStmt); ok && v.init == nil {
+				g.checked[g.table.Lookup(v.name)] = true
+			}
+			return true
+		})
+	}
+
+	// The locals whose names are taken by other variables are numbered, so
+	// that the scopes of JavaScript resolve them as the resolver did.
+	count := make(map[string]int)
+	for _, sym := range g.table.Symbols() {
+		count[sym.name]++
+	}
+	n := 0
+	for _, sym := range g.table.Symbols() {
+		switch {
+		case !sym.global && count[sym.name] > 1:
+			n++
+			g.names[sym] = fmt.Sprintf("%v$%v", sym.name, n)
+		case jsReserved[sym.name]:
+			g.names[sym] = sym.name + "$"
+		default:
+			g.names[sym] = sym.name
+		}
+	}
+
+	g.b.WriteString(`"use strict";` + "\n\n")
+	g.b.WriteString(jsRuntime)
+	g.line("")
+	g.line("$main(function () {")
+	g.indent++
+	for _, sym := range g.table.Symbols() {
+		if !sym.global {
+			continue
+		}
+		if sym.kind == NativeSymbol {
+			if !jsNatives[sym.name] {
+				g.errs = append(g.errs, fmt.Errorf("[line %v] error: the native '%v' is not supported in JavaScript",
+					sym.refs[0].line, sym.name))
+			}
+			g.line("let %v = $natives.%v;", g.names[sym], sym.name)
+			g.defined[sym.name] = true
+		} else {
+			g.line("let %v = $undefined;", g.names[sym])
+		}
+	}
+	for _, s := range stmts {
+		g.stmt(s)
+		switch s := s.(type) {
+		case *FunStmt:
+			g.defined[s.name.lexeme] = true
+		case *VarStmt:
+			g.defined[s.name.lexeme] = s.init != nil
+		}
+	}
+	g.indent--
+	g.line("});")
+	if len(g.errs) > 0 {
+		return nil, errors.Join(g.errs...)
+	}
+	return g.b.Bytes(), nil
+}
+
+// jsGen writes the JavaScript code of a program.
+type jsGen struct {
+	b        bytes.Buffer
+	indent   int
+	table    *SymbolTable
+	names    map[*Symbol]string // the variables of JavaScript
+	checked  map[*Symbol]bool   // the locals that may be read before they are assigned
+	declared map[*Symbol]bool   // the locals declared in JavaScript so far
+	defined  map[string]bool    // the globals surely defined in the code at the top level
+	depth    int                // of the functions that enclose the code
+	errs     []error
+}
+
+func (g *jsGen) line(format string, args ...interface{}) {
+	if format != "" {
+		g.b.WriteString(strings.Repeat("  ", g.indent))
+	}
+	fmt.Fprintf(&g.b, format+"\n", args...)
+}
+
+// name returns the variable of JavaScript of the variable of the script
+// at t.
+func (g *jsGen) name(t *Token) string {
+	if sym := g.table.Lookup(t); sym != nil {
+		return g.names[sym]
+	}
+	return t.lexeme
+}
+
+// global tells if the variable at t is a global.
+func (g *jsGen) global(t *Token) bool {
+	sym := g.table.Lookup(t)
+	return sym == nil || sym.global
+}
+
+// checkedGlobal tells if the global at t needs checks to be read or
+// assigned, that is when the code may run before the global is defined.
+func (g *jsGen) checkedGlobal(t *Token) bool {
+	return g.global(t) && (g.depth > 0 || !g.defined[t.lexeme])
+}
+
+// declareBody declares the local that s declares before s is written as
+// the body of an if or a loop, where it belongs to the enclosing scope.
+func (g *jsGen) declareBody(s Stmt) {
+	var name *Token
+	switch s := s.(type) {
+	case *FunStmt:
+		name = s.name
+	case *VarStmt:
+		name = s.name
+	default:
+		return
+	}
+	if sym := g.table.Lookup(name); sym != nil && !sym.global && !g.declared[sym] {
+		g.declared[sym] = true
+		g.line("let %v = $unassigned;", g.names[sym])
+	}
+}
+
+// block writes the statements in list in braces after the code in head,
+// all but the newline after the closing brace.
+func (g *jsGen) block(head string, list []Stmt) {
+	g.line("%v{", head)
+	g.blockEnd(list)
+}
+
+// blockEnd writes the statements in list and the closing brace of a block
+// that is open.
+func (g *jsGen) blockEnd(list []Stmt) {
+	g.indent++
+	for _, s := range list {
+		g.stmt(s)
+	}
+	g.indent--
+	g.b.WriteString(strings.Repeat("  ", g.indent) + "}")
+}
+
+// stmtList returns the statements of the body of an if or a loop.
+func stmtList(s Stmt) []Stmt {
+	if b, ok := s.(*BlockStmt); ok {
+		return b.list
+	}
+	return []Stmt{s}
+}
+
+func (g *jsGen) stmt(s Stmt) {
+	switch s := s.(type) {
+	case *BlockStmt:
+		g.block("", s.list)
+		g.line("")
+	case *BreakStmt:
+		g.line("break;")
+	case *ContinueStmt:
+		// the increment of a for loop is in its body, so it is skipped as
+		// it is by the interpreter
+		g.line("continue;")
+	case *ExprStmt:
+		g.line("%v;", g.expr(s.expression))
+	case *FunStmt:
+		g.define(s.name, g.function(s.name.lexeme, s.params, s.body))
+	case *IfStmt:
+		// the chains of else ifs are written as in JavaScript
+		for c := s; ; {
+			g.declareBody(c.block1)
+			next, ok := c.block2.(*IfStmt)
+			if !ok {
+				if c.block2 != nil {
+					g.declareBody(c.block2)
+				}
+				break
+			}
+			c = next
+		}
+		g.block(fmt.Sprintf("if (%v) ", g.condition(s.condition)), stmtList(s.block1))
+		for alt := s.block2; alt != nil; {
+			next, ok := alt.(*IfStmt)
+			if !ok {
+				g.b.WriteString(" else {\n")
+				g.blockEnd(stmtList(alt))
+				break
+			}
+			fmt.Fprintf(&g.b, " else if (%v) {\n", g.condition(next.condition))
+			g.blockEnd(stmtList(next.block1))
+			alt = next.block2
+		}
+		g.line("")
+	case *PrintStmt:
+		g.line("$print(%v);", g.expr(s.expression))
+	case *ReturnStmt:
+		if s.value == nil {
+			g.line("return;")
+		} else {
+			g.line("return %v;", g.expr(s.value))
+		}
+	case *VarStmt:
+		if s.init == nil {
+			g.define(s.name, "$unassigned")
+		} else {
+			g.define(s.name, g.expr(s.init))
+		}
+	case *WhileStmt:
+		g.declareBody(s.body)
+		g.block(fmt.Sprintf("while (%v) ", g.condition(s.condition)), stmtList(s.body))
+		g.line("")
+	}
+}
+
+// define writes the declaration of the variable or the function name with
+// the value in the code v.
+func (g *jsGen) define(name *Token, v string) {
+	sym := g.table.Lookup(name)
+	if sym.global || g.declared[sym] {
+		g.line("%v = %v;", g.names[sym], v)
+		return
+	}
+	g.declared[sym] = true
+	g.line("let %v = %v;", g.names[sym], v)
+}
+
+// function returns the code of a function of the script.
+func (g *jsGen) function(name string, params []*Token, body []Stmt) string {
+	outer := g.b
+	g.b = bytes.Buffer{}
+	g.depth++
+
+	quoted := make([]string, len(params))
+	vars := make([]string, len(params))
+	for i, p := range params {
+		quoted[i] = jsString(p.lexeme)
+		sym := g.table.Lookup(p)
+		g.declared[sym] = true
+		vars[i] = g.names[sym]
+	}
+	fmt.Fprintf(&g.b, "$fn(%v, [%v], function (%v) {\n",
+		jsString(name), strings.Join(quoted, ", "), strings.Join(vars, ", "))
+	g.blockEnd(body)
+	g.b.WriteString(")")
+
+	code := g.b.String()
+	g.b = outer
+	g.depth--
+	return code
+}
+
+// jsString returns s quoted for JavaScript.
+func jsString(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// boolean tells if e surely evaluates to a boolean, so that it needs no
+// conversion to be a condition of JavaScript.
+func boolean(e Expr) bool {
+	switch e := e.(type) {
+	case *GroupingExpr:
+		return boolean(e.e)
+	case *BinaryExpr:
+		switch e.operator.tok {
+		case EqualEqual, BangEqual, Less, LessEqual, Greater, GreaterEqual:
+			return true
+		}
+	case *UnaryExpr:
+		return e.operator.tok == Bang
+	case *LiteralExpr:
+		return e.value.kind == boolKind
+	}
+	return false
+}
+
+// condition returns the code of e as the condition of an if or a loop.
+func (g *jsGen) condition(e Expr) string {
+	if boolean(e) {
+		return g.expr(e)
+	}
+	return fmt.Sprintf("$truthy(%v)", g.expr(e))
+}
+
+// operand returns the code of e as an operand of === or !, in parentheses
+// if it is an assignment or a comparison for equality.
+func (g *jsGen) operand(e Expr) string {
+	code := g.expr(e)
+	for {
+		group, ok := e.(*GroupingExpr)
+		if !ok {
+			break
+		}
+		e = group.e
+	}
+	switch e := e.(type) {
+	case *AssignExpr:
+		return "(" + code + ")"
+	case *BinaryExpr:
+		if e.operator.tok == EqualEqual || e.operator.tok == BangEqual {
+			return "(" + code + ")"
+		}
+	}
+	return code
+}
+
+// expr returns the code of e.
+func (g *jsGen) expr(e Expr) string {
+	switch e := e.(type) {
+	case *AssignExpr:
+		name, v := g.name(e.name), g.expr(e.value)
+		if g.checkedGlobal(e.name) {
+			return fmt.Sprintf("%v = $set(%v, %q, %v, %v)", name, e.name.line, e.name.lexeme, name, v)
+		}
+		return fmt.Sprintf("%v = %v", name, v)
+	case *BinaryExpr:
+		line := e.operator.line
+		switch e.operator.tok {
+		case EqualEqual:
+			return fmt.Sprintf("%v === %v", g.operand(e.left), g.operand(e.right))
+		case BangEqual:
+			return fmt.Sprintf("%v !== %v", g.operand(e.left), g.operand(e.right))
+		}
+		op := map[TokenType]string{
+			Plus: "$add", Minus: "$sub", Star: "$mul", Slash: "$div",
+			Less: "$less", LessEqual: "$lessEqual", Greater: "$greater", GreaterEqual: "$greaterEqual",
+		}[e.operator.tok]
+		return fmt.Sprintf("%v(%v, %v, %v)", op, line, g.expr(e.left), g.expr(e.right))
+	case *CallExpr:
+		args := []string{strconv.Itoa(e.paren.line), g.expr(e.callee)}
+		for _, a := range e.args {
+			args = append(args, g.expr(a))
+		}
+		return fmt.Sprintf("$call(%v)", strings.Join(args, ", "))
+	case *FunExpr:
+		return g.function("", e.params, e.body)
+	case *GroupingExpr:
+		return g.expr(e.e)
+	case *LiteralExpr:
+		switch e.value.kind {
+		case boolKind:
+			return strconv.FormatBool(e.value.asBool())
+		case numKind:
+			if e.value.num >= 1e21 {
+				return strconv.FormatFloat(e.value.num, 'g', -1, 64)
+			}
+			return strconv.FormatFloat(e.value.num, 'f', -1, 64)
+		case strKind:
+			return jsString(e.value.asString())
+		}
+		return "null"
+	case *LogicalExpr:
+		fn := "$and"
+		if e.operator.tok == Or {
+			fn = "$or"
+		}
+		return fmt.Sprintf("%v(%v, () => %v)", fn, g.expr(e.left), g.expr(e.right))
+	case *UnaryExpr:
+		if e.operator.tok == Minus {
+			return fmt.Sprintf("$neg(%v, %v)", e.operator.line, g.expr(e.right))
+		}
+		if boolean(e.right) {
+			return "!" + g.operand(e.right)
+		}
+		return fmt.Sprintf("!$truthy(%v)", g.expr(e.right))
+	case *VarExpr:
+		name := g.name(e.name)
+		sym := g.table.Lookup(e.name)
+		if g.checkedGlobal(e.name) || sym != nil && g.checked[sym] {
+			return fmt.Sprintf("$get(%v, %q, %v)", e.name.line, e.name.lexeme, name)
+		}
+		return name
+	}
+	return "null"
+}
@@ -0,0 +1,299 @@
+// The runtime of the scripts that glox build -target=js translates. The
+// values of Lox are those of JavaScript: null, booleans, numbers, strings
+// and the functions that $fn marks. The output of print goes to
+// globalThis.loxPrint if it is a function, to console.log otherwise.
+
+const $undefined = Symbol("undefined"); // of a global never declared
+const $unassigned = Symbol("unassigned"); // of a variable declared without a value
+const $maxCallDepth = 10000;
+const $traceDepth = 10;
+const $stack = []; // the active calls: the function and the line of the call site
+
+class $RuntimeError extends Error {
+  constructor(line, msg) {
+    super(msg);
+    this.line = line;
+    this.trace = $stack.slice();
+  }
+
+  toString() {
+    let s = `[line ${this.line}] runtime error: ${this.message}`;
+    if (this.trace.length === 0) {
+      return s;
+    }
+    let lines = [];
+    let line = this.line;
+    for (let i = this.trace.length - 1; i >= 0; i--) {
+      const f = this.trace[i].f.lox;
+      lines.push(`  [line ${line}] in ${f.name === "" ? "anonymous function" : f.name + "()"}`);
+      line = this.trace[i].line;
+    }
+    lines.push(`  [line ${line}] in script`);
+    const n = lines.length;
+    if ($traceDepth > 0 && n > 2 * $traceDepth) {
+      const elided = `  ... ${(n - 2 * $traceDepth).toLocaleString("en-US")} frames elided ...`;
+      lines = [...lines.slice(0, $traceDepth), elided, ...lines.slice(n - $traceDepth)];
+    }
+    return s + "\n" + lines.join("\n");
+  }
+}
+
+function $fail(line, msg) {
+  throw new $RuntimeError(line, msg);
+}
+
+function $out(s) {
+  if (typeof globalThis.loxPrint === "function") {
+    globalThis.loxPrint(s);
+  } else {
+    console.log(s);
+  }
+}
+
+// $str formats v like print does, numbers in decimal but for those below
+// 1e-4 or from 1e21 on.
+function $str(v) {
+  switch (typeof v) {
+    case "boolean":
+      return String(v);
+    case "string":
+      return v;
+    case "function": {
+      const f = v.lox;
+      if (f.native) {
+        return `<native fn ${f.name}>`;
+      }
+      return f.name === "" ? `<lambda (${f.params.join(",")})>` : `<fn ${f.name}>`;
+    }
+    case "number": {
+      if (Number.isNaN(v)) {
+        return "NaN";
+      }
+      if (!Number.isFinite(v)) {
+        return v > 0 ? "+Inf" : "-Inf";
+      }
+      if (v === 0) {
+        return Object.is(v, -0) ? "-0" : "0";
+      }
+      const [mant, exp] = v.toExponential().split("e");
+      const e = Number(exp);
+      if (e < -4 || e >= 21) {
+        return mant + "e" + (e < 0 ? "-" : "+") + String(Math.abs(e)).padStart(2, "0");
+      }
+      return String(v);
+    }
+  }
+  return "nil";
+}
+
+function $print(v) {
+  $out($str(v));
+}
+
+function $truthy(v) {
+  return v !== null && v !== false;
+}
+
+function $or(x, y) {
+  return $truthy(x) ? x : y();
+}
+
+function $and(x, y) {
+  return $truthy(x) ? y() : x;
+}
+
+// $get returns v, the value of the variable name at line, unless it was
+// never declared or assigned.
+function $get(line, name, v) {
+  if (v === $undefined) {
+    $fail(line, `undefined variable '${name}'`);
+  }
+  if (v === $unassigned) {
+    $fail(line, `variable '${name}' used before assignment`);
+  }
+  return v;
+}
+
+// $set returns v, to be assigned to the global name at line whose value is
+// old.
+function $set(line, name, old, v) {
+  if (old === $undefined) {
+    $fail(line, `undefined variable '${name}'`);
+  }
+  return v;
+}
+
+// $fn makes f a Lox function called name, empty for an anonymous one.
+function $fn(name, params, f) {
+  f.lox = { name, params, native: false };
+  return f;
+}
+
+function $call(line, f, ...args) {
+  if (typeof f !== "function") {
+    $fail(line, `'${$str(f)}' is not a function or class`);
+  }
+  if (args.length !== f.lox.params.length) {
+    $fail(line, `expected ${f.lox.params.length} arguments but got ${args.length}`);
+  }
+  if (f.lox.native) {
+    try {
+      return f(...args);
+    } catch (e) {
+      if (e instanceof $RuntimeError) {
+        throw e;
+      }
+      $fail(line, `native '${f.lox.name}' failed: ${e.message}`);
+    }
+  }
+  if ($stack.length >= $maxCallDepth) {
+    $fail(line, `stack overflow: exceeded ${$maxCallDepth} frames`);
+  }
+  $stack.push({ f, line });
+  try {
+    const v = f(...args);
+    return v === undefined ? null : v;
+  } catch (e) {
+    // The stack of JavaScript may be shallower than $maxCallDepth calls.
+    if (e instanceof RangeError) {
+      $fail(line, `stack overflow: exceeded ${$stack.length} frames`);
+    }
+    throw e;
+  } finally {
+    $stack.pop();
+  }
+}
+
+// $type names the type of v like Value.Type of the interpreter.
+function $type(v) {
+  switch (typeof v) {
+    case "function":
+      return v.lox.native ? "native" : "function";
+    case "object":
+      return "nil";
+  }
+  return typeof v;
+}
+
+function $operandsError(line, op, want, x, y) {
+  $fail(line, `operands of '${op}' must be ${want}, got ${$type(x)} and ${$type(y)}`);
+}
+
+function $numbers(line, op, x, y) {
+  if (typeof x !== "number" || typeof y !== "number") {
+    $operandsError(line, op, "numbers", x, y);
+  }
+}
+
+// $compare compares the strings x and y by their code points, like Go
+// compares the bytes of strings, the operands of op that are not two
+// numbers.
+function $compare(line, op, x, y) {
+  if (typeof x !== "string" || typeof y !== "string") {
+    $operandsError(line, op, "two numbers or two strings", x, y);
+  }
+  for (let i = 0; i < x.length && i < y.length; i++) {
+    const a = x.codePointAt(i);
+    const b = y.codePointAt(i);
+    if (a !== b) {
+      return a < b ? -1 : 1;
+    }
+  }
+  return x.length - y.length;
+}
+
+function $add(line, x, y) {
+  if ((typeof x === "number" && typeof y === "number") || (typeof x === "string" && typeof y === "string")) {
+    return x + y;
+  }
+  $operandsError(line, "+", "two numbers or two strings", x, y);
+}
+
+function $sub(line, x, y) {
+  $numbers(line, "-", x, y);
+  return x - y;
+}
+
+function $mul(line, x, y) {
+  $numbers(line, "*", x, y);
+  return x * y;
+}
+
+function $div(line, x, y) {
+  $numbers(line, "/", x, y);
+  if (y === 0) {
+    $fail(line, "division by zero");
+  }
+  return x / y;
+}
+
+function $less(line, x, y) {
+  if (typeof x === "number" && typeof y === "number") {
+    return x < y;
+  }
+  return $compare(line, "<", x, y) < 0;
+}
+
+function $lessEqual(line, x, y) {
+  if (typeof x === "number" && typeof y === "number") {
+    return x <= y;
+  }
+  return $compare(line, "<=", x, y) <= 0;
+}
+
+function $greater(line, x, y) {
+  if (typeof x === "number" && typeof y === "number") {
+    return x > y;
+  }
+  return $compare(line, ">", x, y) > 0;
+}
+
+function $greaterEqual(line, x, y) {
+  if (typeof x === "number" && typeof y === "number") {
+    return x >= y;
+  }
+  return $compare(line, ">=", x, y) >= 0;
+}
+
+function $neg(line, x) {
+  if (typeof x !== "number") {
+    $fail(line, "operand must be a number");
+  }
+  return -x;
+}
+
+// The arguments of the script, those of the command line under Node.js.
+const $args = typeof process === "object" && Array.isArray(process.argv) ? process.argv.slice(2) : [];
+
+function $native(name, params, f) {
+  f.lox = { name, params, native: true };
+  return f;
+}
+
+const $natives = {
+  clock: $native("clock", [], () => Date.now() * 1e6),
+  args: $native("args", [], () => $args.length),
+  arg: $native("arg", ["i"], (i) => {
+    if (typeof i !== "number" || !Number.isInteger(i)) {
+      throw new Error("argument 1 must be an integer");
+    }
+    return i >= 0 && i < $args.length ? $args[i] : null;
+  }),
+  toString: $native("toString", ["v"], (v) => $str(v)),
+  deepEquals: $native("deepEquals", ["a", "b"], (a, b) => a === b || (a !== a && b !== b)),
+};
+
+// $main runs the script in run and prints its runtime error, if any.
+function $main(run) {
+  try {
+    run();
+  } catch (e) {
+    if (!(e instanceof $RuntimeError)) {
+      throw e;
+    }
+    $out(e.toString());
+    if (typeof process === "object") {
+      process.exitCode = 1;
+    }
+  }
+}
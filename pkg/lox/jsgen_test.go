@@ -0,0 +1,62 @@
+package lox
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestTranslateJS translates each program in testdata/jsgen and compares
+// the code after the runtime with the .golden file next to it. If Node.js
+// is installed, the translation must also print what the interpreter does.
+func TestTranslateJS(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "jsgen", "*.lox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no programs in testdata/jsgen")
+	}
+	node, err := exec.LookPath("node")
+	if err != nil || testing.Short() {
+		node = ""
+	}
+	for _, name := range files {
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			src, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(strings.TrimSuffix(name, ".lox") + ".golden")
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, err := TranslateJS(string(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			prefix := "\"use strict\";\n\n" + jsRuntime
+			if !bytes.HasPrefix(code, []byte(prefix)) {
+				t.Fatal("the translation does not start with the runtime")
+			}
+			if got := string(code[len(prefix):]); got != string(want) {
+				t.Errorf("got:\n%v\nwant:\n%v", got, want)
+			}
+			if node == "" {
+				return
+			}
+			out := runProgram(t, string(src), WithBackend("tree"))
+			script := filepath.Join(t.TempDir(), "script.js")
+			if err := os.WriteFile(script, code, 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, _ := exec.Command(node, script).CombinedOutput()
+			if strings.TrimSuffix(string(got), "\n") != strings.TrimSuffix(out, "\n") {
+				t.Errorf("node printed:\n%s\nthe interpreter:\n%v", got, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package lox
+
+import (
+	"fmt"
+	"sort"
+)
+
+// LintRule is a check of Lint.
+type LintRule struct {
+	Name string
+	Doc  string // what the rule finds
+}
+
+// LintRules are the rules of Lint.
+var LintRules = []LintRule{
+	{"shadow", "a variable, parameter or function that hides one of the same name declared outside of it"},
+	{"constant-condition", "an if or a loop whose condition is made of literals only, while (true) aside"},
+	{"assign-in-condition", "an assignment used as the condition of an if or a loop, which is often a mistyped =="},
+	{"empty-block", "a block without statements other than the body of a function"},
+	{"long-params", "a function with more parameters than allowed, 5 unless set otherwise"},
+	{"unused-result", "an expression statement that neither calls nor assigns, so its value is thrown away"},
+}
+
+// LintOptions select the rules of Lint.
+type LintOptions struct {
+	// Disabled are the names of the rules that are not checked.
+	Disabled map[string]bool
+
+	// MaxParams is the number of parameters a function may have without
+	// long-params reporting it, 0 means 5.
+	MaxParams int
+}
+
+// Lint checks stmts with the rules that opts enables and returns what they
+// find in source order, the message of a finding names its rule.
+func Lint(stmts []Stmt, opts LintOptions) []Finding {
+	l := &linter{opts: opts, scopes: []map[string]*Token{{}}}
+	if l.opts.MaxParams <= 0 {
+		l.opts.MaxParams = 5
+	}
+	for _, s := range stmts {
+		Walk(l, s)
+	}
+	sort.SliceStable(l.findings, func(i, j int) bool {
+		return l.findings[i].At.pos < l.findings[j].At.pos
+	})
+	return l.findings
+}
+
+// linter is the Visitor of Lint, it keeps the scopes like the resolver
+// does to find the shadowed names.
+type linter struct {
+	opts     LintOptions
+	scopes   []map[string]*Token // the declarations in every scope, the globals first
+	findings []Finding
+}
+
+func (l *linter) report(rule string, at *Token, format string, args ...interface{}) {
+	if at == nil || l.opts.Disabled[rule] {
+		return
+	}
+	l.findings = append(l.findings, Finding{at, fmt.Sprintf(format, args...) + " (" + rule + ")"})
+}
+
+func (l *linter) Enter(n Node) bool {
+	switch n := n.(type) {
+	case *BlockStmt:
+		if len(n.list) == 0 {
+			l.report("empty-block", firstToken(n), "empty block")
+		}
+		l.scopes = append(l.scopes, map[string]*Token{})
+	case *ExprStmt:
+		if !hasEffect(n.expression) {
+			l.report("unused-result", firstToken(n), "the value of the expression is not used")
+		}
+	case *FunStmt:
+		l.declare(n.name)
+		l.function(n.name, n.params)
+	case *FunExpr:
+		l.function(n.keyword, n.params)
+	case *IfStmt:
+		l.condition(n.condition, "if")
+	case *VarStmt:
+		l.declare(n.name)
+	case *WhileStmt:
+		l.condition(n.condition, n.keyword.lexeme)
+	}
+	return true
+}
+
+func (l *linter) Leave(n Node) {
+	switch n.(type) {
+	case *BlockStmt, *FunStmt, *FunExpr:
+		l.scopes = l.scopes[:len(l.scopes)-1]
+	}
+}
+
+// function opens the scope of a function with its parameters.
+func (l *linter) function(at *Token, params []*Token) {
+	if len(params) > l.opts.MaxParams {
+		l.report("long-params", at, "function has %v parameters, more than %v", len(params), l.opts.MaxParams)
+	}
+	l.scopes = append(l.scopes, map[string]*Token{})
+	for _, p := range params {
+		l.declare(p)
+	}
+}
+
+// declare adds name to the innermost scope, reporting the declaration of
+// an outer scope that it hides.
+func (l *linter) declare(name *Token) {
+	inner := len(l.scopes) - 1
+	for i := inner - 1; i >= 0; i-- {
+		if outer, ok := l.scopes[i][name.lexeme]; ok {
+			l.report("shadow", name, "'%v' shadows the declaration at line %v", name.lexeme, outer.line)
+			break
+		}
+	}
+	l.scopes[inner][name.lexeme] = name
+}
+
+func (l *linter) condition(cond Expr, keyword string) {
+	if v, ok := constValue(cond); ok && !(keyword == "while" && isTruthy(v) && isLiteral(cond)) {
+		l.report("constant-condition", exprToken(cond), "condition of %v is always %v", keyword, isTruthy(v))
+	}
+	Inspect(cond, func(n Node) bool {
+		switch n := n.(type) {
+		case *AssignExpr:
+			l.report("assign-in-condition", n.name, "assignment to '%v' in the condition of %v", n.name.lexeme, keyword)
+			return false
+		case *LogicalExpr, *GroupingExpr, *UnaryExpr:
+			return true
+		}
+		return false
+	})
+}
+
+// constValue evaluates e like the optimizer folds it if it does not depend
+// on anything but literals, leaving e as it is.
+func constValue(e Expr) (Value, bool) {
+	switch e := e.(type) {
+	case *BinaryExpr:
+		x, xok := constValue(e.left)
+		y, yok := constValue(e.right)
+		if xok && yok {
+			return (&optimizer{}).foldBinary(e.operator.tok, x, y)
+		}
+	case *GroupingExpr:
+		return constValue(e.e)
+	case *LiteralExpr:
+		return e.value, true
+	case *LogicalExpr:
+		x, ok := constValue(e.left)
+		if !ok {
+			break
+		}
+		if isTruthy(x) == (e.operator.tok == Or) {
+			return x, true
+		}
+		return constValue(e.right)
+	case *UnaryExpr:
+		r, ok := constValue(e.right)
+		switch {
+		case ok && e.operator.tok == Bang:
+			return BoolValue(!isTruthy(r)), true
+		case ok && e.operator.tok == Minus && r.kind == numKind:
+			return NumberValue(-r.num), true
+		}
+	}
+	return nilValue, false
+}
+
+// isLiteral tells if e is a literal in parentheses or not.
+func isLiteral(e Expr) bool {
+	for {
+		switch g := e.(type) {
+		case *GroupingExpr:
+			e = g.e
+		case *LiteralExpr:
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// hasEffect tells if evaluating e calls or assigns anything.
+func hasEffect(e Expr) bool {
+	effect := false
+	Inspect(e, func(n Node) bool {
+		switch n.(type) {
+		case *CallExpr, *AssignExpr:
+			effect = true
+		case *FunExpr:
+			// its body runs when it is called, not here
+			return false
+		}
+		return !effect
+	})
+	return effect
+}
+
+// exprToken returns the first token of e that the parser kept, nil for the
+// literals that the optimizer made.
+func exprToken(e Expr) *Token {
+	switch e := e.(type) {
+	case *AssignExpr:
+		return e.name
+	case *BinaryExpr:
+		return exprToken(e.left)
+	case *CallExpr:
+		return exprToken(e.callee)
+	case *FunExpr:
+		return e.keyword
+	case *GroupingExpr:
+		return exprToken(e.e)
+	case *LiteralExpr:
+		return e.token
+	case *LogicalExpr:
+		return exprToken(e.left)
+	case *UnaryExpr:
+		return e.operator
+	case *VarExpr:
+		return e.name
+	}
+	return nil
+}
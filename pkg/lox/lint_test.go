@@ -0,0 +1,69 @@
+package lox
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// lintFindings returns the findings of Lint on src as line:col: msg, one
+// per line.
+func lintFindings(t *testing.T, src string, opts LintOptions) string {
+	t.Helper()
+	var b strings.Builder
+	for _, f := range Lint(parseProgram(t, src), opts) {
+		fmt.Fprintf(&b, "%v:%v: %v\n", f.At.Line(), f.At.Col(), f.Msg)
+	}
+	return b.String()
+}
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`var a = 1; { var a = 2; print a; }`, "1:18: 'a' shadows the declaration at line 1 (shadow)\n"},
+		{"var x = 1;\nfun f(x) { return x; }\nf(1);", "2:7: 'x' shadows the declaration at line 1 (shadow)\n"},
+		{`if (1 < 2) print "y";`, "1:5: condition of if is always true (constant-condition)\n"},
+		{`while (false or nil) print 1;`, "1:8: condition of while is always false (constant-condition)\n"},
+		{`var i = 0; while (true) { i = i + 1; if (i > 2) break; }`, ""},
+		{`var a; if (a = 1) print a;`, "1:12: assignment to 'a' in the condition of if (assign-in-condition)\n"},
+		{`var a; while (!(a = nil)) print a;`, "1:17: assignment to 'a' in the condition of while (assign-in-condition)\n"},
+		{`var a; print a = 1;`, ""},
+		{`if (clock()) {}`, "1:14: empty block (empty-block)\n"},
+		{`fun f() {} f();`, ""},
+		{`fun f(a, b, c, d, e, g) { return a; } f(1, 2, 3, 4, 5, 6);`, "1:5: function has 6 parameters, more than 5 (long-params)\n"},
+		{`var f = fun(a, b, c, d, e, g) { return a; }; f(1, 2, 3, 4, 5, 6);`, "1:9: function has 6 parameters, more than 5 (long-params)\n"},
+		{`var a = 1; a + 1; a == 2;`, "1:12: the value of the expression is not used (unused-result)\n1:19: the value of the expression is not used (unused-result)\n"},
+		{`var a; a = 1; clock(); var g = fun() { 1; };`, "1:40: the value of the expression is not used (unused-result)\n"},
+	}
+	for _, tt := range tests {
+		if got := lintFindings(t, tt.src, LintOptions{}); got != tt.want {
+			t.Errorf("%q:\ngot  %q\nwant %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+// TestLintOptions turns the rules off and sets the parameters that
+// long-params allows.
+func TestLintOptions(t *testing.T) {
+	src := `var a = 1; fun f(a, b, c) { if (true) {} a; } f(1, 2, 3);`
+	tests := []struct {
+		opts LintOptions
+		want string
+	}{
+		{LintOptions{}, "1:18: 'a' shadows the declaration at line 1 (shadow)\n" +
+			"1:33: condition of if is always true (constant-condition)\n" +
+			"1:39: empty block (empty-block)\n" +
+			"1:42: the value of the expression is not used (unused-result)\n"},
+		{LintOptions{Disabled: map[string]bool{"shadow": true, "empty-block": true}},
+			"1:33: condition of if is always true (constant-condition)\n" +
+				"1:42: the value of the expression is not used (unused-result)\n"},
+		{LintOptions{MaxParams: 2, Disabled: map[string]bool{"shadow": true, "constant-condition": true, "empty-block": true, "unused-result": true}},
+			"1:16: function has 3 parameters, more than 2 (long-params)\n"},
+	}
+	for _, tt := range tests {
+		if got := lintFindings(t, src, tt.opts); got != tt.want {
+			t.Errorf("%+v:\ngot  %q\nwant %q", tt.opts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,612 @@
+// Package lox implements the Lox language: the Scanner turns source into
+// tokens, the Parser turns tokens into statements and the Interpreter runs
+// them, either by walking the tree or by compiling it to bytecode for a
+// stack VM.
+package lox
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// Interpreter runs Lox programs. The global variables live as long as the
+// interpreter, every program it runs sees what the previous ones defined.
+// The settings may be changed between the runs.
+//
+// An interpreter runs one program at a time, and the program may spawn
+// tasks that run concurrently with it. Separate interpreters share no
+// state and may run programs on different goroutines at the same time.
+type Interpreter struct {
+	// Backend runs the programs, "tree" walks the AST and "vm" compiles it
+	// to bytecode first.
+	Backend string
+
+	// Optimize folds constant expressions and branches before running.
+	Optimize bool
+
+	// IEEEDivision lets division by zero produce Inf or NaN instead of a
+	// runtime error.
+	IEEEDivision bool
+
+	// MaxCallDepth is the maximum depth of nested function calls.
+	MaxCallDepth int
+
+	// MaxSteps limits the number of steps a run may take, its tasks
+	// included, 0 means no limit. Every loop iteration, every call of a
+	// Lox function and every spawn is a step, the code between them always
+	// runs in bounded time.
+	MaxSteps int
+
+	// MaxAlloc limits the bytes that a run may allocate for the strings,
+	// functions and tasks it makes, 0 means no limit. What is allocated is
+	// counted, not what is still in use, so it bounds the memory a run can
+	// hold no matter when the garbage is collected.
+	MaxAlloc int
+
+	// TraceDepth is the number of innermost and outermost frames shown in
+	// stack traces, 0 shows all of them.
+	TraceDepth int
+
+	// Stdout gets the output of print statements and Stdin is read by
+	// readLine(). The interpreter returns its errors rather than printing
+	// them, Stderr is where the host reports them, as glox does.
+	Stdout, Stderr io.Writer
+	Stdin          io.Reader
+
+	// FS holds the files of readFile() and writeFile(), which scripts may
+	// only call once CapFile is allowed.
+	FS FileSystem
+
+	// Args are the arguments of the script, args() returns how many there
+	// are and arg(i) the one at index i.
+	Args []string
+
+	// Disasm, if set, gets the bytecode of the programs instead of running
+	// them. It implies the vm backend.
+	Disasm io.Writer
+
+	// Hooks, if set, are called as the programs run.
+	Hooks *Hooks
+
+	// Timings, if set, adds up the time that the programs spend in each
+	// phase, from scanning to running.
+	Timings *Timings
+
+	globals *globals
+	sh      *shared // by the tasks, see task.go
+
+	// The rest is the state of a thread of execution, the main program or
+	// a task spawned by it. Tasks run with a copy of the interpreter.
+	callStack []frame    // of the tree-walker
+	scopes    []scopeEnv // of the tree-walker when there are hooks, the innermost last
+	vm        *VM        // created on the first use of the vm backend
+	locked    bool       // holds the lock of sh
+	isTask    bool
+
+	// ctx of the program being run, the loops and calls stop the program
+	// once done is closed. done is nil if the run cannot be cancelled.
+	ctx   context.Context
+	done  <-chan struct{}
+	watch bool // the steps are counted or done is checked
+
+	denied map[Capability]bool // natives that scripts may not call
+}
+
+// Option changes a setting of the interpreter made by New.
+type Option func(*Interpreter)
+
+// WithStdout makes print statements write to w.
+func WithStdout(w io.Writer) Option {
+	return func(in *Interpreter) { in.Stdout = w }
+}
+
+// WithStderr sets the writer that the host reports the errors of the runs
+// to, see Interpreter.Stderr.
+func WithStderr(w io.Writer) Option {
+	return func(in *Interpreter) { in.Stderr = w }
+}
+
+// WithStdin makes readLine() read from r.
+func WithStdin(r io.Reader) Option {
+	return func(in *Interpreter) { in.Stdin = r }
+}
+
+// WithFS makes readFile() and writeFile() use the files in fsys.
+func WithFS(fsys FileSystem) Option {
+	return func(in *Interpreter) { in.FS = fsys }
+}
+
+// EnableFileIO allows the natives that read and write the files of FS.
+func EnableFileIO() Option {
+	return AllowCapabilities(CapFile)
+}
+
+// EnableExec allows the natives that run programs.
+func EnableExec() Option {
+	return AllowCapabilities(CapExec)
+}
+
+// EnableNetwork allows the natives that connect to the network.
+func EnableNetwork() Option {
+	return AllowCapabilities(CapNetwork)
+}
+
+// AllowCapabilities allows the natives with any of caps.
+func AllowCapabilities(caps ...Capability) Option {
+	return func(in *Interpreter) { in.Allow(caps...) }
+}
+
+// DisableFileIO denies the natives that read and write files, including
+// the ones of the temporary sandbox.
+func DisableFileIO() Option {
+	return DenyCapabilities(CapFile, CapTempFile)
+}
+
+// DisableExec denies the natives that run programs.
+func DisableExec() Option {
+	return DenyCapabilities(CapExec)
+}
+
+// DisableNetwork denies the natives that connect to the network.
+func DisableNetwork() Option {
+	return DenyCapabilities(CapNetwork)
+}
+
+// DenyCapabilities denies the natives with any of caps.
+func DenyCapabilities(caps ...Capability) Option {
+	return func(in *Interpreter) { in.Deny(caps...) }
+}
+
+// WithArgs passes args to the script.
+func WithArgs(args ...string) Option {
+	return func(in *Interpreter) { in.Args = args }
+}
+
+// WithBackend selects the backend, "tree" or "vm".
+func WithBackend(name string) Option {
+	return func(in *Interpreter) { in.Backend = name }
+}
+
+// WithOptimize turns on the optimization passes.
+func WithOptimize() Option {
+	return func(in *Interpreter) { in.Optimize = true }
+}
+
+// WithIEEEDivision lets division by zero produce Inf or NaN.
+func WithIEEEDivision() Option {
+	return func(in *Interpreter) { in.IEEEDivision = true }
+}
+
+// WithMaxCallDepth limits the depth of nested function calls to n.
+func WithMaxCallDepth(n int) Option {
+	return func(in *Interpreter) { in.MaxCallDepth = n }
+}
+
+// WithMaxSteps stops the runs that take more than n steps.
+func WithMaxSteps(n int) Option {
+	return func(in *Interpreter) { in.MaxSteps = n }
+}
+
+// WithMaxAlloc stops the runs that allocate more than n bytes.
+func WithMaxAlloc(n int) Option {
+	return func(in *Interpreter) { in.MaxAlloc = n }
+}
+
+// WithTraceDepth shows n innermost and outermost frames in stack traces.
+func WithTraceDepth(n int) Option {
+	return func(in *Interpreter) { in.TraceDepth = n }
+}
+
+// WithHooks calls h as the programs run.
+func WithHooks(h *Hooks) Option {
+	return func(in *Interpreter) { in.Hooks = h }
+}
+
+// deniedByDefault are the capabilities that give scripts access to the
+// host beyond the sandbox, a host has to allow them explicitly.
+var deniedByDefault = []Capability{CapFile, CapExec, CapNetwork}
+
+// New returns an interpreter with only the natives defined. Without options
+// it walks the tree, allows 10000 nested calls and uses the standard streams.
+// The natives that read and write files, run programs or connect to the
+// network are denied until they are allowed, see Allow; the files they get
+// then are those of the process. Call Cleanup when done with it to remove
+// the files of tempFile() and tempDir().
+func New(opts ...Option) *Interpreter {
+	in := &Interpreter{
+		Stdout:       os.Stdout,
+		Stderr:       os.Stderr,
+		Stdin:        os.Stdin,
+		FS:           HostFS{},
+		Backend:      "tree",
+		MaxCallDepth: 10000,
+		TraceDepth:   10,
+		globals:      newGlobals(),
+		sh:           &shared{},
+	}
+	in.Deny(deniedByDefault...)
+	for _, opt := range opts {
+		opt(in)
+	}
+	return in
+}
+
+// Run runs the program in source. The files it makes with tempFile() and
+// tempDir() stay until Cleanup is called.
+func (in *Interpreter) Run(source string) error {
+	_, err := in.run(source, false)
+	return err
+}
+
+// RunFile runs the program in file. Like with Run, call Cleanup when done.
+func (in *Interpreter) RunFile(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return in.Run(string(data))
+}
+
+// RunLine runs a line typed into a REPL, statements at the end of the line
+// do not need a terminating semicolon.
+func (in *Interpreter) RunLine(line string) error {
+	_, err := in.run(line, true)
+	return err
+}
+
+// Eval runs the program in source and returns the value of its last
+// statement if that is an expression, nil otherwise. Like with RunLine,
+// the semicolons at the ends of lines may be left out, so
+//
+//	v, err := in.Eval("width * height")
+//
+// evaluates an expression against the globals defined so far.
+func (in *Interpreter) Eval(source string) (Value, error) {
+	return in.run(source, true)
+}
+
+// EvalLine runs a line typed into a REPL like RunLine. If the line ends
+// with an expression, ok is true and v is its value, so that the REPL can
+// show it.
+func (in *Interpreter) EvalLine(line string) (v Value, ok bool, err error) {
+	stmts, err := parse(line, true, in.Timings)
+	if err != nil {
+		return nilValue, false, err
+	}
+	v, err = in.execContext(context.Background(), stmts)
+	if err != nil {
+		return nilValue, false, err
+	}
+	if n := len(stmts); n > 0 {
+		_, ok = stmts[n-1].(*ExprStmt)
+	}
+	return v, ok, nil
+}
+
+func (in *Interpreter) run(source string, interactive bool) (Value, error) {
+	stmts, err := parse(source, interactive, in.Timings)
+	if err != nil {
+		return nilValue, err
+	}
+	return in.execContext(context.Background(), stmts)
+}
+
+// parse scans and parses source.
+func parse(source string, interactive bool, t *Timings) ([]Stmt, error) {
+	start := time.Now()
+	sc := NewScanner(source)
+	tokens, err := sc.Scan()
+	start = t.add(scanPhase, start)
+	defer t.add(parsePhase, start)
+	if err != nil {
+		if sc.Incomplete() {
+			err = incompleteError{err}
+		}
+		return nil, err
+	}
+	p := NewParser(tokens)
+	p.ImplicitSemicolons = interactive
+	stmts, errs := p.Parse()
+	if len(errs) > 0 {
+		err := errors.Join(errs...)
+		if p.Incomplete() {
+			err = incompleteError{err}
+		}
+		return nil, err
+	}
+	return stmts, nil
+}
+
+// ErrIncomplete is matched by the syntax errors of a source that ended too
+// early, in the middle of a statement, a string or a comment. A REPL can
+// read another line and run both lines together.
+var ErrIncomplete = errors.New("incomplete source")
+
+// incompleteError is a syntax error that is ErrIncomplete.
+type incompleteError struct {
+	error
+}
+
+func (e incompleteError) Unwrap() error {
+	return e.error
+}
+
+func (e incompleteError) Is(target error) bool {
+	return target == ErrIncomplete
+}
+
+// Parse scans and parses the program in source like Run does, to run it
+// with Interpret or RunContext.
+func (in *Interpreter) Parse(source string) ([]Stmt, error) {
+	return parse(source, false, in.Timings)
+}
+
+// Interpret runs the parsed statements.
+func (in *Interpreter) Interpret(stmts []Stmt) error {
+	return in.RunContext(context.Background(), stmts)
+}
+
+// RunContext runs the parsed statements until they are done or ctx is. A
+// cancelled program stops at the next loop iteration or function call
+// with a runtime error that wraps ctx.Err(), so errors.Is tells a timeout
+// from a cancellation. Running out of steps is reported the same way with
+// ErrStepBudget.
+func (in *Interpreter) RunContext(ctx context.Context, stmts []Stmt) error {
+	_, err := in.execContext(ctx, stmts)
+	return err
+}
+
+func (in *Interpreter) execContext(ctx context.Context, stmts []Stmt) (Value, error) {
+	in.ctx, in.done = ctx, ctx.Done()
+	in.watch = in.done != nil || in.MaxSteps > 0
+	if in.sh.spawned() {
+		// the steps are counted to take turns with the tasks
+		in.lock()
+		in.watch = true
+	}
+	in.sh.steps, in.sh.alloc = 0, 0
+	defer func() {
+		in.stopTasks()
+		if in.locked {
+			in.unlock()
+		}
+		in.ctx, in.done, in.watch = nil, nil, false
+	}()
+	return in.exec(stmts)
+}
+
+// ErrStepBudget is the cause of the runtime error of a program that ran out
+// of steps, see Interpreter.MaxSteps.
+var ErrStepBudget = errors.New("step budget exceeded")
+
+// ErrMemoryLimit is the cause of the runtime error of a program that
+// allocated too much, see Interpreter.MaxAlloc.
+var ErrMemoryLimit = errors.New("memory limit exceeded")
+
+// Approximate sizes of what programs allocate.
+const (
+	stringSize   = 16   // header, the bytes come on top
+	functionSize = 64   // closure with its captured environment
+	taskSize     = 2048 // goroutine with its stack and thread state
+)
+
+// allocate counts n bytes allocated by the program or one of its tasks and
+// returns ErrMemoryLimit if it goes over the limit.
+func (in *Interpreter) allocate(n int) error {
+	if in.MaxAlloc <= 0 {
+		return nil
+	}
+	in.sh.alloc += n
+	if in.sh.alloc > in.MaxAlloc {
+		return ErrMemoryLimit
+	}
+	return nil
+}
+
+// step counts a step of the program or one of its tasks and returns why it
+// has to stop, if it does: ErrStepBudget or the error of the context.
+func (in *Interpreter) step() error {
+	in.sh.steps++
+	if in.MaxSteps > 0 && in.sh.steps > in.MaxSteps {
+		return ErrStepBudget
+	}
+	if in.locked && in.sh.steps%stepsPerTurn == 0 {
+		in.yield()
+	}
+	select {
+	case <-in.done:
+		return in.ctx.Err()
+	default:
+		return nil
+	}
+}
+
+// stops tells if err, returned by a native, stopped the program from
+// outside rather than being a failure of the native.
+func stops(err error) bool {
+	return err == ErrStepBudget || err == ErrMemoryLimit ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// stopMsg describes why a program was stopped.
+func (in *Interpreter) stopMsg(err error) string {
+	switch {
+	case err == ErrStepBudget:
+		return fmt.Sprintf("exceeded the budget of %v steps", commas(in.MaxSteps))
+	case err == ErrMemoryLimit:
+		return fmt.Sprintf("out of memory: exceeded the limit of %v bytes", commas(in.MaxAlloc))
+	case errors.Is(err, context.DeadlineExceeded):
+		return "execution timed out"
+	}
+	return "execution cancelled"
+}
+
+func (in *Interpreter) exec(stmts []Stmt) (Value, error) {
+	start := time.Now()
+	if in.Optimize {
+		stmts = optimize(stmts, in.IEEEDivision)
+		start = in.Timings.add(optimizePhase, start)
+	}
+	Resolve(stmts)
+	start = in.Timings.add(resolvePhase, start)
+
+	switch {
+	case in.Backend == "vm" || in.Disasm != nil:
+		script, errs := compile(stmts, in.Hooks != nil)
+		start = in.Timings.add(compilePhase, start)
+		if len(errs) > 0 {
+			return nilValue, errors.Join(errs...)
+		}
+		if in.Disasm != nil {
+			disassemble(in.Disasm, script)
+			return nilValue, nil
+		}
+		if in.vm == nil {
+			in.vm = newVM(in)
+		}
+		defer in.Timings.add(runPhase, start)
+		return in.vm.interpret(script)
+	case in.Backend == "tree":
+		defer in.Timings.add(runPhase, start)
+		return interpret(stmts, &Env{globals: in.globals, in: in})
+	}
+	return nilValue, fmt.Errorf("unknown backend %q", in.Backend)
+}
+
+// Deny makes the natives with any of caps fail with a runtime error when
+// scripts call them. The natives stay defined, so scripts can still test
+// for them or pass them around.
+func (in *Interpreter) Deny(caps ...Capability) {
+	if in.denied == nil {
+		in.denied = make(map[Capability]bool)
+	}
+	for _, c := range caps {
+		in.denied[c] = true
+	}
+}
+
+// Allow lets scripts call the natives with any of caps, which New denies
+// for CapFile, CapExec and CapNetwork.
+func (in *Interpreter) Allow(caps ...Capability) {
+	for _, c := range caps {
+		delete(in.denied, c)
+	}
+}
+
+// permit returns the error of calling n if its capability is denied.
+func (in *Interpreter) permit(n *nativeFn) error {
+	if in.denied[n.cap] {
+		return fmt.Errorf("capability not permitted: '%v' needs %v", n.name, n.cap)
+	}
+	return nil
+}
+
+// context returns the context of the program being run.
+func (in *Interpreter) context() context.Context {
+	if in.ctx == nil {
+		return context.Background()
+	}
+	return in.ctx
+}
+
+// stdin returns the buffered reader of Stdin. If Stdin is a *bufio.Reader
+// it is used as is, so that the host can read lines from it too without
+// losing what was buffered.
+func (in *Interpreter) stdin() *bufio.Reader {
+	if r, ok := in.Stdin.(*bufio.Reader); ok {
+		return r
+	}
+	sh := in.sh
+	if sh.stdinBuf == nil || sh.stdinSrc != in.Stdin {
+		sh.stdinBuf = bufio.NewReader(in.Stdin)
+		sh.stdinSrc = in.Stdin
+	}
+	return sh.stdinBuf
+}
+
+// RegisterNative defines the global function name that scripts call with
+// arity arguments to run fn. An error returned by fn becomes a runtime error
+// at the call. It panics if name is not a valid identifier.
+func (in *Interpreter) RegisterNative(name string, arity int, fn func(args []Value) (Value, error)) {
+	if !isIdentifier(name) {
+		panic(fmt.Sprintf("lox: invalid native name %q", name))
+	}
+	in.register(name, arity, fn)
+}
+
+// RegisterNativeIn is RegisterNative for a function in a namespace, scripts
+// call it by the qualified name, e.g. `http.get(url)` for the native get in
+// the namespace http. Scripts cannot declare or assign qualified names, so
+// they cannot shadow the natives of the host by accident.
+func (in *Interpreter) RegisterNativeIn(namespace, name string, arity int, fn func(args []Value) (Value, error)) {
+	if !isIdentifier(namespace) || !isIdentifier(name) {
+		panic(fmt.Sprintf("lox: invalid native name %q", namespace+"."+name))
+	}
+	in.register(namespace+"."+name, arity, fn)
+}
+
+func (in *Interpreter) register(name string, arity int, fn func(args []Value) (Value, error)) {
+	if arity < 0 || arity > 255 {
+		panic(fmt.Sprintf("lox: invalid arity %v of native %q", arity, name))
+	}
+	n := &nativeFn{name: name, nargs: arity, cap: CapHost, fn: func(_ *Interpreter, args []Value) (Value, error) {
+		return fn(args)
+	}}
+	in.exclusive(func() { in.globals.define(name, funValue(n)) })
+}
+
+// Define defines the global variable name with the value v, or stores v
+// into it if it is defined already. It panics if name is not a valid
+// identifier.
+func (in *Interpreter) Define(name string, v Value) {
+	if !isIdentifier(name) {
+		panic(fmt.Sprintf("lox: invalid variable name %q", name))
+	}
+	in.exclusive(func() { in.globals.define(name, v) })
+}
+
+// Global returns the value of the global variable name, ok is false if it
+// is not defined.
+func (in *Interpreter) Global(name string) (v Value, ok bool) {
+	in.exclusive(func() {
+		if c, found := in.globals.cells[name]; found && c.defined {
+			v, ok = c.v, true
+		}
+	})
+	return v, ok
+}
+
+// Globals returns the names of the defined global variables, natives
+// included, in sorted order.
+func (in *Interpreter) Globals() []string {
+	var names []string
+	in.exclusive(func() {
+		for name, c := range in.globals.cells {
+			if c.defined {
+				names = append(names, name)
+			}
+		}
+	})
+	sort.Strings(names)
+	return names
+}
+
+func errorAtToken(t *Token, msg string) string {
+	var e string
+	if t.tok == EOF {
+		e = errorAt(t.line, " at end", msg)
+	} else {
+		e = errorAt(t.line, " at '"+t.lexeme+"'", msg)
+	}
+	return e
+}
+
+func errorAt(line int, where, msg string) string {
+	return fmt.Sprintf("[line %v] error%v: %v", line, where, msg)
+}
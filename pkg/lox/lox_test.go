@@ -0,0 +1,226 @@
+package lox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestNativeAllocation reads more than MaxAlloc with natives, which must
+// count against the limit like the strings that programs make.
+func TestNativeAllocation(t *testing.T) {
+	big := strings.Repeat("x", 1<<20)
+	tests := []struct {
+		src  string
+		over bool
+	}{
+		{`var s = readFile("big");`, true},
+		{`var s = readLine();`, true},
+		{`var s = toString(readFile("small")) + "";`, false},
+	}
+	for _, backend := range backends {
+		for _, tt := range tests {
+			fs := &MemFS{}
+			fs.WriteFile("big", []byte(big))
+			fs.WriteFile("small", []byte("x"))
+			in := New(
+				WithBackend(backend),
+				WithFS(fs),
+				EnableFileIO(),
+				WithStdin(strings.NewReader(big+"\n")),
+				WithStdout(io.Discard),
+				WithMaxAlloc(1<<16),
+			)
+			err := in.Run(tt.src)
+			if tt.over && !errors.Is(err, ErrMemoryLimit) {
+				t.Errorf("%v backend, %q: got %v, want %v", backend, tt.src, err, ErrMemoryLimit)
+			}
+			if !tt.over && err != nil {
+				t.Errorf("%v backend, %q: %v", backend, tt.src, err)
+			}
+		}
+	}
+}
+
+// TestDeniedByDefault calls the natives that reach beyond the sandbox,
+// which a default interpreter must refuse until they are allowed.
+func TestDeniedByDefault(t *testing.T) {
+	tests := []struct {
+		src string
+		cap Capability
+	}{
+		{`readFile("f");`, CapFile},
+		{`writeFile("f", "x");`, CapFile},
+		{`exec("true");`, CapExec},
+		{`httpGet("http://localhost:1/");`, CapNetwork},
+	}
+	for _, backend := range backends {
+		for _, tt := range tests {
+			in := New(WithBackend(backend), WithFS(&MemFS{}))
+			err := in.Run(tt.src)
+			if err == nil || !strings.Contains(err.Error(), "capability not permitted") {
+				t.Errorf("%v backend, %q: got %v, want a denied capability", backend, tt.src, err)
+			}
+			in = New(WithBackend(backend), WithFS(&MemFS{}), AllowCapabilities(tt.cap))
+			if err := in.Run(tt.src); err != nil && strings.Contains(err.Error(), "capability not permitted") {
+				t.Errorf("%v backend, %q with %v allowed: %v", backend, tt.src, tt.cap, err)
+			}
+		}
+	}
+}
+
+// TestNamespaces calls natives registered in namespaces by their qualified
+// names, with and without spaces around the dots.
+func TestNamespaces(t *testing.T) {
+	tests := []programTest{
+		{`print text.upper("lox");`, "LOX\n"},
+		{`var text = "shadowed"; print text.upper(text) + " " + text;`, "SHADOWED shadowed\n"},
+		{"print math . add(1,\n  2);", "3\n"},
+		{`var f = text.upper; print f("f");`, "F\n"},
+		{`fun f() { var math = 1; return math.add(math, 2); } print f();`, "3\n"},
+		{`print math.sub(1, 2);`, "[line 1] runtime error: undefined variable 'math.sub'"},
+		{`print text;`, "[line 1] runtime error: undefined variable 'text'"},
+	}
+	for _, backend := range backends {
+		for _, tt := range tests {
+			var out strings.Builder
+			in := New(WithBackend(backend), WithStdout(&out))
+			in.RegisterNativeIn("text", "upper", 1, func(args []Value) (Value, error) {
+				s, _ := args[0].Str()
+				return StringValue(strings.ToUpper(s)), nil
+			})
+			if err := in.RegisterFunc("math.add", func(a, b float64) float64 { return a + b }); err != nil {
+				t.Fatal(err)
+			}
+			if err := in.Run(tt.src); err != nil {
+				out.WriteString(err.Error())
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("%v backend, %q:\ngot  %q\nwant %q", backend, tt.src, got, tt.want)
+			}
+		}
+	}
+}
+
+// TestConcurrentEval calls Eval from several goroutines, each with an
+// interpreter of its own that it also defines and reads globals of. Run it
+// with -race to check that interpreters share no state.
+func TestConcurrentEval(t *testing.T) {
+	const src = `
+fun fib(n) { if (n < 2) return n; return fib(n - 2) + fib(n - 1); }
+var s = "";
+while (len(s) < id) s = s + "x";
+count = count + 1;
+fib(12) + id
+`
+	const workers, rounds = 8, 10
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	for id := 0; id < workers; id++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			errs[id] = evalRounds(id, rounds, src)
+		}(id)
+	}
+	wg.Wait()
+	for id, err := range errs {
+		if err != nil {
+			t.Errorf("interpreter %v: %v", id, err)
+		}
+	}
+}
+
+// evalRounds evaluates src rounds times in a new interpreter and checks
+// what it returns and the globals it leaves.
+func evalRounds(id, rounds int, src string) error {
+	in := New(WithBackend([]string{"tree", "vm"}[id%2]), WithStdout(io.Discard))
+	in.Define("count", NumberValue(0))
+	in.Define("id", NumberValue(float64(id)))
+	in.RegisterNative("len", 1, func(args []Value) (Value, error) {
+		s, _ := args[0].Str()
+		return NumberValue(float64(len(s))), nil
+	})
+	for i := 0; i < rounds; i++ {
+		v, err := in.Eval(src)
+		if err != nil {
+			return err
+		}
+		if n, _ := v.Number(); n != float64(144+id) {
+			return fmt.Errorf("returned %v, want %v", v, 144+id)
+		}
+	}
+	if v, _ := in.Global("count"); v.Quoted() != fmt.Sprint(rounds) {
+		return fmt.Errorf("count = %v, want %v", v.Quoted(), rounds)
+	}
+	if v, _ := in.Global("s"); v.Quoted() != fmt.Sprintf("%q", strings.Repeat("x", id)) {
+		return fmt.Errorf("s = %v, want %v x's", v.Quoted(), id)
+	}
+	return nil
+}
+
+// TestStepBudget runs programs that take more steps than they may, which
+// must stop with ErrStepBudget wherever they take them.
+func TestStepBudget(t *testing.T) {
+	tests := []string{
+		`while (true) {}`,
+		`for (var i = 0; i >= 0; i = i + 1) {}`,
+		`fun f(n) { if (n == 0) return 0; return f(n - 1); } f(5000);`,
+		`fun loop() { loop(); } loop();`,
+	}
+	for _, backend := range backends {
+		for _, src := range tests {
+			in := New(WithBackend(backend), WithMaxSteps(1000), WithMaxCallDepth(100000))
+			err := in.Run(src)
+			if !errors.Is(err, ErrStepBudget) {
+				t.Errorf("%v backend, %q: got %v, want %v", backend, src, err, ErrStepBudget)
+			} else if !strings.Contains(err.Error(), "exceeded the budget of 1,000 steps") {
+				t.Errorf("%v backend, %q: the error is %q", backend, src, err)
+			}
+		}
+		// a program within the budget, and every run gets all of it
+		in := New(WithBackend(backend), WithMaxSteps(1000), WithStdout(io.Discard))
+		for i := 0; i < 3; i++ {
+			if err := in.Run(`var i = 0; while (i < 900) i = i + 1;`); err != nil {
+				t.Errorf("%v backend, run %v: %v", backend, i+1, err)
+			}
+		}
+	}
+}
+
+// TestCancel stops programs that would run forever by cancelling their
+// context or letting it time out.
+func TestCancel(t *testing.T) {
+	tests := []string{
+		`while (true) {}`,
+		`fun f() { while (true) {} } f();`,
+		`var ch = channel(0); spawn(fun() { while (true) {} }); receive(ch);`,
+	}
+	for _, backend := range backends {
+		for _, src := range tests {
+			in := New(WithBackend(backend))
+			stmts, err := in.Parse(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(20*time.Millisecond, cancel)
+			err = in.RunContext(ctx, stmts)
+			if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "execution cancelled") {
+				t.Errorf("%v backend, %q: got %v, want a cancellation", backend, src, err)
+			}
+
+			ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+			err = in.RunContext(ctx, stmts)
+			cancel()
+			if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "execution timed out") {
+				t.Errorf("%v backend, %q: got %v, want a timeout", backend, src, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package lox
+
+// Optimizer
+//
+// optimize folds the subexpressions that only depend on literals, so that
+// `2 * 3 + x` is evaluated as `6 + x`, and replaces if statements with a
+// constant condition by the branch that is taken. After folding it drops the
+// code that can never run: loops whose condition is always false and the
+// statements that follow a return, break or continue. It has to run before
+// the resolver, since it may drop statements together with their
+// declarations.
+//
+// Anything that would fail at run time, like `1 / 0` or `"a" + 1`, is left
+// alone so that the error is still reported when and where it happens.
+//
+// The optimizer builds new nodes for what it changes and shares the rest
+// with the tree it was given, which stays as it was for the callers that
+// run it again.
+
+func optimize(stmts []Stmt, ieeeDivision bool) []Stmt {
+	o := &optimizer{ieeeDivision: ieeeDivision}
+	return o.optimizeList(stmts)
+}
+
+type optimizer struct {
+	ieeeDivision bool // division by zero is not an error, see Interpreter
+}
+
+// optimizeList returns the optimized list in a new slice.
+func (o *optimizer) optimizeList(list []Stmt) []Stmt {
+	out := make([]Stmt, 0, len(list))
+	for _, s := range list {
+		if s = o.optimizeStmt(s); s == nil {
+			continue
+		}
+		out = append(out, s)
+		switch s.(type) {
+		case *ReturnStmt, *BreakStmt, *ContinueStmt:
+			// the rest of the block is unreachable
+			return out
+		}
+	}
+	return out
+}
+
+// optimizeStmt returns the optimized s, or nil if nothing is left of it.
+func (o *optimizer) optimizeStmt(s Stmt) Stmt {
+	switch s := s.(type) {
+	case *BlockStmt:
+		return newBlockStmt(o.optimizeList(s.list))
+	case *ExprStmt:
+		return newExprStmt(o.fold(s.expression))
+	case *FunStmt:
+		return newFunStmt(s.name, s.params, o.optimizeList(s.body))
+	case *IfStmt:
+		cond := o.fold(s.condition)
+		if c, ok := cond.(*LiteralExpr); ok {
+			if isTruthy(c.value) {
+				return o.optimizeStmt(s.block1)
+			}
+			if s.block2 != nil {
+				return o.optimizeStmt(s.block2)
+			}
+			return nil
+		}
+		then := o.optimizeStmt(s.block1)
+		if then == nil {
+			then = newBlockStmt(nil)
+		}
+		var els Stmt
+		if s.block2 != nil {
+			els = o.optimizeStmt(s.block2)
+		}
+		return newIfStmt(cond, then, els)
+	case *PrintStmt:
+		return newPrintStmt(o.fold(s.expression))
+	case *ReturnStmt:
+		if s.value != nil {
+			return newReturnStmt(s.keyword, o.fold(s.value))
+		}
+	case *VarStmt:
+		if s.init != nil {
+			return newVarStmt(s.name, o.fold(s.init))
+		}
+	case *WhileStmt:
+		cond := o.fold(s.condition)
+		if c, ok := cond.(*LiteralExpr); ok && !isTruthy(c.value) {
+			return nil
+		}
+		body := o.optimizeStmt(s.body)
+		if body == nil {
+			body = newBlockStmt(nil)
+		}
+		return newWhileStmt(s.keyword, cond, body)
+	}
+	return s
+}
+
+// fold returns e with its constant subexpressions evaluated.
+func (o *optimizer) fold(e Expr) Expr {
+	switch e := e.(type) {
+	case *AssignExpr:
+		return newAssignExpr(e.name, o.fold(e.value))
+	case *BinaryExpr:
+		left, right := o.fold(e.left), o.fold(e.right)
+		x, xok := left.(*LiteralExpr)
+		y, yok := right.(*LiteralExpr)
+		if xok && yok {
+			if v, ok := o.foldBinary(e.operator.tok, x.value, y.value); ok {
+				return newLiteralExpr(nil, v)
+			}
+		}
+		return newBinaryExpr(e.operator, left, right)
+	case *CallExpr:
+		args := make([]Expr, len(e.args))
+		for i, a := range e.args {
+			args[i] = o.fold(a)
+		}
+		return newCallExpr(o.fold(e.callee), e.paren, args)
+	case *FunExpr:
+		return newFunExpr(e.keyword, e.params, o.optimizeList(e.body))
+	case *GroupingExpr:
+		inner := o.fold(e.e)
+		if l, ok := inner.(*LiteralExpr); ok {
+			return l
+		}
+		return newGroupingExpr(inner)
+	case *LogicalExpr:
+		left, right := o.fold(e.left), o.fold(e.right)
+		if l, ok := left.(*LiteralExpr); ok {
+			// the result is the left operand if it decides the outcome
+			if isTruthy(l.value) == (e.operator.tok == Or) {
+				return l
+			}
+			return right
+		}
+		return newLogicalExpr(e.operator, left, right)
+	case *UnaryExpr:
+		right := o.fold(e.right)
+		if r, ok := right.(*LiteralExpr); ok {
+			switch e.operator.tok {
+			case Bang:
+				return newLiteralExpr(nil, BoolValue(!isTruthy(r.value)))
+			case Minus:
+				if r.value.kind == numKind {
+					return newLiteralExpr(nil, NumberValue(-r.value.num))
+				}
+			}
+		}
+		return newUnaryExpr(e.operator, right)
+	}
+	return e
+}
+
+// foldBinary evaluates the operator on two constants, ok is false if that
+// would be a runtime error.
+func (o *optimizer) foldBinary(op TokenType, x, y Value) (v Value, ok bool) {
+	switch op {
+	case EqualEqual:
+		return BoolValue(isEqual(x, y)), true
+	case BangEqual:
+		return BoolValue(!isEqual(x, y)), true
+	case Plus:
+		if x.kind == strKind && y.kind == strKind {
+			return StringValue(x.asString() + y.asString()), true
+		}
+	case Greater, GreaterEqual, Less, LessEqual:
+		if x.kind == strKind && y.kind == strKind {
+			a, b, _ := comparands(x, y)
+			x, y = NumberValue(a), NumberValue(b)
+		}
+	}
+	if x.kind != numKind || y.kind != numKind {
+		return nilValue, false
+	}
+	a, b := x.num, y.num
+	switch op {
+	case Plus:
+		return NumberValue(a + b), true
+	case Minus:
+		return NumberValue(a - b), true
+	case Star:
+		return NumberValue(a * b), true
+	case Slash:
+		if b == 0 && !o.ieeeDivision {
+			return nilValue, false
+		}
+		return NumberValue(a / b), true
+	case Greater:
+		return BoolValue(a > b), true
+	case GreaterEqual:
+		return BoolValue(a >= b), true
+	case Less:
+		return BoolValue(a < b), true
+	case LessEqual:
+		return BoolValue(a <= b), true
+	}
+	return nilValue, false
+}
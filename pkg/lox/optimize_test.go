@@ -0,0 +1,136 @@
+package lox
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// optimizerTests are programs whose output must not change with the
+// optimizer.
+var optimizerTests = []string{
+	`print 1 + 2 * 3 - 4 / 2;`,
+	`print "a" + "b" + "c";`,
+	`print "a" < "b"; print 2 >= 3; print 1 == 1; print nil != false;`,
+	`print !true; print -(-(2)); print !nil;`,
+	`print nil or "x"; print false and 1; print 1 or undefined;`,
+	`if (false) print 0; print 1; print 2;`,
+	`if (true) print "then"; else print "else";`,
+	`while (false) print "never"; print "after";`,
+	`fun f() { return 1; print "unreachable"; } print f();`,
+	`fun f() { if (1 > 2) return "a"; return "b"; } print f();`,
+	`var x = 10; { var y = x * 2; print y + 1; }`,
+	`for (var i = 0; i < 3; i = i + 1) { if (i == 1) print "one"; print i; }`,
+	`var i = 0; while (true) { i = i + 1; if (i > 3) break; } print i;`,
+	`print 1 / 0;`,
+	`print 1 + nil;`,
+	`print -"a";`,
+}
+
+func TestOptimizeEquivalence(t *testing.T) {
+	for _, backend := range backends {
+		for _, src := range optimizerTests {
+			want := runProgram(t, src, WithBackend(backend))
+			got := runProgram(t, src, WithBackend(backend), WithOptimize())
+			if got != want {
+				t.Errorf("%v backend, %q:\noptimized:\n%v\nnot optimized:\n%v", backend, src, got, want)
+			}
+		}
+	}
+}
+
+// TestOptimizeRunTwice runs the same statements twice, the optimizer must
+// not change the program that the caller holds, down to the bodies of the
+// functions and blocks.
+func TestOptimizeRunTwice(t *testing.T) {
+	const src = `fun f() { if (false) print 0; print 1 + 2; }
+{ var a = -(4); while (false) a = a + 1; print a * 2; }
+var g = fun() { return !true or "x"; };
+f();
+print g();
+`
+	stmts := parseProgram(t, src)
+	var before []string
+	for _, s := range stmts {
+		before = append(before, SprintAST(s))
+	}
+	for _, backend := range backends {
+		var out bytes.Buffer
+		in := New(WithBackend(backend), WithOptimize(), WithStdout(&out))
+		for i := 0; i < 2; i++ {
+			out.Reset()
+			if err := in.RunContext(context.Background(), stmts); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := out.String(), "-8\n3\nx\n"; got != want {
+				t.Errorf("%v backend, run %v: got %q, want %q", backend, i+1, got, want)
+			}
+			for j, s := range stmts {
+				if got := SprintAST(s); got != before[j] {
+					t.Errorf("%v backend, run %v: statement %v changed:\ngot  %v\nwant %v", backend, i+1, j+1, got, before[j])
+				}
+			}
+		}
+	}
+}
+
+// optimized returns the optimized program in src as s-expressions, one
+// statement per line.
+func optimized(t *testing.T, src string, ieeeDivision bool) string {
+	t.Helper()
+	var lines []string
+	for _, s := range optimize(parseProgram(t, src), ieeeDivision) {
+		lines = append(lines, SprintAST(s))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestOptimizeFold(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`var x = 1; print 2 * 3 + x;`, "(var x 1)\n(print (+ 6 x))"},
+		{`if (true) print "A"; else print "B";`, `(print "A")`},
+		{`if (1 > 2) print "A"; else print "B";`, `(print "B")`},
+		{`if (nil) print "A";`, ""},
+		{`print "a" + "b" + "c";`, `(print "abc")`},
+		{`print !nil == true;`, "(print true)"},
+		{`print -(-(2));`, "(print 2)"},
+		{`var x; print nil or x; print false and x;`, "(var x)\n(print x)\n(print false)"},
+		// what fails at run time stays
+		{`print 1 / 0;`, "(print (/ 1 0))"},
+		{`print "a" + 1;`, `(print (+ "a" 1))`},
+		{`print -"a";`, `(print (- "a"))`},
+	}
+	for _, tt := range tests {
+		if got := optimized(t, tt.src, false); got != tt.want {
+			t.Errorf("%q:\ngot  %v\nwant %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+// TestOptimizeDrop checks that the loops that never run and the statements
+// after return, break and continue are gone.
+func TestOptimizeDrop(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`while (false) print 1; print 2;`, "(print 2)"},
+		{`while (1 > 2) { print 1; } print 2;`, "(print 2)"},
+		{`var a = 1; while (a) { print a; break; print "after"; }`,
+			"(var a 1)\n(while a (block (print a) (break)))"},
+		{`fun f() { print 1; return 2; print 3; var x = 4; } print f();`,
+			"(fun f () (print 1) (return 2))\n(print (call f))"},
+		// only the rest of the block with the return is dropped
+		{`fun f() { { return; } print 1; }`, "(fun f () (block (return)) (print 1))"},
+		{`var i = 0; while (i < 2) { i = i + 1; continue; print i; }`,
+			"(var i 0)\n(while (< i 2) (block (expr (= i (+ i 1))) (continue)))"},
+		{`fun f() { if (true) return 1; print 2; }`, "(fun f () (return 1))"},
+	}
+	for _, tt := range tests {
+		if got := optimized(t, tt.src, false); got != tt.want {
+			t.Errorf("%q:\ngot  %v\nwant %v", tt.src, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,867 @@
+package lox
+
+import "strings"
+
+// Recursive-descent parser
+//
+// program        -> declaration* EOF ;
+//
+// declaration    -> funDecl
+//                 | lambdaCall
+//                 | varDecl
+//                 | statement ;
+//
+// funDecl        -> "fun" function ;
+// function       -> IDENTIFIER "(" parameters? ")" block ;
+// parameters     -> IDENTIFIER ( "," IDENTIFIER )* ","? ;
+//
+// lambdaCall     -> funExpr "(" arguments? ")" ";" ;
+//
+// varDecl        -> "var" IDENTIFIER ( "=" expression )? ";" ;
+//
+// statement      -> exprStmt
+//                 | breakStmt
+//                 | continueStmt
+//                 | forStmt
+//                 | ifStmt
+//                 | printStmt
+//                 | returnStmt
+//                 | whileStmt
+//				   | block ;
+//
+// block		  -> "{" declaration* "}" ;
+// breakStmt      -> "break" ";" ;
+// continueStmt   -> "continue" ";" ;
+// exprStmt       -> expression ";" ;
+// forStmt        -> "for" "(" ( varDecl | exprStmt | ";" )
+//                   expression? ";"
+//                   expression? ")" statement ;
+// ifStmt         -> "if" "(" expression ")" statement ( "else" statement )? ;
+// printStmt      -> "print" expression ";" ;
+// returnStmt     -> "return" expression? ";" ;
+// whileStmt      -> "while" "(" expression ")" statement ;
+//
+// expression     -> funExpr
+//                 | assignment ;
+// funExpr        -> "fun" "(" parameters? ")" block ;
+// assignment     -> IDENTIFIER "=" assignment
+//				   | logicOr ;
+// logicOr        -> logicAnd ( "or" logicAnd )* ;
+// logicAnd       -> equality ( "and" equality )* ;
+// equality       -> comparison ( ( "!=" | "==" ) comparison )* ;
+// comparison     -> term ( ( ">" | ">=" | "<" | "<=" ) term )* ;
+// term           -> factor ( ( "-" | "+" ) factor )* ;
+// factor         -> unary ( ( "/" | "*" ) unary )* ;
+// unary          -> ( "!" | "-" ) unary | call ;
+// call			  -> primary ( "(" arguments? ")" )* ;
+// arguments      -> expression ( "," expression )* ","? ;
+// primary        -> NUMBER | STRING | "true" | "false" | "nil"
+//                 | "(" expression ")"
+//                 | IDENTIFIER ;
+//
+
+type Parser struct {
+	tokens  []*Token
+	current int
+	errs    []error
+	errToks []*Token // at which the errors were found
+	lastErr *Token   // token of the last reported error
+	eofErrs int      // reported at the end of the tokens
+	inLoop  int
+	inFun   int
+	depth   int // of the statements and expressions being parsed
+
+	// ImplicitSemicolons lets a statement that ends a line go without its
+	// trailing ';', which is handy in the REPL.
+	ImplicitSemicolons bool
+}
+
+func NewParser(tokens []*Token) *Parser {
+	p := &Parser{tokens: tokens, errs: make([]error, 0)}
+	return p
+}
+
+// match advances pointer to the next token if current token matches
+// any of toks and returns true
+func (p *Parser) match(toks ...TokenType) bool {
+	for _, t := range toks {
+		if p.check(t) {
+			p.advance()
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Parser) advance() *Token {
+	if !p.atEnd() {
+		p.current++
+	}
+	return p.prev()
+}
+
+func (p *Parser) atEnd() bool {
+	return p.peek().tok == EOF
+}
+
+func (p *Parser) peek() *Token {
+	return p.tokens[p.current]
+}
+
+func (p *Parser) prev() *Token {
+	return p.tokens[p.current-1]
+}
+
+func (p *Parser) check(tok TokenType) bool {
+	if p.atEnd() {
+		return false
+	}
+	return p.peek().tok == tok
+}
+
+func (p *Parser) consume(expected TokenType, msg string) (*Token, error) {
+	if p.check(expected) {
+		return p.advance(), nil
+	}
+	return nil, p.perror(p.peek(), msg)
+}
+
+// semicolon consumes the ';' that terminates a statement, or accepts the end
+// of the line in its place when implicit semicolons are on.
+func (p *Parser) semicolon(msg string) error {
+	if p.match(Semicolon) {
+		return nil
+	}
+	if p.ImplicitSemicolons && (p.peek().tok == EOF || p.peek().line > p.prev().line) {
+		return nil
+	}
+	_, err := p.consume(Semicolon, msg)
+	return err
+}
+
+type ParsingError string
+
+func (e ParsingError) Error() string {
+	return string(e)
+}
+
+// maxDepth bounds the nesting of statements and expressions, so that the
+// parser and the passes that walk the tree do not run out of stack.
+const maxDepth = 10000
+
+// nest enters a nested statement or expression that starts at the current
+// token, unnest leaves it.
+func (p *Parser) nest() error {
+	if p.depth >= maxDepth {
+		return p.perror(p.peek(), "too deeply nested")
+	}
+	p.depth++
+	return nil
+}
+
+func (p *Parser) unnest() {
+	p.depth--
+}
+
+// perror records an error that the parser cannot continue after and returns
+// it, so that it is passed up to the enclosing declaration.
+func (p *Parser) perror(t *Token, msg string) error {
+	e := ParsingError(errorAtToken(t, msg))
+	p.report(t, e)
+	return e
+}
+
+// yerror records an error that does not stop parsing of the current
+// production.
+func (p *Parser) yerror(t *Token, msg string) {
+	p.report(t, ParsingError(errorAtToken(t, msg)))
+}
+
+// report records e unless an error was already reported at the same token,
+// e.g. every unclosed block complains about the missing '}' at the end.
+func (p *Parser) report(t *Token, e error) {
+	if t == p.lastErr {
+		return
+	}
+	p.lastErr = t
+	p.errs = append(p.errs, e)
+	p.errToks = append(p.errToks, t)
+	if t.tok == EOF {
+		p.eofErrs++
+	}
+}
+
+// ErrorTokens returns the tokens at which the errors returned by Parse were
+// found, one for every error, for tools that point at them.
+func (p *Parser) ErrorTokens() []*Token {
+	return p.errToks
+}
+
+// Incomplete tells if parsing failed only because the tokens ran out, like
+// in the middle of a block or after a binary operator, so more source could
+// fix it.
+func (p *Parser) Incomplete() bool {
+	return len(p.errs) > 0 && p.eofErrs == len(p.errs)
+}
+
+// sync discards tokens until it finds a statement boundary, so that a single
+// syntax error does not produce a cascade of bogus ones. start is the
+// position where the failed declaration began. Blocks met on the way are
+// skipped as a whole, otherwise their bodies and closing braces would be
+// reported again as misplaced.
+func (p *Parser) sync(start int) {
+	depth := 0
+	skip := func() {
+		switch p.advance().tok {
+		case LeftBrace:
+			depth++
+		case RightBrace:
+			if depth > 0 {
+				depth--
+			}
+		}
+	}
+	// Make sure the parser moves on even if the error is at the very first
+	// token of the declaration.
+	if p.current == start {
+		skip()
+	}
+	for !p.atEnd() {
+		if depth == 0 {
+			switch p.prev().tok {
+			case Semicolon, RightBrace:
+				return
+			}
+			switch p.peek().tok {
+			case Class, Fun, Var, For, If, While, Print, Return, Break, Continue:
+				return
+			case RightBrace:
+				// let the enclosing block close itself
+				return
+			}
+		}
+		skip()
+	}
+}
+
+// ---------------------------------------------------------
+//
+
+// Parse returns an AST of parsed tokens, if it cannot parse then it returns
+// the errors.
+func (p *Parser) Parse() (s []Stmt, errs []error) {
+	s = make([]Stmt, 0)
+	for !p.atEnd() {
+		if d := p.syncDeclaration(); d != nil {
+			s = append(s, d)
+		}
+	}
+
+	return s, p.errs
+}
+
+// syncDeclaration parses a declaration. On error, which is already recorded
+// by then, it skips to the next statement boundary and returns nil.
+func (p *Parser) syncDeclaration() Stmt {
+	start := p.current
+	s, err := p.declaration()
+	if err != nil {
+		p.sync(start)
+		return nil
+	}
+	return s
+}
+
+// spanned records the tokens from start up to the current one as the source
+// span of the statement parsed by fn.
+func (p *Parser) spanned(fn func() (Stmt, error)) (Stmt, error) {
+	start := p.peek()
+	s, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	s.setSpan(start, p.prev())
+	return s, nil
+}
+
+func (p *Parser) declaration() (Stmt, error) {
+	return p.spanned(p.declarationBody)
+}
+
+func (p *Parser) declarationBody() (Stmt, error) {
+	if p.match(Fun) {
+		if p.check(LeftParen) {
+			return p.lambdaCall()
+		}
+		return p.funDecl("function")
+	}
+	if p.match(Var) {
+		return p.varDecl()
+	}
+	return p.statement()
+}
+
+func (p *Parser) funDecl(kind string) (Stmt, error) {
+	name, err := p.declName("expected " + kind + " name")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(LeftParen, "expected '(' after "+kind+" name"); err != nil {
+		return nil, err
+	}
+	params, err := p.parameters()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(LeftBrace, "expected '{' after "+kind+" signature"); err != nil {
+		return nil, err
+	}
+	body, err := p.functionBody()
+	if err != nil {
+		return nil, err
+	}
+	return newFunStmt(name, params, body), nil
+}
+
+// functionBody parses the block of a function. Return is allowed there,
+// while break and continue cannot reach the loops around the function.
+func (p *Parser) functionBody() ([]Stmt, error) {
+	loops := p.inLoop
+	p.inLoop = 0
+	p.inFun += 1
+	body, err := p.block()
+	p.inFun -= 1
+	p.inLoop = loops
+	return body, err
+}
+
+// parameters parses the parameter list of a function up to and including
+// the closing ')'. The last parameter may be followed by a comma.
+func (p *Parser) parameters() ([]*Token, error) {
+	params := make([]*Token, 0)
+	for !p.check(RightParen) {
+		param, err := p.declName("expected parameter name")
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, param)
+		if !p.match(Comma) {
+			break
+		}
+	}
+	if _, err := p.consume(RightParen, "expected ')' after parameters"); err != nil {
+		return nil, err
+	}
+	if len(params) > 255 {
+		p.yerror(params[255], "can't have more than 255 parameters")
+	}
+	return params, nil
+}
+
+// declName parses the name in a declaration. Qualified names like
+// `http.get` are reserved for the natives of the host.
+func (p *Parser) declName(msg string) (*Token, error) {
+	name, err := p.consume(Identifier, msg)
+	if err == nil && p.check(Dot) {
+		p.yerror(p.peek(), "cannot declare a qualified name")
+		if _, err := p.qualified(name); err != nil {
+			return nil, err
+		}
+	}
+	return name, err
+}
+
+// qualified parses the rest of a qualified name like `http.get`, which
+// names a native that the host registered in a namespace, after its first
+// part. The name is returned as one identifier at the first part.
+func (p *Parser) qualified(first *Token) (*Token, error) {
+	if !p.check(Dot) {
+		return first, nil
+	}
+	lexeme := first.lexeme
+	for p.match(Dot) {
+		part, err := p.consume(Identifier, "expected name after '.'")
+		if err != nil {
+			return nil, err
+		}
+		lexeme += "." + part.lexeme
+	}
+	return &Token{tok: Identifier, lexeme: lexeme, line: first.line, col: first.col, pos: first.pos}, nil
+}
+
+func (p *Parser) varDecl() (Stmt, error) {
+	name, err := p.declName("expected variable name")
+	if err != nil {
+		return nil, err
+	}
+	var init Expr
+
+	if p.match(Equal) {
+		if init, err = p.expression(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.semicolon("expected ';' after variable declaration"); err != nil {
+		return nil, err
+	}
+	return newVarStmt(name, init), nil
+}
+
+func (p *Parser) statement() (Stmt, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer p.unnest()
+	return p.spanned(p.statementBody)
+}
+
+func (p *Parser) statementBody() (Stmt, error) {
+	if p.match(Break) {
+		return p.breakStatement()
+	}
+	if p.match(Continue) {
+		return p.continueStatement()
+	}
+	if p.match(For) {
+		return p.forStatement()
+	}
+	if p.match(If) {
+		return p.ifStatement()
+	}
+	if p.match(Print) {
+		return p.printStatement()
+	}
+	if p.match(Return) {
+		return p.returnStatement()
+	}
+	if p.match(While) {
+		return p.whileStatement()
+	}
+	if p.match(LeftBrace) {
+		list, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		return newBlockStmt(list), nil
+	}
+	return p.exprStatement()
+}
+
+// The errors of the statements that are out of place, which UnmarshalAST
+// reports too.
+const (
+	msgOutsideLoop = "expected inside the loop"
+	msgTopReturn   = "can't return from top-level code"
+)
+
+func (p *Parser) breakStatement() (Stmt, error) {
+	key := p.prev()
+	if p.inLoop < 1 {
+		return nil, p.perror(key, msgOutsideLoop)
+	}
+	if err := p.semicolon("expected ';' after break"); err != nil {
+		return nil, err
+	}
+	return newBreakStmt(key), nil
+}
+
+func (p *Parser) continueStatement() (Stmt, error) {
+	key := p.prev()
+	if p.inLoop < 1 {
+		return nil, p.perror(key, msgOutsideLoop)
+	}
+	if err := p.semicolon("expected ';' after continue"); err != nil {
+		return nil, err
+	}
+	return newContinueStmt(key), nil
+}
+
+func (p *Parser) forStatement() (Stmt, error) {
+	keyword := p.prev()
+	if _, err := p.consume(LeftParen, "expected '(' after 'for'"); err != nil {
+		return nil, err
+	}
+
+	var initial Stmt
+	var err error
+	switch {
+	case p.match(Semicolon):
+		initial = nil
+	case p.match(Var):
+		initial, err = p.varDecl()
+	default:
+		initial, err = p.exprStatement()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cond Expr
+	if !p.check(Semicolon) {
+		if cond, err = p.expression(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.consume(Semicolon, "expected ';' after for condition"); err != nil {
+		return nil, err
+	}
+
+	var incr Expr
+	if !p.check(RightParen) {
+		if incr, err = p.expression(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.consume(RightParen, "expected ')' after for clauses"); err != nil {
+		return nil, err
+	}
+
+	p.inLoop += 1
+	body, err := p.statement()
+	p.inLoop -= 1
+	if err != nil {
+		return nil, err
+	}
+
+	if incr != nil {
+		body = newBlockStmt([]Stmt{
+			body,
+			newExprStmt(incr)})
+	}
+	if cond != nil {
+		body = newWhileStmt(keyword, cond, body)
+	}
+	if initial != nil {
+		body = newBlockStmt([]Stmt{
+			initial,
+			body})
+	}
+	return body, nil
+}
+
+func (p *Parser) ifStatement() (Stmt, error) {
+	if _, err := p.consume(LeftParen, "expected '(' after 'if'"); err != nil {
+		return nil, err
+	}
+	e, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(RightParen, "expected ')' after if condition"); err != nil {
+		return nil, err
+	}
+	a, err := p.statement()
+	if err != nil {
+		return nil, err
+	}
+	var b Stmt = nil
+	if p.match(Else) {
+		if b, err = p.statement(); err != nil {
+			return nil, err
+		}
+	}
+	return newIfStmt(e, a, b), nil
+}
+
+func (p *Parser) printStatement() (Stmt, error) {
+	e, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.semicolon("expected ';' after expression"); err != nil {
+		return nil, err
+	}
+	return newPrintStmt(e), nil
+}
+
+func (p *Parser) returnStatement() (Stmt, error) {
+	k := p.prev()
+	if p.inFun < 1 {
+		return nil, p.perror(k, msgTopReturn)
+	}
+	var val Expr
+	var err error
+	if !p.check(Semicolon) {
+		if val, err = p.expression(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.semicolon("expected ';' after return value"); err != nil {
+		return nil, err
+	}
+	return newReturnStmt(k, val), nil
+}
+
+func (p *Parser) whileStatement() (Stmt, error) {
+	keyword := p.prev()
+	if _, err := p.consume(LeftParen, "expected '(' after while"); err != nil {
+		return nil, err
+	}
+	expr, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(RightParen, "expected ')' after while condition"); err != nil {
+		return nil, err
+	}
+	p.inLoop += 1
+	body, err := p.statement()
+	p.inLoop -= 1
+	if err != nil {
+		return nil, err
+	}
+	return newWhileStmt(keyword, expr, body), nil
+}
+
+func (p *Parser) block() ([]Stmt, error) {
+	list := make([]Stmt, 0)
+	for !p.check(RightBrace) && !p.atEnd() {
+		if d := p.syncDeclaration(); d != nil {
+			list = append(list, d)
+		}
+	}
+	if _, err := p.consume(RightBrace, "expected '}' after block"); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (p *Parser) exprStatement() (Stmt, error) {
+	e, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.semicolon("expected ';' after expression"); err != nil {
+		return nil, err
+	}
+	return newExprStmt(e), nil
+}
+
+func (p *Parser) expression() (Expr, error) {
+	if p.match(Fun) {
+		return p.funExpr()
+	}
+	return p.assignment()
+}
+
+func (p *Parser) funExpr() (Expr, error) {
+	keyword := p.prev()
+	if _, err := p.consume(LeftParen, "expected '(' after 'fun'"); err != nil {
+		return nil, err
+	}
+	params, err := p.parameters()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.consume(LeftBrace, "expected '{' after anonymous function signature"); err != nil {
+		return nil, err
+	}
+	body, err := p.functionBody()
+	if err != nil {
+		return nil, err
+	}
+	return newFunExpr(keyword, params, body), nil
+}
+
+func (p *Parser) lambdaCall() (Stmt, error) {
+	expr, err := p.funExpr()
+	if err != nil {
+		return nil, err
+	}
+	for p.match(LeftParen) {
+		if expr, err = p.finishCall(expr); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.semicolon("expected ';' call to a function"); err != nil {
+		return nil, err
+	}
+	return newExprStmt(expr), nil
+}
+
+func (p *Parser) assignment() (Expr, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer p.unnest()
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.match(Equal) {
+		equals := p.prev()
+		value, err := p.assignment()
+		if err != nil {
+			return nil, err
+		}
+		if ev, ok := expr.(*VarExpr); ok {
+			name := ev.name
+			if strings.Contains(name.lexeme, ".") {
+				p.yerror(equals, "cannot assign to a qualified name")
+			}
+			return newAssignExpr(name, value), nil
+		}
+		p.yerror(equals, "invalid assignment target")
+	}
+	return expr, nil
+}
+
+func (p *Parser) or() (Expr, error) {
+	expr, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.match(Or) {
+		op := p.prev()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		expr = newLogicalExpr(op, expr, right)
+	}
+	return expr, nil
+}
+
+func (p *Parser) and() (Expr, error) {
+	expr, err := p.equality()
+	if err != nil {
+		return nil, err
+	}
+	for p.match(And) {
+		op := p.prev()
+		right, err := p.equality()
+		if err != nil {
+			return nil, err
+		}
+		expr = newLogicalExpr(op, expr, right)
+	}
+	return expr, nil
+}
+
+// binary parses a left-associative chain of operands produced by next and
+// separated by any of ops.
+func (p *Parser) binary(next func() (Expr, error), ops ...TokenType) (Expr, error) {
+	expr, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for p.match(ops...) {
+		op := p.prev()
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		expr = newBinaryExpr(op, expr, right)
+	}
+	return expr, nil
+}
+
+// equality -> comparison ( ( "!=" | "==" ) comparison )* ;
+func (p *Parser) equality() (Expr, error) {
+	return p.binary(p.comparison, BangEqual, EqualEqual)
+}
+
+// comparison -> term ( ( ">" | ">=" | "<" | "<=" ) term )* ;
+func (p *Parser) comparison() (Expr, error) {
+	return p.binary(p.term, Greater, GreaterEqual, Less, LessEqual)
+}
+
+// term ->  factor ( ( "-" | "+" ) factor )* ;
+func (p *Parser) term() (Expr, error) {
+	return p.binary(p.factor, Plus, Minus)
+}
+
+// factor -> unary ( ( "/" | "*" ) unary )* ;
+func (p *Parser) factor() (Expr, error) {
+	return p.binary(p.unary, Slash, Star)
+}
+
+// unary -> ( "!" | "-" ) unary
+//        | primary ;
+func (p *Parser) unary() (Expr, error) {
+	if p.match(Bang, Minus) {
+		op := p.prev()
+		if err := p.nest(); err != nil {
+			return nil, err
+		}
+		defer p.unnest()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return newUnaryExpr(op, right), nil
+	}
+	return p.call()
+}
+
+func (p *Parser) call() (Expr, error) {
+	expr, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for p.match(LeftParen) {
+		if expr, err = p.finishCall(expr); err != nil {
+			return nil, err
+		}
+	}
+	return expr, nil
+}
+
+// finishCall parses the arguments of a call up to and including the
+// closing ')'. The last argument may be followed by a comma.
+func (p *Parser) finishCall(expr Expr) (Expr, error) {
+	args := make([]Expr, 0)
+	for !p.check(RightParen) {
+		arg, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if !p.match(Comma) {
+			break
+		}
+	}
+	paren, err := p.consume(RightParen, "expected ')' after arguments")
+	if err != nil {
+		return nil, err
+	}
+	if len(args) > 255 {
+		// At the ')', where the runtime errors of the call are reported.
+		p.yerror(paren, "can't have more than 255 arguments")
+	}
+	return newCallExpr(expr, paren, args), nil
+}
+
+// primary -> NUMBER | STRING | "true" | "false" | "nil"
+//          | IDENTIFIER ( "." IDENTIFIER )* | "(" expression ")" ;
+func (p *Parser) primary() (Expr, error) {
+	switch {
+	case p.match(False):
+		return newLiteralExpr(p.prev(), BoolValue(false)), nil
+	case p.match(True):
+		return newLiteralExpr(p.prev(), BoolValue(true)), nil
+	case p.match(Nil):
+		return newLiteralExpr(p.prev(), nilValue), nil
+	case p.match(Number, String):
+		return newLiteralExpr(p.prev(), literalValue(p.prev().literal)), nil
+	case p.match(Identifier):
+		name, err := p.qualified(p.prev())
+		if err != nil {
+			return nil, err
+		}
+		return newVarExpr(name), nil
+	case p.match(This, Super):
+		// there are no classes, so no methods either
+		return nil, p.perror(p.prev(), "can't use '"+p.prev().lexeme+"' outside of a class")
+	case p.match(LeftParen):
+		expr, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.consume(RightParen, "expected enclosing ')' after expression"); err != nil {
+			return nil, err
+		}
+		return newGroupingExpr(expr), nil
+	}
+	return nil, p.perror(p.peek(), "expected expression")
+}
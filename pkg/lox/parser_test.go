@@ -0,0 +1,87 @@
+package lox
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// errorStrings parses src and returns its syntax errors.
+func errorStrings(t *testing.T, src string) []string {
+	t.Helper()
+	toks, err := NewScanner(src).Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, errs := NewParser(toks).Parse()
+	var out []string
+	for _, err := range errs {
+		out = append(out, err.Error())
+	}
+	return out
+}
+
+// TestParseErrors checks that examples/syntaxerr.glx reports each of its
+// errors once, without cascading into the lines around them.
+func TestParseErrors(t *testing.T) {
+	src, err := os.ReadFile("../../examples/syntaxerr.glx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"[line 4] error at 'print': expected ';' after variable declaration",
+		"[line 6] error at '{': expected ')' after parameters",
+		"[line 12] error at '}': expected ';' after expression",
+		"[line 15] error at 'b': expected ')' after if condition",
+		"[line 16] error at '=': expected variable name",
+		"[line 22] error at end: expected '}' after block",
+	}
+	got := errorStrings(t, string(src))
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got errors:\n%v\nwant:\n%v", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestParseNoErrors(t *testing.T) {
+	src := `var a = 1; fun f(x) { return x + a; } { print f(2); } if (a) print 1; else print 2;`
+	if errs := errorStrings(t, src); len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
+
+// TestQualifiedNames checks that scripts can refer to a qualified name
+// but cannot declare or assign one.
+func TestQualifiedNames(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{`print http.get("u"); print a.b.c; print x . y;`, nil},
+		{`var http.get = 1;`, []string{"[line 1] error at '.': cannot declare a qualified name"}},
+		{`fun os.exit() {}`, []string{"[line 1] error at '.': cannot declare a qualified name"}},
+		{`fun f(a.b) {}`, []string{"[line 1] error at '.': cannot declare a qualified name"}},
+		{`http.get = 1;`, []string{"[line 1] error at '=': cannot assign to a qualified name"}},
+		{`print http.;`, []string{"[line 1] error at ';': expected name after '.'"}},
+		{`print http.nil;`, []string{"[line 1] error at 'nil': expected name after '.'"}},
+		{`print .5;`, []string{"[line 1] error at '.': expected expression"}},
+	}
+	for _, tt := range tests {
+		if got := errorStrings(t, tt.src); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%q: got errors %q, want %q", tt.src, got, tt.want)
+		}
+	}
+
+	var vars []string
+	for _, s := range parseProgram(t, "print a.b.c + x .\n  y;") {
+		Inspect(s, func(n Node) bool {
+			if v, ok := n.(*VarExpr); ok {
+				vars = append(vars, fmt.Sprintf("%v %v:%v", v.name.lexeme, v.name.line, v.name.col))
+			}
+			return true
+		})
+	}
+	if got, want := strings.Join(vars, ", "), "a.b.c 1:7, x.y 1:15"; got != want {
+		t.Errorf("got the names %v, want %v", got, want)
+	}
+}
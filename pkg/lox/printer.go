@@ -0,0 +1,183 @@
+package lox
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SprintAST returns node as an s-expression, like (+ 1 (* 2 3)) for the
+// expression 1 + 2 * 3, to see how the parser read a program. The
+// statements made up while desugaring show up as they are run, so a for
+// loop prints as a block with a while loop in it.
+func SprintAST(node Node) string {
+	var b strings.Builder
+	sexpr(&b, node)
+	return b.String()
+}
+
+func sexpr(b *strings.Builder, node Node) {
+	list := func(head string, nodes ...Node) {
+		b.WriteString("(" + head)
+		for _, n := range nodes {
+			b.WriteByte(' ')
+			sexpr(b, n)
+		}
+		b.WriteByte(')')
+	}
+	stmts := func(list []Stmt) []Node {
+		nodes := make([]Node, len(list))
+		for i, s := range list {
+			nodes[i] = s
+		}
+		return nodes
+	}
+	switch n := node.(type) {
+	case *AssignExpr:
+		list("= "+n.name.lexeme, n.value)
+	case *BinaryExpr:
+		list(n.operator.lexeme, n.left, n.right)
+	case *CallExpr:
+		args := []Node{n.callee}
+		for _, a := range n.args {
+			args = append(args, a)
+		}
+		list("call", args...)
+	case *FunExpr:
+		list("fun "+params(n.params, " "), stmts(n.body)...)
+	case *GroupingExpr:
+		list("group", n.e)
+	case *LiteralExpr:
+		b.WriteString(literal(n.value))
+	case *LogicalExpr:
+		list(n.operator.lexeme, n.left, n.right)
+	case *UnaryExpr:
+		list(n.operator.lexeme, n.right)
+	case *VarExpr:
+		b.WriteString(n.name.lexeme)
+
+	case *BlockStmt:
+		list("block", stmts(n.list)...)
+	case *BreakStmt:
+		list("break")
+	case *ContinueStmt:
+		list("continue")
+	case *ExprStmt:
+		list("expr", n.expression)
+	case *FunStmt:
+		list("fun "+n.name.lexeme+" "+params(n.params, " "), stmts(n.body)...)
+	case *IfStmt:
+		if n.block2 != nil {
+			list("if", n.condition, n.block1, n.block2)
+		} else {
+			list("if", n.condition, n.block1)
+		}
+	case *PrintStmt:
+		list("print", n.expression)
+	case *ReturnStmt:
+		if n.value != nil {
+			list("return", n.value)
+		} else {
+			list("return")
+		}
+	case *VarStmt:
+		if n.init != nil {
+			list("var "+n.name.lexeme, n.init)
+		} else {
+			list("var " + n.name.lexeme)
+		}
+	case *WhileStmt:
+		list("while", n.condition, n.body)
+	default:
+		panic("unexpected node")
+	}
+}
+
+// FprintTree writes the statements to w as an indented tree with a node on
+// every line and the children of a node under it.
+func FprintTree(w io.Writer, stmts []Stmt) error {
+	p := &treePrinter{w: w}
+	for _, s := range stmts {
+		Walk(p, s)
+	}
+	return p.err
+}
+
+type treePrinter struct {
+	w     io.Writer
+	depth int
+	err   error
+}
+
+func (p *treePrinter) Enter(node Node) bool {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, "%v%v\n", strings.Repeat("  ", p.depth), label(node))
+	}
+	p.depth++
+	return true
+}
+
+func (p *treePrinter) Leave(Node) {
+	p.depth--
+}
+
+// label describes node without its children.
+func label(node Node) string {
+	switch n := node.(type) {
+	case *AssignExpr:
+		return "Assign " + n.name.lexeme
+	case *BinaryExpr:
+		return "Binary " + n.operator.lexeme
+	case *CallExpr:
+		return "Call"
+	case *FunExpr:
+		return "Fun " + params(n.params, ", ")
+	case *GroupingExpr:
+		return "Grouping"
+	case *LiteralExpr:
+		return "Literal " + literal(n.value)
+	case *LogicalExpr:
+		return "Logical " + n.operator.lexeme
+	case *UnaryExpr:
+		return "Unary " + n.operator.lexeme
+	case *VarExpr:
+		return "Variable " + n.name.lexeme
+
+	case *BlockStmt:
+		return "Block"
+	case *BreakStmt:
+		return "Break"
+	case *ContinueStmt:
+		return "Continue"
+	case *ExprStmt:
+		return "Expr"
+	case *FunStmt:
+		return "Fun " + n.name.lexeme + params(n.params, ", ")
+	case *IfStmt:
+		return "If"
+	case *PrintStmt:
+		return "Print"
+	case *ReturnStmt:
+		return "Return"
+	case *VarStmt:
+		return "Var " + n.name.lexeme
+	case *WhileStmt:
+		return "While"
+	}
+	panic("unexpected node")
+}
+
+// params formats the parameters of a function in parentheses, separated
+// by sep.
+func params(list []*Token, sep string) string {
+	names := make([]string, len(list))
+	for i, t := range list {
+		names[i] = t.lexeme
+	}
+	return "(" + strings.Join(names, sep) + ")"
+}
+
+// literal formats v as it would be written in a program.
+func literal(v Value) string {
+	return v.Quoted()
+}
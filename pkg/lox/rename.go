@@ -0,0 +1,104 @@
+package lox
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Rename returns source with the symbol at line:col renamed to name.
+func Rename(source string, line, col int, name string) (string, error) {
+	if !isIdentifier(name) {
+		return "", fmt.Errorf("'%v' is not a valid identifier", name)
+	}
+	tokens, table, err := resolveSource(source)
+	if err != nil {
+		return "", err
+	}
+	sym := table.SymbolAt(line, col)
+	switch {
+	case sym == nil:
+		return "", fmt.Errorf("no symbol at %v:%v", line, col)
+	case sym.kind == NativeSymbol:
+		return "", fmt.Errorf("cannot rename native function '%v'", sym.name)
+	case sym.kind == UndefinedSymbol:
+		return "", fmt.Errorf("cannot rename '%v', it is never declared", sym.name)
+	}
+
+	occurrences := append([]*Token{sym.decl}, sym.refs...)
+	sort.Slice(occurrences, func(i, j int) bool {
+		return occurrences[i].pos < occurrences[j].pos
+	})
+	var b strings.Builder
+	last := 0
+	for _, t := range occurrences {
+		b.WriteString(source[last:t.pos])
+		b.WriteString(name)
+		last = t.pos + len(t.lexeme)
+	}
+	b.WriteString(source[last:])
+	out := b.String()
+
+	// The new name must not capture or get captured by another declaration,
+	// so every name has to be bound exactly as before.
+	renamed, renamedTable, err := resolveSource(out)
+	if err != nil {
+		return "", err
+	}
+	if !sameBindings(tokens, table, renamed, renamedTable) {
+		return "", fmt.Errorf("renaming '%v' to '%v' would change what names refer to", sym.name, name)
+	}
+	return out, nil
+}
+
+// resolveSource scans, parses and resolves source.
+func resolveSource(source string) ([]*Token, *SymbolTable, error) {
+	tokens, err := NewScanner(source).Scan()
+	if err != nil {
+		return nil, nil, err
+	}
+	stmts, errs := NewParser(tokens).Parse()
+	if len(errs) > 0 {
+		return nil, nil, errs[0]
+	}
+	return tokens, Resolve(stmts), nil
+}
+
+// sameBindings reports whether the identifiers of two programs, that differ
+// only in the spelling of names, are grouped into symbols the same way.
+func sameBindings(a []*Token, at *SymbolTable, b []*Token, bt *SymbolTable) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	pairs := make(map[*Symbol]*Symbol)
+	back := make(map[*Symbol]*Symbol)
+	for i := range a {
+		x, y := at.Lookup(a[i]), bt.Lookup(b[i])
+		if (x == nil) != (y == nil) {
+			return false
+		}
+		if x == nil {
+			continue
+		}
+		if p, ok := pairs[x]; ok && p != y {
+			return false
+		}
+		if p, ok := back[y]; ok && p != x {
+			return false
+		}
+		pairs[x], back[y] = y, x
+	}
+	return true
+}
+
+func isIdentifier(s string) bool {
+	if s == "" || isDigit(s[0]) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isAlphaNum(s[i]) {
+			return false
+		}
+	}
+	return keyword([]byte(s)) == Identifier
+}
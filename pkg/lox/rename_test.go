@@ -0,0 +1,43 @@
+package lox
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRename(t *testing.T) {
+	tests := []struct {
+		src       string
+		line, col int
+		name      string
+		want      string // the renamed source or the error
+	}{
+		{"var a = 1;\nprint a + a;", 2, 7, "b", "var b = 1;\nprint b + b;"},
+		{"var a = 1;\nprint a + a;", 1, 5, "count", "var count = 1;\nprint count + count;"},
+		// the other declarations of the name stay as they are
+		{"var a = 1;\n{ var a = 2; print a; }\nprint a;", 2, 20, "b", "var a = 1;\n{ var b = 2; print b; }\nprint a;"},
+		{"fun f(a) { return a; }\nvar a = f(1);", 1, 19, "x", "fun f(x) { return x; }\nvar a = f(1);"},
+		{"fun f() { return 1; }\nprint f();", 2, 7, "g", "fun g() { return 1; }\nprint g();"},
+		{"fun outer() { var n = 0; fun inc() { n = n + 1; } inc(); return n; }", 1, 38, "count",
+			"fun outer() { var count = 0; fun inc() { count = count + 1; } inc(); return count; }"},
+
+		{"var a = 1;", 1, 5, "1a", "'1a' is not a valid identifier"},
+		{"var a = 1;", 1, 5, "while", "'while' is not a valid identifier"},
+		{"var a = 1;", 1, 1, "b", "no symbol at 1:1"},
+		{"print clock();", 1, 7, "time", "cannot rename native function 'clock'"},
+		{"print missing;", 1, 7, "b", "cannot rename 'missing', it is never declared"},
+		// b would be captured by the local, or would hide the global
+		{"var b = 1;\nfun f() { var a = 2; return a + b; }", 2, 15, "b", "renaming 'a' to 'b' would change what names refer to"},
+		{"var a = 1;\nfun f() { var b = 2; return a + b; }", 1, 5, "b", "renaming 'a' to 'b' would change what names refer to"},
+		{"var a = 1", 1, 5, "b", "expected ';' after variable declaration"},
+	}
+	for _, tt := range tests {
+		got, err := Rename(tt.src, tt.line, tt.col, tt.name)
+		if err != nil {
+			got = err.Error()
+		}
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("Rename(%q, %v:%v, %v):\ngot  %q\nwant %q", tt.src, tt.line, tt.col, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package lox
+
+import "sort"
+
+// Resolver
+//
+// The resolver walks the AST and binds every use of a name to the
+// declaration it refers to. The bindings are kept in a symbol table, so
+// tools can answer questions about names without re-deriving the scopes.
+//
+// Every local variable also gets a slot in the environment of its scope,
+// and uses of locals are annotated with how many scopes out and at which
+// slot the variable lives. The interpreter relies on that, so the scopes
+// here must match the environments it creates: one per block and one per
+// function call, with the parameters in the first slots.
+//
+// Scoping follows the interpreter: a variable is visible after its
+// declaration, so the initializer of `var a = a;` sees the outer `a`, while
+// a function is visible inside its own body. Globals are late bound, a
+// function body may use a global that is declared further down.
+
+type SymbolKind int
+
+const (
+	VarSymbol SymbolKind = iota
+	FunSymbol
+	ParamSymbol
+	NativeSymbol
+	UndefinedSymbol
+)
+
+var symbolKindNames = [...]string{
+	VarSymbol:       "variable",
+	FunSymbol:       "function",
+	ParamSymbol:     "parameter",
+	NativeSymbol:    "native function",
+	UndefinedSymbol: "undefined",
+}
+
+func (k SymbolKind) String() string {
+	return symbolKindNames[k]
+}
+
+// Symbol is a declared name together with all the places it is used at.
+type Symbol struct {
+	name   string
+	kind   SymbolKind
+	global bool
+	slot   int      // in the environment of the scope, -1 for globals
+	decl   *Token   // nil for natives and undefined names
+	refs   []*Token // uses of the name in source order
+}
+
+func (s *Symbol) Name() string     { return s.name }
+func (s *Symbol) Kind() SymbolKind { return s.kind }
+func (s *Symbol) Global() bool     { return s.global }
+
+// Decl returns the token that declares the symbol, nil for natives and
+// undefined names.
+func (s *Symbol) Decl() *Token { return s.decl }
+
+// Refs returns the uses of the name in source order.
+func (s *Symbol) Refs() []*Token { return s.refs }
+
+type SymbolTable struct {
+	symbols []*Symbol
+	byToken map[*Token]*Symbol // declarations and references
+}
+
+// Symbols returns all the symbols of the program in the order of their first
+// appearance.
+func (t *SymbolTable) Symbols() []*Symbol {
+	return t.symbols
+}
+
+// Lookup returns the symbol that the identifier token t declares or refers
+// to.
+func (t *SymbolTable) Lookup(tok *Token) *Symbol {
+	return t.byToken[tok]
+}
+
+// SymbolAt returns the symbol declared or referenced at the given 1-based
+// line and column, or nil if there is no name there.
+func (t *SymbolTable) SymbolAt(line, col int) *Symbol {
+	for tok, sym := range t.byToken {
+		if tok.line == line && tok.col <= col && col < tok.col+len(tok.lexeme) {
+			return sym
+		}
+	}
+	return nil
+}
+
+type scope struct {
+	names map[string]*Symbol
+	slots []*Token // declarations of the variables, by slot
+}
+
+type resolver struct {
+	table   *SymbolTable
+	scopes  []*scope // local scopes, the innermost is the last
+	globals map[string]*Symbol
+	pending []*Token // uses of globals that are bound at the end
+}
+
+// Resolve builds the symbol table of a parsed program and binds the
+// variables of its AST to their slots.
+func Resolve(stmts []Stmt) *SymbolTable {
+	r := &resolver{
+		table:   &SymbolTable{byToken: make(map[*Token]*Symbol)},
+		globals: make(map[string]*Symbol),
+	}
+	r.stmts(stmts)
+	for _, t := range r.pending {
+		r.bindGlobal(t)
+	}
+	for _, sym := range r.table.symbols {
+		sort.Slice(sym.refs, func(i, j int) bool {
+			return sym.refs[i].pos < sym.refs[j].pos
+		})
+	}
+	return r.table
+}
+
+func (r *resolver) beginScope() {
+	r.scopes = append(r.scopes, &scope{names: make(map[string]*Symbol)})
+}
+
+// endScope returns the declarations of the variables in the slots of the
+// scope.
+func (r *resolver) endScope() []*Token {
+	slots := r.scopes[len(r.scopes)-1].slots
+	r.scopes = r.scopes[:len(r.scopes)-1]
+	return slots
+}
+
+func (r *resolver) newSymbol(name string, kind SymbolKind, decl *Token) *Symbol {
+	sym := &Symbol{name: name, kind: kind, global: len(r.scopes) == 0, slot: -1, decl: decl}
+	r.table.symbols = append(r.table.symbols, sym)
+	if decl != nil {
+		r.table.byToken[decl] = sym
+	}
+	return sym
+}
+
+// declare binds name in the innermost scope and returns its slot. Declaring
+// a name again in the same scope reuses the variable, just like the
+// interpreter always did, except for parameters that each get a slot.
+func (r *resolver) declare(name *Token, kind SymbolKind) int {
+	if len(r.scopes) == 0 {
+		if sym, ok := r.globals[name.lexeme]; ok {
+			r.ref(sym, name)
+		} else {
+			r.globals[name.lexeme] = r.newSymbol(name.lexeme, kind, name)
+		}
+		return -1
+	}
+	sc := r.scopes[len(r.scopes)-1]
+	if sym, ok := sc.names[name.lexeme]; ok && kind != ParamSymbol {
+		r.ref(sym, name)
+		return sym.slot
+	}
+	sym := r.newSymbol(name.lexeme, kind, name)
+	sym.slot = len(sc.slots)
+	sc.slots = append(sc.slots, name)
+	sc.names[name.lexeme] = sym
+	return sym.slot
+}
+
+func (r *resolver) ref(sym *Symbol, name *Token) {
+	sym.refs = append(sym.refs, name)
+	r.table.byToken[name] = sym
+}
+
+// use binds a use of name to the closest declaration in scope.
+func (r *resolver) use(name *Token) binding {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if sym, ok := r.scopes[i].names[name.lexeme]; ok {
+			r.ref(sym, name)
+			return binding{depth: len(r.scopes) - 1 - i, slot: sym.slot}
+		}
+	}
+	r.pending = append(r.pending, name)
+	return binding{slot: -1}
+}
+
+func (r *resolver) bindGlobal(name *Token) {
+	sym, ok := r.globals[name.lexeme]
+	if !ok {
+		kind := UndefinedSymbol
+		if lookupNative(name.lexeme) != nil {
+			kind = NativeSymbol
+		}
+		sym = r.newSymbol(name.lexeme, kind, nil)
+		sym.global = true
+		r.globals[name.lexeme] = sym
+	}
+	r.ref(sym, name)
+}
+
+// function resolves a function and returns the declarations of the
+// variables in the slots of its calls.
+func (r *resolver) function(params []*Token, body []Stmt) []*Token {
+	r.beginScope()
+	for _, p := range params {
+		r.declare(p, ParamSymbol)
+	}
+	r.stmts(body)
+	return r.endScope()
+}
+
+func (r *resolver) stmts(list []Stmt) {
+	for _, s := range list {
+		r.stmt(s)
+	}
+}
+
+func (r *resolver) stmt(s Stmt) {
+	switch s := s.(type) {
+	case *BlockStmt:
+		r.beginScope()
+		r.stmts(s.list)
+		s.slots = r.endScope()
+	case *ExprStmt:
+		r.expr(s.expression)
+	case *FunStmt:
+		s.slot = r.declare(s.name, FunSymbol)
+		s.slots = r.function(s.params, s.body)
+	case *IfStmt:
+		r.expr(s.condition)
+		r.stmt(s.block1)
+		if s.block2 != nil {
+			r.stmt(s.block2)
+		}
+	case *PrintStmt:
+		r.expr(s.expression)
+	case *ReturnStmt:
+		if s.value != nil {
+			r.expr(s.value)
+		}
+	case *VarStmt:
+		if s.init != nil {
+			r.expr(s.init)
+		}
+		s.slot = r.declare(s.name, VarSymbol)
+	case *WhileStmt:
+		r.expr(s.condition)
+		r.stmt(s.body)
+	case *BreakStmt, *ContinueStmt:
+	}
+}
+
+func (r *resolver) expr(e Expr) {
+	switch e := e.(type) {
+	case *AssignExpr:
+		r.expr(e.value)
+		e.binding = r.use(e.name)
+	case *BinaryExpr:
+		r.expr(e.left)
+		r.expr(e.right)
+	case *CallExpr:
+		r.expr(e.callee)
+		for _, a := range e.args {
+			r.expr(a)
+		}
+	case *FunExpr:
+		e.slots = r.function(e.params, e.body)
+	case *GroupingExpr:
+		r.expr(e.e)
+	case *LogicalExpr:
+		r.expr(e.left)
+		r.expr(e.right)
+	case *UnaryExpr:
+		r.expr(e.right)
+	case *VarExpr:
+		e.binding = r.use(e.name)
+	case *LiteralExpr:
+	}
+}
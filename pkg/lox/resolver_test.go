@@ -0,0 +1,22 @@
+package lox
+
+import "testing"
+
+// TestResolverSlots checks that locals are found in the slots the resolver
+// gives them, across blocks, calls and closures.
+func TestResolverSlots(t *testing.T) {
+	tests := []programTest{
+		{`{ var a = 1; var b = 2; { var c = 3; print a + b + c; } print b; }`, "6\n2\n"},
+		{`var a = "global"; { var a = "outer"; { var a = "inner"; print a; } print a; } print a;`, "inner\nouter\nglobal\n"},
+		{`fun f(a, b) { var c = a - b; { var d = c * 2; return d; } } print f(5, 2);`, "6\n"},
+		{`fun fib(n) { if (n < 2) return n; return fib(n - 1) + fib(n - 2); } print fib(15);`, "610\n"},
+		{`fun counter() { var n = 0; fun inc() { n = n + 1; return n; } return inc; }
+		  var c1 = counter(); var c2 = counter(); c1(); c1(); print c1(); print c2();`, "3\n1\n"},
+		{`fun outer() { var x = "x"; fun middle() { fun inner() { return x; } return inner; } return middle()(); } print outer();`, "x\n"},
+		// closures are bound lexically, a later local of the same name is
+		// not seen by a function declared before it
+		{`var a = "global"; { fun show() { print a; } show(); var a = "block"; show(); }`, "global\nglobal\n"},
+		{`for (var i = 0; i < 2; i = i + 1) { var j = i * 10; print j; }`, "0\n10\n"},
+	}
+	testPrograms(t, tests)
+}
@@ -0,0 +1,100 @@
+package lox
+
+import (
+	"os"
+	"sync"
+)
+
+// tempSandbox is a per-interpreter scratch directory that backs the
+// tempFile() and tempDir() natives. Scripts only ever get paths inside of
+// it. The directory outlives the runs, so that the files a script leaves
+// there are still around for the next input of a REPL, and it is removed by
+// Interpreter.Cleanup or Cleanup. Interpreters running side by side each get
+// their own, so cleaning up after one does not pull the files from under
+// another.
+type tempSandbox struct {
+	mu  sync.Mutex
+	dir string // created lazily on the first request
+}
+
+// sandboxes are the ones with a directory, for the package Cleanup.
+var sandboxes = struct {
+	sync.Mutex
+	m map[*tempSandbox]bool
+}{m: make(map[*tempSandbox]bool)}
+
+func (s *tempSandbox) root() (string, error) {
+	if s.dir == "" {
+		dir, err := os.MkdirTemp("", "glox-")
+		if err != nil {
+			return "", err
+		}
+		s.dir = dir
+		sandboxes.Lock()
+		sandboxes.m[s] = true
+		sandboxes.Unlock()
+	}
+	return s.dir, nil
+}
+
+// tempFile creates a new empty file inside the sandbox and returns its path.
+func (s *tempSandbox) tempFile() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	root, err := s.root()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(root, "file-")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return f.Name(), nil
+}
+
+// tempDir creates a new empty directory inside the sandbox and returns its
+// path.
+func (s *tempSandbox) tempDir() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	root, err := s.root()
+	if err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(root, "dir-")
+}
+
+// cleanup removes the sandbox with everything that was created inside.
+func (s *tempSandbox) cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dir != "" {
+		os.RemoveAll(s.dir)
+		s.dir = ""
+		sandboxes.Lock()
+		delete(sandboxes.m, s)
+		sandboxes.Unlock()
+	}
+}
+
+// Cleanup removes the scratch directory handed out by the tempFile() and
+// tempDir() natives of the interpreter and its tasks. Programs embedding the
+// interpreter call it once they are done running scripts.
+func (in *Interpreter) Cleanup() {
+	in.sh.sandbox.cleanup()
+}
+
+// Cleanup removes the scratch directories of all interpreters. Programs
+// with a single interpreter may call it instead of Interpreter.Cleanup.
+func Cleanup() {
+	sandboxes.Lock()
+	all := make([]*tempSandbox, 0, len(sandboxes.m))
+	for s := range sandboxes.m {
+		all = append(all, s)
+	}
+	sandboxes.Unlock()
+	for _, s := range all {
+		s.cleanup()
+	}
+}
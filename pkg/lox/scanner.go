@@ -0,0 +1,367 @@
+package lox
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// keywordTable lists the keywords by their first letter, so that telling
+// a keyword from an identifier takes a couple of byte comparisons.
+var keywordTable [256][]TokenType
+
+func init() {
+	for t := And; t <= While; t++ {
+		k := t.String()
+		keywordTable[k[0]] = append(keywordTable[k[0]], t)
+	}
+}
+
+// keyword returns the keyword spelled by b or Identifier if there is none.
+func keyword(b []byte) TokenType {
+	for _, t := range keywordTable[b[0]] {
+		if string(b) == t.String() {
+			return t
+		}
+	}
+	return Identifier
+}
+
+// Keywords returns the keywords of the language in sorted order.
+func Keywords() []string {
+	var words []string
+	for t := And; t <= While; t++ {
+		words = append(words, t.String())
+	}
+	sort.Strings(words)
+	return words
+}
+
+// interner keeps a single copy of every identifier and string literal seen
+// by a scanner, so that names and repeated literals share memory instead of
+// each keeping a copy. It does not make comparing or looking up names any
+// cheaper, maps still hash the whole string; the code that runs often goes
+// through the cells of the globals instead, see globals. Each scanner has
+// its own interner, which goes away with it.
+type interner map[string]string
+
+func (m interner) intern(b []byte) string {
+	if v, ok := m[string(b)]; ok {
+		return v
+	}
+	s := string(b)
+	m[s] = s
+	return s
+}
+
+type ScanError string
+
+func (e ScanError) Error() string {
+	return string(e)
+}
+
+// Scanner works on the bytes of the source. The only strings it makes are
+// the lexemes of identifiers, string literals and numbers, other tokens
+// use the spelling of their kind, and the tokens themselves are allocated
+// in batches.
+type Scanner struct {
+	src     []byte
+	lines   []int // offsets at which the lines start
+	tokens  []*Token
+	slab    []Token // preallocated tokens
+	start   int     // start of the lexeme
+	current int     // pointer of scanner
+	line    int     // index into lines of the line with the lexeme
+	err     error
+	errPos  int // offset at which err was found
+
+	incomplete bool // the source ended inside a string or a comment
+	strs       interner
+
+	// KeepComments makes the scanner return the comments as Comment
+	// tokens instead of skipping them, for tools that reprint the source.
+	KeepComments bool
+}
+
+func NewScanner(source string) *Scanner {
+	src := []byte(source)
+	lines := []int{0}
+	for i := 0; ; {
+		n := bytes.IndexByte(src[i:], '\n')
+		if n < 0 {
+			break
+		}
+		i += n + 1
+		lines = append(lines, i)
+	}
+	return &Scanner{
+		src:    src,
+		lines:  lines,
+		tokens: make([]*Token, 0, len(src)/3),
+		strs:   make(interner),
+	}
+}
+
+func (s *Scanner) Scan() ([]*Token, error) {
+	if bytes.HasPrefix(s.src, []byte("#!")) {
+		// the interpreter line of a script run as a program
+		if n := bytes.IndexByte(s.src, '\n'); n < 0 {
+			s.current = len(s.src)
+		} else {
+			s.current = n
+		}
+	}
+	for !s.atEnd() && s.err == nil {
+		s.start = s.current
+		s.scanToken()
+	}
+
+	if s.err == nil {
+		s.start = s.current
+		s.add(EOF, "", nil)
+	}
+	return s.tokens, s.err
+}
+
+// Incomplete tells if scanning failed only because the source ended in the
+// middle of a string or a comment, so more source could fix it.
+func (s *Scanner) Incomplete() bool {
+	return s.incomplete
+}
+
+func (s *Scanner) scanToken() {
+	ch := s.advance()
+	switch ch {
+	case '(':
+		s.token(LeftParen)
+	case ')':
+		s.token(RightParen)
+	case '{':
+		s.token(LeftBrace)
+	case '}':
+		s.token(RightBrace)
+	case ',':
+		s.token(Comma)
+	case ':':
+		s.token(Colon)
+	case '.':
+		s.token(Dot)
+	case '-':
+		s.token(Minus)
+	case '?':
+		s.token(Question)
+	case '+':
+		s.token(Plus)
+	case ';':
+		s.token(Semicolon)
+	case '*':
+		s.token(Star)
+	case '!':
+		if s.match('=') {
+			s.token(BangEqual)
+		} else {
+			s.token(Bang)
+		}
+	case '=':
+		if s.match('=') {
+			s.token(EqualEqual)
+		} else {
+			s.token(Equal)
+		}
+	case '<':
+		if s.match('=') {
+			s.token(LessEqual)
+		} else {
+			s.token(Less)
+		}
+	case '>':
+		if s.match('=') {
+			s.token(GreaterEqual)
+		} else {
+			s.token(Greater)
+		}
+	case '/':
+		if s.match('/') {
+			if n := bytes.IndexByte(s.src[s.current:], '\n'); n < 0 {
+				s.current = len(s.src)
+			} else {
+				s.current += n
+			}
+			s.comment()
+		} else if s.match('*') {
+			if s.fullComment(); s.err == nil {
+				s.comment()
+			}
+		} else {
+			s.token(Slash)
+		}
+	case ' ', '\r', '\t', '\n':
+		break
+	case '"':
+		s.stringLit()
+	default:
+		if isDigit(ch) {
+			s.number()
+		} else if isAlpha(ch) {
+			s.identifier()
+		} else {
+			s.report(fmt.Sprintf("unexpected character '%c'", ch))
+			return
+		}
+	}
+}
+
+func (s *Scanner) report(msg string) {
+	line := sort.Search(len(s.lines), func(i int) bool {
+		return s.lines[i] > s.current
+	})
+	s.err = ScanError(errorAt(line, "", msg))
+	s.errPos = s.current
+}
+
+// ErrorAt returns the 1-based line and column at which Scan failed.
+func (s *Scanner) ErrorAt() (line, col int) {
+	line = sort.Search(len(s.lines), func(i int) bool {
+		return s.lines[i] > s.errPos
+	})
+	return line, s.errPos - s.lines[line-1] + 1
+}
+
+func isDigit(b byte) bool {
+	return '0' <= b && b <= '9'
+}
+
+func isAlpha(b byte) bool {
+	return 'a' <= b && b <= 'z' ||
+		'A' <= b && b <= 'Z' ||
+		b == '_'
+}
+
+func isAlphaNum(b byte) bool {
+	return isDigit(b) || isAlpha(b)
+}
+
+func (s *Scanner) atEnd() bool {
+	return s.current >= len(s.src)
+}
+
+func (s *Scanner) advance() byte {
+	i := s.current
+	s.current++
+	return s.src[i]
+}
+
+func (s *Scanner) match(ch byte) bool {
+	if s.peek() != ch {
+		return false
+	}
+	s.current++
+	return true
+}
+
+func (s *Scanner) peek() byte {
+	if s.atEnd() {
+		return byte(0)
+	}
+	return s.src[s.current]
+}
+
+func (s *Scanner) peekNext() byte {
+	if s.current+1 >= len(s.src) {
+		return byte(0)
+	}
+	return s.src[s.current+1]
+}
+
+func (s *Scanner) token(t TokenType) {
+	s.add(t, t.String(), nil)
+}
+
+// add appends a token that starts at s.start.
+func (s *Scanner) add(t TokenType, lexeme string, literal interface{}) {
+	for s.line+1 < len(s.lines) && s.lines[s.line+1] <= s.start {
+		s.line++
+	}
+	if len(s.slab) == 0 {
+		s.slab = make([]Token, 256)
+	}
+	tok := &s.slab[0]
+	s.slab = s.slab[1:]
+	*tok = Token{
+		tok:     t,
+		lexeme:  lexeme,
+		literal: literal,
+		line:    s.line + 1,
+		col:     s.start - s.lines[s.line] + 1,
+		pos:     s.start,
+	}
+	s.tokens = append(s.tokens, tok)
+}
+
+func (s *Scanner) stringLit() {
+	n := bytes.IndexByte(s.src[s.current:], '"')
+	if n < 0 {
+		s.current = len(s.src)
+	} else {
+		s.current += n
+	}
+	if s.atEnd() {
+		s.report("unterminated string")
+		s.incomplete = true
+		return
+	}
+	s.advance() // skip closing "
+	lex := s.strs.intern(s.src[s.start:s.current])
+	s.add(String, lex, lex[1:len(lex)-1])
+}
+
+func (s *Scanner) number() {
+	for isDigit(s.peek()) {
+		s.advance()
+	}
+	if s.peek() == '.' && isDigit(s.peekNext()) {
+		s.advance() // eat .
+		for isDigit(s.peek()) {
+			s.advance()
+		}
+	}
+	lex := s.strs.intern(s.src[s.start:s.current])
+	val, err := strconv.ParseFloat(lex, 64)
+	if err != nil {
+		// only the numbers too large for a float64 get here
+		s.report("number literal out of range")
+		return
+	}
+	s.add(Number, lex, val)
+}
+
+func (s *Scanner) identifier() {
+	for isAlphaNum(s.peek()) {
+		s.advance()
+	}
+	text := s.src[s.start:s.current]
+	if t := keyword(text); t != Identifier {
+		s.token(t)
+		return
+	}
+	s.add(Identifier, s.strs.intern(text), nil)
+}
+
+// comment adds the comment just scanned if the comments are kept.
+func (s *Scanner) comment() {
+	if s.KeepComments {
+		s.add(Comment, string(bytes.TrimRight(s.src[s.start:s.current], "\r")), nil)
+	}
+}
+
+func (s *Scanner) fullComment() {
+	n := bytes.Index(s.src[s.current:], []byte("*/"))
+	if n < 0 {
+		s.current = len(s.src)
+		s.report("unterminated /**/ comment")
+		s.incomplete = true
+		return
+	}
+	s.current += n + len("*/")
+}
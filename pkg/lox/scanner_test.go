@@ -0,0 +1,124 @@
+package lox
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestScanPositions checks the line, column and offset of every token,
+// which the scanner finds from the offsets of the lines.
+func TestScanPositions(t *testing.T) {
+	src := "var a = 1;\n\n  print \"two\nlines\" + a;\r\n/* c\n */ b\n"
+	want := []string{
+		"var 1:1 0", "a 1:5 4", "= 1:7 6", "1 1:9 8", "; 1:10 9",
+		"print 3:3 14", "\"two\nlines\" 3:9 20", "+ 4:8 32", "a 4:10 34", "; 4:11 35",
+		"b 6:5 47", " 7:1 49",
+	}
+	toks, err := NewScanner(src).Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, tok := range toks {
+		got = append(got, fmt.Sprintf("%v %v:%v %v", tok.lexeme, tok.line, tok.col, tok.pos))
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+// TestScanKeywords checks that every keyword is found in the table, and
+// that words that only start or end like one are identifiers.
+func TestScanKeywords(t *testing.T) {
+	for _, k := range Keywords() {
+		for _, word := range []string{k, k + "x", "x" + k, k[:len(k)-1], strings.ToUpper(k[:1]) + k[1:]} {
+			toks, err := NewScanner(word).Scan()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := Identifier
+			if word == k {
+				want = toks[0].tok
+				if want.String() != k {
+					t.Errorf("%q is scanned as %v", k, want)
+				}
+			}
+			if len(toks) != 2 || toks[0].tok != want || toks[0].lexeme != word {
+				t.Errorf("%q: got %v %q, want %v", word, toks[0].tok, toks[0].lexeme, want)
+			}
+		}
+	}
+}
+
+func TestScanLiterals(t *testing.T) {
+	toks, err := NewScanner(`"abc" 12.5 7 "" "abc"`).Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{"abc", 12.5, 7.0, "", "abc", nil}
+	for i, tok := range toks {
+		if tok.literal != want[i] {
+			t.Errorf("token %v: literal %#v, want %#v", i, tok.literal, want[i])
+		}
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	tests := []struct {
+		src        string
+		incomplete bool
+	}{
+		{`print "abc`, true},
+		{"/* abc", true},
+		{"print @;", false},
+	}
+	for _, tt := range tests {
+		s := NewScanner(tt.src)
+		if _, err := s.Scan(); err == nil {
+			t.Errorf("%q: no error", tt.src)
+		}
+		if s.Incomplete() != tt.incomplete {
+			t.Errorf("%q: Incomplete() = %v, want %v", tt.src, s.Incomplete(), tt.incomplete)
+		}
+	}
+}
+
+// BenchmarkScan scans a generated script of about 400KB.
+func BenchmarkScan(b *testing.B) {
+	var sb strings.Builder
+	for i := 0; i < 4000; i++ {
+		fmt.Fprintf(&sb, "// function number %v\n", i)
+		fmt.Fprintf(&sb, "fun f%v(a, b) {\n\tvar s = \"str %v\";\n", i, i)
+		fmt.Fprintf(&sb, "\tif (a >= b and !nil) return a * %v.5 + b;\n\treturn s;\n}\n", i)
+	}
+	src := sb.String()
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewScanner(src).Scan(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestScanDot checks that a '.' between names is a token of its own, the
+// parser makes the qualified names out of them.
+func TestScanDot(t *testing.T) {
+	toks, err := NewScanner("http.get(url) a . b 1.5 .5 x.").Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, tok := range toks {
+		got = append(got, tok.tok.String()+" "+tok.lexeme)
+	}
+	want := []string{
+		"ident http", ". .", "ident get", "( (", "ident url", ") )",
+		"ident a", ". .", "ident b", "number 1.5", ". .", "number 5", "ident x", ". .", "eof ",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
@@ -0,0 +1,111 @@
+package lox
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// Snapshot holds the global variables of an interpreter as they were at one
+// point, so that a REPL session or a host can roll back to it.
+//
+// The snapshot keeps the values, not copies of what they refer to: a
+// restored function still sees the variables it captured as they are now,
+// and so does a restored task, channel or host value. Only nil, booleans,
+// numbers and strings survive MarshalJSON, see UnmarshalJSON for what a
+// decoded snapshot restores.
+type Snapshot struct {
+	vars    map[string]Value
+	decoded bool // holds only the data
+}
+
+// SnapshotGlobals returns the global variables defined so far, including
+// the natives.
+func (in *Interpreter) SnapshotGlobals() *Snapshot {
+	s := &Snapshot{vars: make(map[string]Value)}
+	in.exclusive(func() {
+		for name, c := range in.globals.cells {
+			if c.defined {
+				s.vars[name] = c.v
+			}
+		}
+	})
+	return s
+}
+
+// RestoreGlobals sets the global variables back to the ones in s. Those
+// defined since s was taken are undefined again, except that a snapshot
+// decoded from JSON leaves the functions and other values that it could not
+// hold alone.
+func (in *Interpreter) RestoreGlobals(s *Snapshot) {
+	in.exclusive(func() {
+		for name, c := range in.globals.cells {
+			if _, ok := s.vars[name]; ok || s.decoded && c.defined && !isData(c.v) {
+				continue
+			}
+			c.v, c.defined = nilValue, false
+		}
+		for name, v := range s.vars {
+			in.globals.define(name, v)
+		}
+	})
+}
+
+// exclusive runs f without tasks running alongside.
+func (in *Interpreter) exclusive(f func()) {
+	if in.sh.spawned() && !in.locked {
+		in.lock()
+		defer in.unlock()
+	}
+	f()
+}
+
+// isData tells if v is nil, a boolean, a number or a string.
+func isData(v Value) bool {
+	switch v.kind {
+	case nilKind, boolKind, numKind, strKind:
+		return true
+	}
+	return false
+}
+
+// MarshalJSON writes the variables holding nil, a boolean, a finite number
+// or a string as an object, it fails on infinite numbers and NaN. The other
+// variables are left out.
+func (s *Snapshot) MarshalJSON() ([]byte, error) {
+	vars := make(map[string]interface{})
+	for name, v := range s.vars {
+		if !isData(v) {
+			continue
+		}
+		if f, ok := v.Number(); ok && (math.IsInf(f, 0) || math.IsNaN(f)) {
+			return nil, fmt.Errorf("global '%v' is %v, which JSON cannot hold", name, v)
+		}
+		vars[name] = v.Interface()
+	}
+	return json.Marshal(vars)
+}
+
+// UnmarshalJSON reads what MarshalJSON wrote. Restoring the snapshot sets
+// the variables in it and undefines the other variables holding data.
+func (s *Snapshot) UnmarshalJSON(data []byte) error {
+	var vars map[string]interface{}
+	if err := json.Unmarshal(data, &vars); err != nil {
+		return err
+	}
+	s.vars = make(map[string]Value, len(vars))
+	s.decoded = true
+	for name, x := range vars {
+		switch x.(type) {
+		case nil, bool, float64, string:
+		default:
+			return fmt.Errorf("global '%v' is not nil, a boolean, a number or a string", name)
+		}
+		v, err := ToValue(x)
+		if err != nil {
+			return err
+		}
+		s.vars[name] = v
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package lox
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// TestSnapshotRestore takes a snapshot, changes the globals and restores
+// it, after which the program continues with the globals of the snapshot.
+func TestSnapshotRestore(t *testing.T) {
+	for _, backend := range backends {
+		var out bytes.Buffer
+		in := New(WithBackend(backend), WithStdout(&out))
+		run := func(src string) {
+			t.Helper()
+			if err := in.Run(src); err != nil {
+				t.Fatalf("%v backend, %q: %v", backend, src, err)
+			}
+		}
+		run(`var n = 1; var s = "a"; fun counter() { n = n + 1; return n; }`)
+		snap := in.SnapshotGlobals()
+		run(`counter(); counter(); s = "changed"; var later = true;`)
+		in.RestoreGlobals(snap)
+		out.Reset()
+		run(`print n; print s; print counter(); print n;`)
+		if got, want := out.String(), "1\na\n2\n2\n"; got != want {
+			t.Errorf("%v backend: after restoring printed %q, want %q", backend, got, want)
+		}
+		if _, ok := in.Global("later"); ok {
+			t.Errorf("%v backend: a global defined after the snapshot is still defined", backend)
+		}
+		if err := in.Run(`print later;`); err == nil {
+			t.Errorf("%v backend: reading a global defined after the snapshot succeeded", backend)
+		}
+
+		// through JSON, which keeps the data and leaves the functions
+		data, err := json.Marshal(snap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Snapshot
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		run(`n = 10; s = "b"; var extra = 1;`)
+		in.RestoreGlobals(&decoded)
+		out.Reset()
+		run(`print n; print s; print counter();`)
+		if got, want := out.String(), "1\na\n2\n"; got != want {
+			t.Errorf("%v backend: after restoring from JSON printed %q, want %q", backend, got, want)
+		}
+		if _, ok := in.Global("extra"); ok {
+			t.Errorf("%v backend: a global defined after the snapshot is still defined", backend)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package lox
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Tasks
+//
+// spawn(f) calls the function f without arguments on a goroutine and
+// returns a task, join(task) waits for it and returns what f returned. If f
+// failed, join fails with the same runtime error.
+//
+// Tasks share the global variables with the program that spawned them, and
+// the variables that f captured. To keep that free of data races they take
+// turns like the threads of an interpreter with a global lock: only the
+// holder of the lock runs Lox code, and it hands the lock over every few
+// hundred steps (see Interpreter.MaxSteps) and while it waits in a native
+// that blocks, such as join(), receive() or exec(). So tasks run
+// concurrently but not in parallel, and a statement like `n = n + 1` is
+// atomic only when no step happens within it.
+//
+// Each task has its own call stack, but the steps and the memory it takes
+// count against the budgets of the run that spawned it, and so does the
+// spawn itself. Tasks stop when the context of the run is done, and the
+// run stops the tasks that are left when it returns, so nothing keeps
+// running behind the host's back. Only a task waiting in readLine() cannot
+// be stopped, the run waits for the line.
+
+// stepsPerTurn is how often a thread hands the lock over.
+const stepsPerTurn = 512
+
+// shared is the state of an interpreter that its tasks share.
+type shared struct {
+	mu    sync.Mutex    // the global lock
+	tasks int32         // spawned by the run, accessed atomically
+	live  int           // tasks still running, guarded by the global lock
+	idle  chan struct{} // closed when live drops to 0
+
+	// The budgets of the run, guarded by the global lock once there are
+	// tasks.
+	steps int // taken by the program and its tasks
+	alloc int // bytes allocated by the program and its tasks
+
+	// stop cancels the context of the tasks, nil until the run spawns
+	// the first one.
+	stop context.CancelFunc
+
+	stdinBuf *bufio.Reader // buffers Stdin
+	stdinSrc io.Reader     // that stdinBuf reads from
+
+	sandbox tempSandbox
+}
+
+func (sh *shared) spawned() bool {
+	return atomic.LoadInt32(&sh.tasks) > 0
+}
+
+func (in *Interpreter) lock() {
+	in.sh.mu.Lock()
+	in.locked = true
+}
+
+func (in *Interpreter) unlock() {
+	in.locked = false
+	in.sh.mu.Unlock()
+}
+
+// yield lets the other threads run.
+func (in *Interpreter) yield() {
+	in.sh.mu.Unlock()
+	runtime.Gosched()
+	in.sh.mu.Lock()
+}
+
+// alone tells if the thread is the program and no task is left to run, so
+// that nobody can be on the other side of a channel.
+func (in *Interpreter) alone() bool {
+	return !in.isTask && in.sh.live == 0
+}
+
+// idle returns the channel that is closed when the last task returns, for
+// the program to stop waiting on a channel. Tasks get nil, the program may
+// still be on the other side.
+func (in *Interpreter) idle() chan struct{} {
+	if in.isTask {
+		return nil
+	}
+	return in.sh.idle
+}
+
+// blocking runs wait without the lock, if the thread holds it.
+func (in *Interpreter) blocking(wait func()) {
+	if !in.locked {
+		wait()
+		return
+	}
+	in.sh.mu.Unlock()
+	defer in.sh.mu.Lock()
+	wait()
+}
+
+type task struct {
+	done   chan struct{} // closed when the task returns
+	result Value
+	err    error
+}
+
+func (t *task) String() string {
+	return "<task>"
+}
+
+// spawn starts a task that calls f.
+func (in *Interpreter) spawn(f Value) (Value, error) {
+	if f.kind != funKind {
+		return nilValue, fmt.Errorf("'%v' is not a function", f)
+	}
+	fn := f.asCallable()
+	if fn.arity() != 0 {
+		return nilValue, fmt.Errorf("the function of a task cannot have parameters")
+	}
+
+	// From now on the threads take turns.
+	atomic.AddInt32(&in.sh.tasks, 1)
+	if !in.locked {
+		in.lock()
+	}
+	in.watch = true
+	if err := in.step(); err != nil {
+		return nilValue, err
+	}
+	if err := in.allocate(taskSize); err != nil {
+		return nilValue, err
+	}
+	if in.sh.stop == nil {
+		// The first task of the run, which is spawned by the program.
+		in.ctx, in.sh.stop = context.WithCancel(in.context())
+		in.done = in.ctx.Done()
+	}
+	if in.sh.live == 0 {
+		in.sh.idle = make(chan struct{})
+	}
+	in.sh.live++
+
+	th := *in
+	th.callStack = nil
+	th.scopes = nil
+	th.vm = nil
+	th.locked = false
+	th.isTask = true
+	t := &task{done: make(chan struct{})}
+	go func() {
+		defer close(t.done)
+		th.lock()
+		defer th.unlock()
+		t.result, t.err = th.call(fn)
+		if th.sh.live--; th.sh.live == 0 {
+			close(th.sh.idle)
+		}
+	}()
+	return Value{kind: hostKind, obj: &hostObj{t}}, nil
+}
+
+// stopTasks stops the tasks of the run that are still running and waits
+// until they returned. The next run goes without the lock until it spawns
+// a task of its own.
+func (in *Interpreter) stopTasks() {
+	sh := in.sh
+	if sh.stop != nil {
+		sh.stop()
+		sh.stop = nil
+		if sh.live > 0 {
+			idle := sh.idle
+			in.blocking(func() { <-idle })
+		}
+	}
+	atomic.StoreInt32(&sh.tasks, 0)
+}
+
+// call calls fn without arguments at the bottom of the thread.
+func (in *Interpreter) call(fn Callable) (result Value, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			re, ok := e.(RuntimeError)
+			if !ok {
+				panic(e)
+			}
+			err = re
+		}
+	}()
+	switch fn := fn.(type) {
+	case *closure:
+		in.vm = newVM(in)
+		return in.vm.callClosure(fn, nil), nil
+	case *nativeFn:
+		if err := in.permit(fn); err != nil {
+			return nilValue, err
+		}
+		return fn.fn(in, nil)
+	}
+	return fn.call(&Env{globals: in.globals, in: in}, nil), nil
+}
+
+// join waits for the task in v and returns its result.
+func (in *Interpreter) join(v Value) (Value, error) {
+	var t *task
+	if v.kind == hostKind {
+		t, _ = v.obj.(*hostObj).v.(*task)
+	}
+	if t == nil {
+		return nilValue, fmt.Errorf("'%v' is not a task", v)
+	}
+	in.blocking(func() { <-t.done })
+	return t.result, t.err
+}
+
+// Channels
+//
+// channel(capacity) makes a channel that buffers up to capacity values,
+// send(ch, v) sends v on it and receive(ch) receives the next value, both
+// waiting for the other side while the channel is full or empty. close(ch)
+// says that no more values will be sent: receive then returns the values
+// still buffered and after them nil.
+//
+// A thread that waits on a channel hands the lock over like join() does,
+// and stops waiting when the context of the run is done. Until the first
+// task is spawned, and once all of them returned, nobody could be on the
+// other side of the program, so a send or a receive that would wait fails
+// instead.
+
+type channel struct {
+	ch     chan Value
+	closed bool // guarded by the global lock
+}
+
+func (c *channel) String() string {
+	return "<channel>"
+}
+
+func (in *Interpreter) makeChannel(capacity Value) (Value, error) {
+	n, ok := capacity.Number()
+	if !ok || n < 0 || n != float64(int(n)) {
+		return nilValue, fmt.Errorf("capacity must be a non-negative integer")
+	}
+	c := &channel{ch: make(chan Value, int(n))}
+	return Value{kind: hostKind, obj: &hostObj{c}}, nil
+}
+
+// channelArg returns argument i of a native, which must be a channel.
+func channelArg(args []Value, i int) (*channel, error) {
+	var c *channel
+	if v := args[i]; v.kind == hostKind {
+		c, _ = v.obj.(*hostObj).v.(*channel)
+	}
+	if c == nil {
+		return nil, fmt.Errorf("argument %v must be a channel", i+1)
+	}
+	return c, nil
+}
+
+func (in *Interpreter) send(c *channel, v Value) (err error) {
+	if c.closed {
+		return errors.New("send on a closed channel")
+	}
+	deadlock := errors.New("send would wait forever: no task can receive")
+	if !in.sh.spawned() || in.alone() {
+		select {
+		case c.ch <- v:
+			return nil
+		default:
+			return deadlock
+		}
+	}
+	idle := in.idle()
+	defer func() {
+		// The channel was closed while we waited.
+		if e := recover(); e != nil {
+			err = errors.New("send on a closed channel")
+		}
+	}()
+	in.blocking(func() {
+		select {
+		case c.ch <- v:
+		case <-idle:
+			select {
+			case c.ch <- v:
+			default:
+				err = deadlock
+			}
+		case <-in.done:
+			err = in.ctx.Err()
+		}
+	})
+	return err
+}
+
+func (in *Interpreter) receive(c *channel) (v Value, err error) {
+	deadlock := errors.New("receive would wait forever: no task can send")
+	if !in.sh.spawned() || in.alone() {
+		select {
+		case v = <-c.ch:
+			return v, nil
+		default:
+			if c.closed {
+				return nilValue, nil
+			}
+			return nilValue, deadlock
+		}
+	}
+	idle := in.idle()
+	in.blocking(func() {
+		select {
+		case v = <-c.ch:
+		case <-idle:
+			select {
+			case v = <-c.ch:
+			default:
+				err = deadlock
+			}
+		case <-in.done:
+			err = in.ctx.Err()
+		}
+	})
+	return v, err
+}
+
+func (in *Interpreter) closeChannel(c *channel) error {
+	if c.closed {
+		return errors.New("close of a closed channel")
+	}
+	c.closed = true
+	close(c.ch)
+	return nil
+}
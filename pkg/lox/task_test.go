@@ -0,0 +1,184 @@
+package lox
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestSpawnJoin checks what join returns for tasks, and that tasks share
+// the globals and captured variables with the program that spawned them.
+func TestSpawnJoin(t *testing.T) {
+	tests := []programTest{
+		{`var t = spawn(fun() { return "r"; }); print join(t); print join(t); print t;`, "r\nr\n<task>\n"},
+		{`fun f() {} print join(spawn(f));`, "nil\n"},
+		{`var a = spawn(fun() { return 1; }); var b = spawn(fun() { return 2; }); print join(b) + join(a);`, "3\n"},
+		{`var n = 0;
+		  fun work() { var i = 0; while (i < 1000) { n = n + 1; i = i + 1; } }
+		  var ts = spawn(work); var us = spawn(work); work(); join(ts); join(us); print n;`, "3000\n"},
+		{`fun f() { var x = 1; var t = spawn(fun() { x = x + 10; return x; }); print join(t); print x; } f();`, "11\n11\n"},
+		{`var t = spawn(fun() { return spawn(fun() { return "inner"; }); }); print join(join(t));`, "inner\n"},
+		{`var e = spawn(fun() { return nil + 1; }); print "before"; join(e); print "after";`,
+			"before\n[line 1] runtime error: operands of '+' must be two numbers or two strings, got nil and number"},
+		{`spawn(1);`, "[line 1] runtime error: native 'spawn' failed: '1' is not a function"},
+		{`spawn(fun(a) {});`, "[line 1] runtime error: native 'spawn' failed: the function of a task cannot have parameters"},
+		{`join(1);`, "[line 1] runtime error: native 'join' failed: '1' is not a task"},
+	}
+	testPrograms(t, tests)
+}
+
+// TestTaskBudgets spawns tasks that together take more than the budgets of
+// the run, each of them alone would fit.
+func TestTaskBudgets(t *testing.T) {
+	const src = `
+fun grow() { var s = "x"; var i = 0; while (i < 15) { s = s + s; i = i + 1; } return s; }
+var first = nil;
+var i = 0;
+while (i < 40) {
+	var t = spawn(grow);
+	if (first == nil) first = t;
+	i = i + 1;
+}
+join(first);
+`
+	tests := []struct {
+		opt  Option
+		want error
+	}{
+		{WithMaxAlloc(100000), ErrMemoryLimit},
+		{WithMaxSteps(100), ErrStepBudget},
+	}
+	for _, backend := range backends {
+		for _, tt := range tests {
+			in := New(WithBackend(backend), WithStdout(io.Discard), tt.opt)
+			if err := in.Run(src); !errors.Is(err, tt.want) {
+				t.Errorf("%v backend: got %v, want %v", backend, err, tt.want)
+			}
+		}
+	}
+}
+
+// TestTasksStopWithRun returns from a run that left a task looping forever,
+// which must be stopped by the time Run returns.
+func TestTasksStopWithRun(t *testing.T) {
+	const src = `
+var ch = channel(0);
+spawn(fun() { send(ch, 1); while (true) {} });
+spawn(fun() { receive(channel(0)); });
+receive(ch);
+`
+	for _, backend := range backends {
+		in := New(WithBackend(backend))
+		done := make(chan error)
+		go func() { done <- in.Run(src) }()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("%v backend: %v", backend, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%v backend: still running", backend)
+		}
+		if in.sh.live != 0 {
+			t.Errorf("%v backend: %v tasks still running", backend, in.sh.live)
+		}
+		if in.sh.spawned() {
+			t.Errorf("%v backend: the next run would take the lock", backend)
+		}
+	}
+}
+
+// TestChannelDeadlock waits on channels that nobody can use anymore, which
+// must fail instead of hanging.
+func TestChannelDeadlock(t *testing.T) {
+	tests := []struct {
+		src, err string
+	}{
+		{`var ch = channel(0); receive(ch);`, "receive would wait forever"},
+		{`var ch = channel(0); send(ch, 1);`, "send would wait forever"},
+		{`var ch = channel(0);
+		  var t = spawn(fun() { send(ch, 1); });
+		  receive(ch);
+		  join(t);
+		  receive(ch);`, "receive would wait forever"},
+		{`var ch = channel(1);
+		  var t = spawn(fun() { send(ch, 1); });
+		  join(t);
+		  send(ch, 2);`, "send would wait forever"},
+		{`var ch = channel(0);
+		  spawn(fun() { var i = 0; while (i < 1000) i = i + 1; });
+		  receive(ch);`, "receive would wait forever"},
+	}
+	for _, backend := range backends {
+		for _, tt := range tests {
+			done := make(chan string)
+			go func() { done <- runProgram(t, tt.src, WithBackend(backend)) }()
+			select {
+			case out := <-done:
+				if !strings.Contains(out, tt.err) {
+					t.Errorf("%v backend, %q: got %q, want the error %q", backend, tt.src, out, tt.err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%v backend, %q: still waiting", backend, tt.src)
+			}
+		}
+	}
+}
+
+// sharedGlobals runs tasks that read and write the same globals while the
+// program does too.
+const sharedGlobals = `
+var n = 0;
+var last = nil;
+fun work(id) {
+	return fun() {
+		var i = 0;
+		while (i < 300) {
+			n = n + 1;
+			last = id;
+			i = i + 1;
+		}
+		return i;
+	};
+}
+var t1 = spawn(work(1));
+var t2 = spawn(work(2));
+var t3 = spawn(work(3));
+var i = 0;
+while (i < 300) {
+	n = n + 1;
+	i = i + 1;
+}
+print join(t1) + join(t2) + join(t3) + i;
+print n;
+print last != nil;
+`
+
+// TestTasksSharedGlobals runs programs whose tasks share globals in several
+// interpreters at once, run it with -race to check the global lock. No
+// step happens within `n = n + 1`, so no increment is lost.
+func TestTasksSharedGlobals(t *testing.T) {
+	const want = "1200\n1200\ntrue\n"
+	var wg sync.WaitGroup
+	outs := make([]string, 8)
+	for i := range outs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			backend := "tree"
+			if i%2 == 1 {
+				backend = "vm"
+			}
+			outs[i] = runProgram(t, sharedGlobals, WithBackend(backend))
+		}(i)
+	}
+	wg.Wait()
+	for i, out := range outs {
+		if out != want {
+			t.Errorf("interpreter %v: got %q, want %q", i, out, want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package lox
+
+import "time"
+
+// Timings add up the time that the runs of an interpreter spend in each of
+// their phases, see Interpreter.Timings. Optimize is only spent with
+// Interpreter.Optimize and Compile with the vm backend.
+type Timings struct {
+	Scan, Parse, Optimize, Resolve, Compile, Run time.Duration
+}
+
+// Total returns the time spent in all of the phases.
+func (t *Timings) Total() time.Duration {
+	return t.Scan + t.Parse + t.Optimize + t.Resolve + t.Compile + t.Run
+}
+
+type phase int
+
+const (
+	scanPhase phase = iota
+	parsePhase
+	optimizePhase
+	resolvePhase
+	compilePhase
+	runPhase
+)
+
+// add adds the time since start to phase p of t, if there is t. It returns
+// the time at which the next phase starts.
+func (t *Timings) add(p phase, start time.Time) time.Time {
+	if t == nil {
+		return start
+	}
+	now := time.Now()
+	d := now.Sub(start)
+	switch p {
+	case scanPhase:
+		t.Scan += d
+	case parsePhase:
+		t.Parse += d
+	case optimizePhase:
+		t.Optimize += d
+	case resolvePhase:
+		t.Resolve += d
+	case compilePhase:
+		t.Compile += d
+	case runPhase:
+		t.Run += d
+	}
+	return now
+}
@@ -0,0 +1,81 @@
+package lox
+
+import "fmt"
+
+type TokenType uint
+
+//go:generate stringer -type TokenType -linecomment tokens.go
+
+const (
+	// single character tokens
+	_          TokenType = iota
+	LeftParen            // (
+	RightParen           // )
+	LeftBrace            // {
+	RightBrace           // }
+	Comma                // ,
+	Dot                  // .
+	Minus                // -
+	Plus                 // +
+	Semicolon            // ;
+	Colon                // :
+	Question             // ?
+	Slash                // /
+	Star                 // *
+
+	Bang         // !
+	BangEqual    // !=
+	Equal        // =
+	EqualEqual   // ==
+	Greater      // >
+	GreaterEqual // >=
+	Less         // <
+	LessEqual    // <=
+
+	Identifier // ident
+	String     // string
+	Number     // number
+
+	And      // and
+	Break    // break
+	Class    // class
+	Continue // continue
+	Else     // else
+	False    // false
+	Fun      // fun
+	For      // for
+	If       // if
+	Nil      // nil
+	Or       // or
+	Print    // print
+	Return   // return
+	Super    // super
+	This     // this
+	True     // true
+	Var      // var
+	While    // while
+
+	EOF // eof
+
+	Comment // comment
+)
+
+type Token struct {
+	tok     TokenType
+	lexeme  string
+	line    int
+	col     int // 1-based byte column of the first character
+	pos     int // byte offset of the first character in the source
+	literal interface{}
+}
+
+func (t *Token) Type() TokenType      { return t.tok }
+func (t *Token) Lexeme() string       { return t.lexeme }
+func (t *Token) Literal() interface{} { return t.literal }
+func (t *Token) Line() int            { return t.line }
+func (t *Token) Col() int             { return t.col }
+func (t *Token) Pos() int             { return t.pos }
+
+func (t *Token) String() string {
+	return fmt.Sprintf("token: %v lex: %v lit: %v", t.tok, t.lexeme, t.literal)
+}
@@ -0,0 +1,232 @@
+package lox
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ysmolsky/glox/pkg/loxrt"
+)
+
+// Value is a Lox value. It is a tagged union rather than an interface, so
+// that numbers and booleans are stored in place and arithmetic in hot loops
+// does not allocate a box for every intermediate result. The zero Value is
+// nil.
+type Value struct {
+	kind valueKind
+	num  float64     // numbers, and booleans as 0 or 1
+	obj  interface{} // string for strings, Callable for functions, *hostObj
+}
+
+type valueKind uint8
+
+const (
+	nilKind valueKind = iota
+	boolKind
+	numKind
+	strKind
+	funKind
+	hostKind       // a Go value that has no Lox counterpart, see ToValue
+	unassignedKind // see unassigned
+)
+
+var (
+	nilValue = Value{}
+
+	// unassigned is the value of a variable declared without an
+	// initializer until something is assigned to it. It is distinct from
+	// nil, so that `var a;` can be told apart from `var a = nil;`.
+	unassigned = Value{kind: unassignedKind}
+)
+
+// NumberValue, BoolValue and StringValue make the values that natives of
+// the host return to scripts.
+func NumberValue(f float64) Value {
+	return Value{kind: numKind, num: f}
+}
+
+func BoolValue(b bool) Value {
+	if b {
+		return Value{kind: boolKind, num: 1}
+	}
+	return Value{kind: boolKind}
+}
+
+func StringValue(s string) Value {
+	return Value{kind: strKind, obj: s}
+}
+
+func funValue(fn Callable) Value {
+	return Value{kind: funKind, obj: fn}
+}
+
+// literalValue converts the literal of a token.
+func literalValue(lit interface{}) Value {
+	switch l := lit.(type) {
+	case float64:
+		return NumberValue(l)
+	case string:
+		return StringValue(l)
+	}
+	return nilValue
+}
+
+// Interface returns v as a Go value: nil, bool, float64, string, Callable
+// or the Go value of a host value.
+func (v Value) Interface() interface{} {
+	switch v.kind {
+	case boolKind:
+		return v.asBool()
+	case numKind:
+		return v.num
+	case strKind, funKind:
+		return v.obj
+	case hostKind:
+		return v.obj.(*hostObj).v
+	}
+	return nil
+}
+
+// Type names the type of v: nil, boolean, number, string, function, native
+// for the functions of Go, host for the other Go values, or uninitialized
+// for a variable declared without a value.
+func (v Value) Type() string {
+	switch v.kind {
+	case nilKind:
+		return "nil"
+	case boolKind:
+		return "boolean"
+	case numKind:
+		return "number"
+	case strKind:
+		return "string"
+	case funKind:
+		if _, ok := v.obj.(*nativeFn); ok {
+			return "native"
+		}
+		return "function"
+	case hostKind:
+		return "host"
+	}
+	return "uninitialized"
+}
+
+func (v Value) IsNil() bool {
+	return v.kind == nilKind
+}
+
+// Number returns the number in v, ok is false if v is not a number.
+func (v Value) Number() (f float64, ok bool) {
+	return v.num, v.kind == numKind
+}
+
+// Bool returns the boolean in v, ok is false if v is not a boolean.
+func (v Value) Bool() (b, ok bool) {
+	ok = v.kind == boolKind
+	return ok && v.asBool(), ok
+}
+
+// Str returns the string in v, ok is false if v is not a string.
+func (v Value) Str() (s string, ok bool) {
+	s, ok = v.obj.(string)
+	return s, ok && v.kind == strKind
+}
+
+func (v Value) asBool() bool {
+	return v.num != 0
+}
+
+func (v Value) asString() string {
+	return v.obj.(string)
+}
+
+func (v Value) asCallable() Callable {
+	return v.obj.(Callable)
+}
+
+// false and nil are the only falsey values
+func isTruthy(v Value) bool {
+	switch v.kind {
+	case nilKind:
+		return false
+	case boolKind:
+		return v.asBool()
+	}
+	return true
+}
+
+// comparands returns numbers that compare like x and y, ok is false if they
+// cannot be compared. Two numbers are returned as they are. Two strings
+// compare in the lexicographic order of their bytes, which is that of their
+// code points, so they are turned into strings.Compare(x, y) and 0.
+func comparands(x, y Value) (a, b float64, ok bool) {
+	switch {
+	case x.kind == numKind && y.kind == numKind:
+		return x.num, y.num, true
+	case x.kind == strKind && y.kind == strKind:
+		return float64(strings.Compare(x.asString(), y.asString())), 0, true
+	}
+	return 0, 0, false
+}
+
+// operandsError is the message of the runtime error of the binary operator
+// op, that wants operands other than x and y.
+func operandsError(op, want string, x, y Value) string {
+	return fmt.Sprintf("operands of '%v' must be %v, got %v and %v", op, want, x.Type(), y.Type())
+}
+
+// isEqual is the == of Lox. Values of different kinds are never equal.
+// Numbers are equal as IEEE 754 has it, so NaN is not equal to itself and
+// -0 is equal to 0. Strings are equal if they have the same characters,
+// the functions, host values and the other objects only to themselves.
+func isEqual(x, y Value) bool {
+	if x.kind != y.kind {
+		return false
+	}
+	switch x.kind {
+	case nilKind:
+		return true
+	case boolKind, numKind:
+		return x.num == y.num
+	}
+	return x.obj == y.obj
+}
+
+// deepEqual is the deepEquals() of Lox. It is isEqual but for NaN, which
+// is equal to itself, and the host values, which are equal if their Go
+// values are deeply equal as with reflect.DeepEqual.
+func deepEqual(x, y Value) bool {
+	switch {
+	case x.kind == numKind && y.kind == numKind && x.num != x.num:
+		return y.num != y.num
+	case x.kind == hostKind && y.kind == hostKind:
+		return reflect.DeepEqual(x.obj.(*hostObj).v, y.obj.(*hostObj).v)
+	}
+	return isEqual(x, y)
+}
+
+// String formats v as print shows it, and as toString() converts it.
+// Strings are as they are, with no quotes.
+func (v Value) String() string {
+	switch v.kind {
+	case nilKind:
+		return "nil"
+	case boolKind:
+		return strconv.FormatBool(v.asBool())
+	case numKind:
+		return loxrt.FormatNumber(v.num)
+	case strKind:
+		return v.asString()
+	}
+	return fmt.Sprint(v.obj)
+}
+
+// Quoted formats v like String, but quotes strings to tell them apart from
+// the other values, so that "1" is not shown as 1.
+func (v Value) Quoted() string {
+	if v.kind == strKind {
+		return strconv.Quote(v.asString())
+	}
+	return v.String()
+}
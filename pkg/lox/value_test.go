@@ -0,0 +1,130 @@
+package lox
+
+import (
+	"math"
+	"testing"
+)
+
+func TestValueConversions(t *testing.T) {
+	tests := []struct {
+		v      Value
+		typ    string
+		str    string
+		quoted string
+		truthy bool
+		iface  interface{}
+	}{
+		{Value{}, "nil", "nil", "nil", false, nil},
+		{BoolValue(true), "boolean", "true", "true", true, true},
+		{BoolValue(false), "boolean", "false", "false", false, false},
+		{NumberValue(0), "number", "0", "0", true, 0.0},
+		{NumberValue(2.5), "number", "2.5", "2.5", true, 2.5},
+		{NumberValue(-3), "number", "-3", "-3", true, -3.0},
+		{StringValue(""), "string", "", `""`, true, ""},
+		{StringValue("1"), "string", "1", `"1"`, true, "1"},
+	}
+	for _, tt := range tests {
+		if got := tt.v.Type(); got != tt.typ {
+			t.Errorf("%#v: Type() = %q, want %q", tt.v, got, tt.typ)
+		}
+		if got := tt.v.String(); got != tt.str {
+			t.Errorf("%#v: String() = %q, want %q", tt.v, got, tt.str)
+		}
+		if got := tt.v.Quoted(); got != tt.quoted {
+			t.Errorf("%#v: Quoted() = %q, want %q", tt.v, got, tt.quoted)
+		}
+		if got := isTruthy(tt.v); got != tt.truthy {
+			t.Errorf("%#v: isTruthy = %v, want %v", tt.v, got, tt.truthy)
+		}
+		if got := tt.v.Interface(); got != tt.iface {
+			t.Errorf("%#v: Interface() = %#v, want %#v", tt.v, got, tt.iface)
+		}
+	}
+}
+
+func TestValueAccessors(t *testing.T) {
+	if f, ok := NumberValue(4).Number(); !ok || f != 4 {
+		t.Errorf("Number() = %v, %v", f, ok)
+	}
+	if _, ok := BoolValue(true).Number(); ok {
+		t.Error("a boolean is not a number")
+	}
+	if b, ok := BoolValue(true).Bool(); !ok || !b {
+		t.Errorf("Bool() = %v, %v", b, ok)
+	}
+	if _, ok := NumberValue(1).Bool(); ok {
+		t.Error("a number is not a boolean")
+	}
+	if s, ok := StringValue("s").Str(); !ok || s != "s" {
+		t.Errorf("Str() = %q, %v", s, ok)
+	}
+	if _, ok := (Value{}).Str(); ok {
+		t.Error("nil is not a string")
+	}
+	if !(Value{}).IsNil() || NumberValue(0).IsNil() || unassigned.IsNil() {
+		t.Error("only the zero Value is nil")
+	}
+	if got := unassigned.Type(); got != "uninitialized" {
+		t.Errorf("unassigned.Type() = %q", got)
+	}
+}
+
+func TestValueEquality(t *testing.T) {
+	nan := NumberValue(math.NaN())
+	tests := []struct {
+		x, y      Value
+		equal     bool
+		deepEqual bool
+	}{
+		{Value{}, Value{}, true, true},
+		{Value{}, BoolValue(false), false, false},
+		{BoolValue(false), NumberValue(0), false, false},
+		{BoolValue(true), BoolValue(true), true, true},
+		{NumberValue(1), NumberValue(1), true, true},
+		{NumberValue(1), StringValue("1"), false, false},
+		{NumberValue(0), NumberValue(math.Copysign(0, -1)), true, true},
+		{nan, nan, false, true},
+		{StringValue("ab"), StringValue("a" + string([]byte{'b'})), true, true},
+		{StringValue("a"), StringValue("b"), false, false},
+	}
+	for _, tt := range tests {
+		if got := isEqual(tt.x, tt.y); got != tt.equal {
+			t.Errorf("isEqual(%v, %v) = %v, want %v", tt.x, tt.y, got, tt.equal)
+		}
+		if got := deepEqual(tt.x, tt.y); got != tt.deepEqual {
+			t.Errorf("deepEqual(%v, %v) = %v, want %v", tt.x, tt.y, got, tt.deepEqual)
+		}
+	}
+}
+
+// TestNumbersDoNotAllocate checks that arithmetic and comparisons on
+// numbers and booleans run without allocating, which is the point of the
+// tagged union.
+func TestNumbersDoNotAllocate(t *testing.T) {
+	x, y := NumberValue(3), NumberValue(4)
+	allocs := testing.AllocsPerRun(100, func() {
+		a, b, _ := comparands(x, y)
+		x = NumberValue(x.num + a*b)
+		if isEqual(x, y) || !isTruthy(BoolValue(a < b)) {
+			y = NumberValue(b + 1)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("got %v allocations, want 0", allocs)
+	}
+}
+
+// BenchmarkArithmetic runs a tight loop of number arithmetic and
+// comparisons on globals.
+func BenchmarkArithmetic(b *testing.B) {
+	benchmarkProgram(b, `
+var x = 0;
+var i = 0;
+while (i < 100000) {
+	x = (x + i * 3 - 1) / 2;
+	if (x > 1000 or x == -1) x = x - 1000;
+	i = i + 1;
+}
+print x;
+`)
+}
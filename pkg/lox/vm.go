@@ -0,0 +1,530 @@
+package lox
+
+import "fmt"
+
+// VM
+//
+// The VM runs the bytecode made by the compiler on a value stack. Every
+// call gets a frame whose slots start at the function being called,
+// followed by the arguments and then the locals.
+//
+// It behaves like the tree-walker, with the same runtime errors and stack
+// traces, except that both operands of an operator are evaluated before
+// their types are checked.
+
+// closure is a function together with the variables it captured.
+type closure struct {
+	fn       *function
+	upvalues []*upvalue
+	vm       *VM
+}
+
+func (c *closure) arity() int {
+	return len(c.fn.params)
+}
+
+// call runs the closure from Go, e.g. from a native function.
+func (c *closure) call(_ *Env, args []Value) Value {
+	return c.vm.callClosure(c, args)
+}
+
+func (c *closure) String() string {
+	return c.fn.String()
+}
+
+// upvalue is a variable captured by a closure. It points into the stack
+// while the variable is in scope and holds the value itself after that.
+type upvalue struct {
+	vm     *VM // whose stack has the variable, a task may have made it
+	slot   int // index into the stack, -1 once closed
+	closed Value
+	next   *upvalue // open upvalues are kept ordered by slot, highest first
+}
+
+type callFrame struct {
+	closure *closure
+	ip      int
+	base    int // stack index of slot 0
+}
+
+type VM struct {
+	stack   []Value
+	sp      int // index of the next free slot
+	frames  []callFrame
+	globals *globals
+	open    *upvalue     // the open upvalues
+	in      *Interpreter // that runs the programs
+}
+
+func newVM(in *Interpreter) *VM {
+	return &VM{
+		stack:   make([]Value, 1024),
+		globals: in.globals,
+		in:      in,
+	}
+}
+
+// interpret runs the compiled top-level code of a program and returns what
+// it returns.
+func (vm *VM) interpret(script *function) (result Value, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			re, ok := e.(RuntimeError)
+			if !ok {
+				panic(e)
+			}
+			vm.sp = 0
+			vm.frames = vm.frames[:0]
+			vm.open = nil
+			err = re
+		}
+	}()
+	return vm.callClosure(&closure{fn: script, vm: vm}, nil), nil
+}
+
+// callClosure calls c with args and runs it to completion.
+func (vm *VM) callClosure(c *closure, args []Value) Value {
+	vm.push(funValue(c))
+	for _, a := range args {
+		vm.push(a)
+	}
+	depth := len(vm.frames)
+	vm.frames = append(vm.frames, callFrame{closure: c, base: vm.sp - len(args) - 1})
+	return vm.run(depth)
+}
+
+func (vm *VM) push(v Value) {
+	if vm.sp == len(vm.stack) {
+		vm.stack = append(vm.stack, make([]Value, len(vm.stack))...)
+	}
+	vm.stack[vm.sp] = v
+	vm.sp++
+}
+
+func (vm *VM) pop() Value {
+	vm.sp--
+	return vm.stack[vm.sp]
+}
+
+func (vm *VM) peek(distance int) Value {
+	return vm.stack[vm.sp-1-distance]
+}
+
+// fail raises a runtime error at the instruction that the innermost frame
+// is executing, with the trace of the frames above the script.
+func (vm *VM) fail(msg string) {
+	panic(vm.newRuntimeError(msg))
+}
+
+func (vm *VM) newRuntimeError(msg string) RuntimeError {
+	// the first frame runs the script or the function of a task, which the
+	// trace does not list
+	trace := make([]frame, 0, len(vm.frames))
+	for i := 1; i < len(vm.frames); i++ {
+		trace = append(trace, frame{fn: vm.frames[i].closure, line: vm.frames[i-1].line()})
+	}
+	return RuntimeError{
+		line:  vm.frames[len(vm.frames)-1].line(),
+		msg:   msg,
+		trace: trace,
+		depth: vm.in.TraceDepth,
+		task:  vm.in.isTask,
+	}
+}
+
+// step counts a step of the program and stops it if it ran out of steps
+// or its context is done.
+func (vm *VM) step() {
+	if err := vm.in.step(); err != nil {
+		vm.stop(err)
+	}
+}
+
+// allocate counts n bytes allocated by the program and stops it if it goes
+// over the limit.
+func (vm *VM) allocate(n int) {
+	if err := vm.in.allocate(n); err != nil {
+		vm.stop(err)
+	}
+}
+
+// stop stops the program because of err.
+func (vm *VM) stop(err error) {
+	re := vm.newRuntimeError(vm.in.stopMsg(err))
+	re.cause = err
+	panic(re)
+}
+
+// line returns the source line of the instruction being executed.
+func (f *callFrame) line() int {
+	return f.closure.fn.chunk.lines[f.ip-1]
+}
+
+func (vm *VM) captureUpvalue(slot int) *upvalue {
+	var prev *upvalue
+	uv := vm.open
+	for uv != nil && uv.slot > slot {
+		prev, uv = uv, uv.next
+	}
+	if uv != nil && uv.slot == slot {
+		return uv
+	}
+	created := &upvalue{vm: vm, slot: slot, next: uv}
+	if prev == nil {
+		vm.open = created
+	} else {
+		prev.next = created
+	}
+	return created
+}
+
+// closeUpvalues moves the variables at slot and above off the stack into
+// the upvalues that captured them.
+func (vm *VM) closeUpvalues(slot int) {
+	for vm.open != nil && vm.open.slot >= slot {
+		uv := vm.open
+		uv.closed = vm.stack[uv.slot]
+		uv.slot = -1
+		vm.open = uv.next
+	}
+}
+
+func (uv *upvalue) get() Value {
+	if uv.slot >= 0 {
+		return uv.vm.stack[uv.slot]
+	}
+	return uv.closed
+}
+
+func (uv *upvalue) set(v Value) {
+	if uv.slot >= 0 {
+		uv.vm.stack[uv.slot] = v
+		return
+	}
+	uv.closed = v
+}
+
+// callValue calls the value below the argc arguments on top of the stack
+// and updates the cache of the call instruction. Calls of closures push a
+// frame that the run loop continues with, natives run right away and leave
+// their result on the stack.
+func (vm *VM) callValue(argc int, site *callCache) {
+	callee := vm.peek(argc)
+	if callee.kind != funKind {
+		vm.fail(fmt.Sprintf("'%v' is not a function or class", callee))
+	}
+	fn := callee.asCallable()
+	if argc != fn.arity() {
+		vm.fail(fmt.Sprintf("expected %v arguments but got %v", fn.arity(), argc))
+	}
+	switch fn := fn.(type) {
+	case *closure:
+		*site = callCache{fn: fn.fn}
+		vm.pushFrame(fn, argc)
+	case *nativeFn:
+		*site = callCache{native: fn}
+		vm.callNativeAt(fn, argc)
+	default:
+		args := make([]Value, argc)
+		copy(args, vm.stack[vm.sp-argc:vm.sp])
+		v := fn.call(nil, args)
+		vm.sp -= argc + 1
+		vm.push(v)
+	}
+}
+
+func (vm *VM) pushFrame(c *closure, argc int) {
+	if vm.in.Hooks != nil {
+		vm.in.onCall(c, append([]Value(nil), vm.stack[vm.sp-argc:vm.sp]...))
+	}
+	if len(vm.frames)-1 >= vm.in.MaxCallDepth {
+		vm.fail(fmt.Sprintf("stack overflow: exceeded %v frames", vm.in.MaxCallDepth))
+	}
+	if vm.in.watch {
+		vm.step()
+	}
+	vm.frames = append(vm.frames, callFrame{closure: c, base: vm.sp - argc - 1})
+}
+
+// callNativeAt calls n with the argc arguments on top of the stack and
+// replaces them and n with the result.
+func (vm *VM) callNativeAt(n *nativeFn, argc int) {
+	args := make([]Value, argc)
+	copy(args, vm.stack[vm.sp-argc:vm.sp])
+	if vm.in.Hooks != nil {
+		vm.in.onCall(n, args)
+	}
+	v := vm.callNative(n, args)
+	if vm.in.Hooks != nil {
+		vm.in.onReturn(v)
+	}
+	vm.sp -= argc + 1
+	vm.push(v)
+}
+
+// callNative calls n and turns whatever goes wrong inside of it into a
+// runtime error at the call, like callNative of the tree-walker.
+func (vm *VM) callNative(n *nativeFn, args []Value) Value {
+	if err := vm.in.permit(n); err != nil {
+		vm.fail(err.Error())
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			if re, ok := e.(RuntimeError); ok {
+				panic(re)
+			}
+			if err, ok := e.(error); ok && stops(err) {
+				vm.stop(err)
+			}
+			vm.fail(fmt.Sprintf("native '%v' failed: %v", n.name, e))
+		}
+	}()
+	v := n.run(vm.in, args)
+	if s, ok := v.Str(); ok {
+		vm.allocate(stringSize + len(s))
+	}
+	return v
+}
+
+// run executes instructions until the frame at depth returns and returns
+// its result.
+func (vm *VM) run(depth int) Value {
+	fr := &vm.frames[len(vm.frames)-1]
+	code := fr.closure.fn.chunk.code
+	constants := fr.closure.fn.chunk.constants
+
+	for {
+		op := opcode(code[fr.ip])
+		fr.ip++
+		switch op {
+		case opConstant:
+			vm.push(constants[readShort(code, &fr.ip)])
+		case opNil:
+			vm.push(nilValue)
+		case opTrue:
+			vm.push(BoolValue(true))
+		case opFalse:
+			vm.push(BoolValue(false))
+		case opUnassigned:
+			vm.push(unassigned)
+		case opPop:
+			vm.sp--
+		case opGetLocal:
+			slot := int(code[fr.ip])
+			fr.ip++
+			vm.push(vm.stack[fr.base+slot])
+		case opGetLocalSafe:
+			slot := int(code[fr.ip])
+			fr.ip++
+			name := constants[readShort(code, &fr.ip)]
+			v := vm.stack[fr.base+slot]
+			if v.kind == unassignedKind {
+				vm.fail("variable '" + name.asString() + "' used before assignment")
+			}
+			vm.push(v)
+		case opSetLocal:
+			slot := int(code[fr.ip])
+			fr.ip++
+			vm.stack[fr.base+slot] = vm.peek(0)
+		case opGetUpvalue:
+			i := code[fr.ip]
+			fr.ip++
+			vm.push(fr.closure.upvalues[i].get())
+		case opGetUpvalueSafe:
+			i := code[fr.ip]
+			fr.ip++
+			name := constants[readShort(code, &fr.ip)]
+			v := fr.closure.upvalues[i].get()
+			if v.kind == unassignedKind {
+				vm.fail("variable '" + name.asString() + "' used before assignment")
+			}
+			vm.push(v)
+		case opSetUpvalue:
+			i := code[fr.ip]
+			fr.ip++
+			fr.closure.upvalues[i].set(vm.peek(0))
+		case opGetGlobal:
+			c := vm.global(fr.closure.fn, readShort(code, &fr.ip))
+			if !c.defined {
+				vm.fail("undefined variable '" + c.name + "'")
+			}
+			if c.v.kind == unassignedKind {
+				vm.fail("variable '" + c.name + "' used before assignment")
+			}
+			vm.push(c.v)
+		case opDefineGlobal:
+			c := vm.global(fr.closure.fn, readShort(code, &fr.ip))
+			c.v = vm.pop()
+			c.defined = true
+		case opSetGlobal:
+			c := vm.global(fr.closure.fn, readShort(code, &fr.ip))
+			if !c.defined {
+				vm.fail("undefined variable '" + c.name + "'")
+			}
+			c.v = vm.peek(0)
+		case opEqual:
+			y := vm.pop()
+			x := vm.pop()
+			vm.push(BoolValue(isEqual(x, y)))
+		case opGreater:
+			x, y := vm.comparands(">")
+			vm.stack[vm.sp-1] = BoolValue(x > y)
+		case opGreaterEqual:
+			x, y := vm.comparands(">=")
+			vm.stack[vm.sp-1] = BoolValue(x >= y)
+		case opLess:
+			x, y := vm.comparands("<")
+			vm.stack[vm.sp-1] = BoolValue(x < y)
+		case opLessEqual:
+			x, y := vm.comparands("<=")
+			vm.stack[vm.sp-1] = BoolValue(x <= y)
+		case opSubtract:
+			x, y := vm.numbers("-")
+			vm.stack[vm.sp-1] = NumberValue(x - y)
+		case opMultiply:
+			x, y := vm.numbers("*")
+			vm.stack[vm.sp-1] = NumberValue(x * y)
+		case opDivide:
+			x, y := vm.numbers("/")
+			if y == 0 && !vm.in.IEEEDivision {
+				vm.fail("division by zero")
+			}
+			vm.stack[vm.sp-1] = NumberValue(x / y)
+		case opAdd:
+			y := vm.pop()
+			x := vm.pop()
+			switch {
+			case x.kind == numKind && y.kind == numKind:
+				vm.stack[vm.sp] = NumberValue(x.num + y.num)
+				vm.sp++
+			case x.kind == strKind && y.kind == strKind:
+				s := x.asString() + y.asString()
+				vm.allocate(stringSize + len(s))
+				vm.push(StringValue(s))
+			default:
+				vm.fail(operandsError("+", "two numbers or two strings", x, y))
+			}
+		case opNot:
+			vm.push(BoolValue(!isTruthy(vm.pop())))
+		case opNegate:
+			x := vm.pop()
+			if x.kind != numKind {
+				vm.fail("operand must be a number")
+			}
+			vm.push(NumberValue(-x.num))
+		case opPrint:
+			fmt.Fprintln(vm.in.Stdout, vm.pop().String())
+		case opJump:
+			offset := readShort(code, &fr.ip)
+			fr.ip += offset
+		case opJumpIfFalse:
+			offset := readShort(code, &fr.ip)
+			if !isTruthy(vm.peek(0)) {
+				fr.ip += offset
+			}
+		case opLoop:
+			offset := readShort(code, &fr.ip)
+			if vm.in.watch {
+				vm.step()
+			}
+			fr.ip -= offset
+		case opCall:
+			argc := int(code[fr.ip])
+			fr.ip++
+			site := &fr.closure.fn.chunk.calls[readShort(code, &fr.ip)]
+			callee := vm.stack[vm.sp-1-argc].obj
+			if c, ok := callee.(*closure); ok && c.fn == site.fn {
+				vm.pushFrame(c, argc)
+			} else if n, ok := callee.(*nativeFn); ok && n == site.native {
+				vm.callNativeAt(n, argc)
+			} else {
+				vm.callValue(argc, site)
+			}
+			// pick up the frame on top
+			fr = &vm.frames[len(vm.frames)-1]
+			code = fr.closure.fn.chunk.code
+			constants = fr.closure.fn.chunk.constants
+		case opClosure:
+			vm.allocate(functionSize)
+			fn := fr.closure.fn.chunk.functions[readShort(code, &fr.ip)]
+			c := &closure{fn: fn, upvalues: make([]*upvalue, fn.upvalues), vm: vm}
+			for i := range c.upvalues {
+				isLocal, index := code[fr.ip], int(code[fr.ip+1])
+				fr.ip += 2
+				if isLocal == 1 {
+					c.upvalues[i] = vm.captureUpvalue(fr.base + index)
+				} else {
+					c.upvalues[i] = fr.closure.upvalues[index]
+				}
+			}
+			vm.push(funValue(c))
+		case opStatement:
+			if h := vm.in.Hooks; h != nil && h.OnScope != nil {
+				h.OnScope(&Scope{vm: vm, base: fr.base, names: fr.closure.fn.chunk.locals[fr.ip-1]})
+			}
+			if h := vm.in.Hooks; h != nil && h.OnStatement != nil {
+				h.OnStatement(fr.line())
+			}
+		case opCloseUpvalue:
+			vm.closeUpvalues(vm.sp - 1)
+			vm.sp--
+		case opReturn:
+			result := vm.pop()
+			vm.closeUpvalues(fr.base)
+			vm.sp = fr.base
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			if len(vm.frames) == depth {
+				return result
+			}
+			if vm.in.Hooks != nil {
+				vm.in.onReturn(result)
+			}
+			vm.push(result)
+			// pick up the frame on top
+			fr = &vm.frames[len(vm.frames)-1]
+			code = fr.closure.fn.chunk.code
+			constants = fr.closure.fn.chunk.constants
+		}
+	}
+}
+
+// global returns the cell of the global named by constant k of fn.
+func (vm *VM) global(fn *function, k int) *globalCell {
+	c := &fn.chunk
+	if c.cells == nil {
+		c.cells = make([]*globalCell, len(c.constants))
+	}
+	return vm.globals.cached(&c.cells[k], c.constants[k].asString())
+}
+
+func readShort(code []byte, ip *int) int {
+	*ip += 2
+	return int(code[*ip-2])<<8 | int(code[*ip-1])
+}
+
+// numbers pops the two operands of the arithmetic operator op, the result
+// goes in the slot of the first one.
+func (vm *VM) numbers(op string) (float64, float64) {
+	x, y := &vm.stack[vm.sp-2], &vm.stack[vm.sp-1]
+	if x.kind != numKind || y.kind != numKind {
+		vm.fail(operandsError(op, "numbers", *x, *y))
+	}
+	vm.sp--
+	return x.num, y.num
+}
+
+// comparands pops the operands of the comparison op like numbers, two
+// strings as the numbers that the function comparands makes of them.
+func (vm *VM) comparands(op string) (float64, float64) {
+	x, y := &vm.stack[vm.sp-2], &vm.stack[vm.sp-1]
+	if x.kind == numKind && y.kind == numKind {
+		vm.sp--
+		return x.num, y.num
+	}
+	a, b, ok := comparands(*x, *y)
+	if !ok {
+		vm.fail(operandsError(op, "two numbers or two strings", *x, *y))
+	}
+	vm.sp--
+	return a, b
+}
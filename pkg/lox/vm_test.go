@@ -0,0 +1,112 @@
+package lox
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// runVM compiles src and runs it on a VM of its own, returning what it
+// printed, followed by its error if any, and the VM to look at afterwards.
+func runVM(t *testing.T, src string) (string, *VM) {
+	t.Helper()
+	stmts := parseProgram(t, src)
+	Resolve(stmts)
+	script, errs := compile(stmts, false)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	var out bytes.Buffer
+	vm := newVM(New(WithStdout(&out)))
+	if _, err := vm.interpret(script); err != nil {
+		out.WriteString(err.Error())
+	}
+	return out.String(), vm
+}
+
+// TestVMStack runs programs that need more stack than the VM starts with
+// and checks that every run leaves the stack empty, also after an error.
+func TestVMStack(t *testing.T) {
+	tests := []programTest{
+		{`fun down(n) { var a = n; var b = a; if (n == 0) return 0; return down(n - 1) + 1; } print down(600);`, "600\n"},
+		// the stack grows while an upvalue points into it
+		{`fun deep(n) { if (n > 0) deep(n - 1); }
+		  fun outer() { var x = "kept"; fun get() { return x; } deep(800); x = x + "!"; return get; }
+		  print outer()();`, "kept!\n"},
+		{`var a = 1; { var b = 2; { var c = 3; print a + b + c; } }`, "6\n"},
+		{`fun f() { { var a = 1; while (true) { var b = a; break; } } return "ok"; } print f();`, "ok\n"},
+		// an error deep down, only the stack is checked
+		{`fun f(n) { if (n == 0) return nil + 1; return f(n - 1); } f(300);`, ""},
+	}
+	for _, tt := range tests {
+		got, vm := runVM(t, tt.src)
+		if tt.want != "" && got != tt.want {
+			t.Errorf("%q:\ngot  %q\nwant %q", tt.src, got, tt.want)
+		}
+		if vm.sp != 0 || len(vm.frames) != 0 || vm.open != nil {
+			t.Errorf("%q: left %v values, %v frames and open upvalues %v", tt.src, vm.sp, len(vm.frames), vm.open != nil)
+		}
+	}
+}
+
+// TestVMUpvalues checks that closures capture variables, not values, and
+// keep them once the frames that declared them returned.
+func TestVMUpvalues(t *testing.T) {
+	tests := []programTest{
+		// a fresh variable for every iteration of the loop body
+		{`var fs = nil; var gs = nil;
+		  for (var i = 0; i < 2; i = i + 1) { var j = i; fun f() { return j; } if (i == 0) fs = f; else gs = f; }
+		  print fs(); print gs();`, "0\n1\n"},
+		// two closures share the variable they both captured
+		{`fun pair() { var n = 0; fun inc() { n = n + 1; } fun get() { return n; } inc(); inc(); return get; }
+		  print pair()();`, "2\n"},
+		{`fun make() { var n = 0; fun inc() { n = n + 1; return n; } return inc; }
+		  var a = make(); var b = make(); a(); a(); print a(); print b();`, "3\n1\n"},
+		// through a function in between that does not use the variable
+		{`fun outer() { var x = "x"; fun middle() { fun inner() { return x; } return inner; } return middle()(); } print outer();`, "x\n"},
+		// a parameter captured and assigned after the capture
+		{`fun f(p) { fun g() { return p; } p = p * 10; return g; } print f(4)();`, "40\n"},
+		// the variable is still open while the closure runs
+		{`fun f() { var v = 1; fun set() { v = 2; } set(); return v; } print f();`, "2\n"},
+	}
+	for _, tt := range tests {
+		if got, _ := runVM(t, tt.src); got != tt.want {
+			t.Errorf("%q:\ngot  %q\nwant %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+// TestVMErrorLines checks the lines of runtime errors and their traces,
+// which the VM finds in the line table of the chunks.
+func TestVMErrorLines(t *testing.T) {
+	tests := []programTest{
+		{"print 1;\n\nprint nil + 1;", "1\n[line 3] runtime error: operands of '+' must be two numbers or two strings, got nil and number"},
+		{"var a = 1;\nvar b = a\n  - \"x\";", "[line 3] runtime error: operands of '-' must be numbers, got number and string"},
+		{"fun f() {\n  return g();\n}\nfun g() {\n  return missing;\n}\nf();",
+			"[line 5] runtime error: undefined variable 'missing'\n  [line 5] in g()\n  [line 2] in f()\n  [line 7] in script"},
+		{"var f = fun() {\n  var x;\n  return x;\n};\nprint 0;\nf();",
+			"0\n[line 3] runtime error: variable 'x' used before assignment\n  [line 3] in anonymous function\n  [line 6] in script"},
+		{"while (true) {\n  var i = 0;\n  3();\n}", "[line 3] runtime error: '3' is not a function or class"},
+	}
+	for _, tt := range tests {
+		if got, _ := runVM(t, tt.src); got != tt.want {
+			t.Errorf("%q:\ngot  %q\nwant %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+// TestVMRunsAgain runs a program that fails on the VM of an interpreter,
+// then another one on the same VM.
+func TestVMRunsAgain(t *testing.T) {
+	var out bytes.Buffer
+	in := New(WithBackend("vm"), WithStdout(&out))
+	if err := in.Run(`fun f() { var a = 1; { var b = 2; return a + b + nil; } } f();`); err == nil {
+		t.Fatal("no error")
+	}
+	if err := in.Run(`fun g(n) { var m = n * 2; return m; } print g(21);`); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "42" {
+		t.Errorf("got %q, want 42", got)
+	}
+}
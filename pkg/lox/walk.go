@@ -0,0 +1,49 @@
+package lox
+
+// Visitor is called by Walk for every node of a tree.
+type Visitor interface {
+	// Enter is called before the children of n are walked, they are
+	// skipped if it returns false.
+	Enter(n Node) bool
+
+	// Leave is called after the children of n, or right after Enter if
+	// they were skipped.
+	Leave(n Node)
+}
+
+// Walk walks the tree of node depth-first, the children of a node in order,
+// and calls v for every node. The bodies of functions are walked too, both
+// the ones of declarations and of anonymous functions. Missing children,
+// like the else of an if without one, are not visited.
+func Walk(v Visitor, node Node) {
+	if !v.Enter(node) {
+		v.Leave(node)
+		return
+	}
+	walkChildren(v, node)
+	v.Leave(node)
+}
+
+func walkStmts(v Visitor, list []Stmt) {
+	for _, s := range list {
+		Walk(v, s)
+	}
+}
+
+func walkExprs(v Visitor, list []Expr) {
+	for _, e := range list {
+		Walk(v, e)
+	}
+}
+
+// inspector turns a function into a Visitor without Leave.
+type inspector func(Node) bool
+
+func (f inspector) Enter(n Node) bool { return f(n) }
+func (f inspector) Leave(Node)        {}
+
+// Inspect walks the tree of node like Walk and calls f before the children
+// of every node, they are skipped if f returns false.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,254 @@
+// Package loxrt is the runtime of the Go programs that glox build makes out
+// of Lox scripts. The values, operators, calls and natives behave like
+// those of the interpreter in package lox, and the runtime errors read the
+// same, stack traces included. The natives are denied by their
+// capability like in the interpreter, see Allow. The programs do not count
+// steps or allocations and cannot spawn tasks.
+package loxrt
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Value is a Lox value: nil, a boolean, a number, a string or a function.
+type Value struct {
+	kind kind
+	num  float64
+	str  string
+	fn   *Func
+}
+
+type kind uint8
+
+const (
+	nilKind kind = iota
+	boolKind
+	numKind
+	strKind
+	funKind
+	unassignedKind
+)
+
+// The values that are not made by the functions below.
+var (
+	Nil        = Value{}
+	True       = Value{kind: boolKind, num: 1}
+	False      = Value{kind: boolKind}
+	Unassigned = Value{kind: unassignedKind} // of a variable declared without a value
+)
+
+func Number(f float64) Value { return Value{kind: numKind, num: f} }
+func String(s string) Value  { return Value{kind: strKind, str: s} }
+
+func Bool(b bool) Value {
+	if b {
+		return True
+	}
+	return False
+}
+
+// Truthy tells if v counts as true, only nil and false do not.
+func Truthy(v Value) bool {
+	switch v.kind {
+	case nilKind:
+		return false
+	case boolKind:
+		return v.num != 0
+	}
+	return true
+}
+
+// Equal tells if x == y, NaN is not equal to itself and the functions are
+// equal to themselves only.
+func Equal(x, y Value) bool {
+	if x.kind != y.kind {
+		return false
+	}
+	switch x.kind {
+	case nilKind:
+		return true
+	case boolKind, numKind:
+		return x.num == y.num
+	case strKind:
+		return x.str == y.str
+	}
+	return x.fn == y.fn
+}
+
+func (v Value) String() string {
+	switch v.kind {
+	case nilKind:
+		return "nil"
+	case boolKind:
+		return strconv.FormatBool(v.num != 0)
+	case numKind:
+		return FormatNumber(v.num)
+	case strKind:
+		return v.str
+	case funKind:
+		return v.fn.String()
+	}
+	return "<uninitialized>"
+}
+
+// FormatNumber formats f as print shows it, in decimal and without a
+// fraction if it is a whole number, so that 1000000 is not shown as 1e+06.
+// The numbers below 1e-4 or from 1e21 on, which would be too long, have an
+// exponent. The interpreter formats its numbers with it too.
+func FormatNumber(f float64) string {
+	if a := math.Abs(f); a == 0 || a >= 1e-4 && a < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Func is a function of the script or a native.
+type Func struct {
+	name   string
+	params []string
+	native bool
+	cap    string // of a native, see Allow
+	fn     func(args []Value) Value
+}
+
+// Function returns the function called name, empty for an anonymous one,
+// with the params that fn gets as its arguments.
+func Function(name string, params []string, fn func(args []Value) Value) Value {
+	return Value{kind: funKind, fn: &Func{name: name, params: params, fn: fn}}
+}
+
+func (f *Func) String() string {
+	switch {
+	case f.native:
+		return fmt.Sprintf("<native fn %v>", f.name)
+	case f.name == "":
+		return fmt.Sprintf("<lambda (%v)>", strings.Join(f.params, ","))
+	}
+	return fmt.Sprintf("<fn %v>", f.name)
+}
+
+// frameName names the frame of a call to f in stack traces.
+func (f *Func) frameName() string {
+	if f.name == "" {
+		return "anonymous function"
+	}
+	return f.name + "()"
+}
+
+// MaxCallDepth is the maximum depth of nested function calls.
+var MaxCallDepth = 10000
+
+// frame is an active call of a function of the script.
+type frame struct {
+	fn   *Func
+	line int // of the call site in the caller
+}
+
+var callStack []frame
+
+// Call calls callee with args at line.
+func Call(line int, callee Value, args ...Value) Value {
+	if callee.kind != funKind {
+		Fail(line, fmt.Sprintf("'%v' is not a function or class", callee))
+	}
+	f := callee.fn
+	if len(args) != len(f.params) {
+		Fail(line, fmt.Sprintf("expected %v arguments but got %v", len(f.params), len(args)))
+	}
+	if f.native {
+		return callNative(line, f, args)
+	}
+	if len(callStack) >= MaxCallDepth {
+		Fail(line, fmt.Sprintf("stack overflow: exceeded %v frames", MaxCallDepth))
+	}
+	callStack = append(callStack, frame{f, line})
+	v := f.fn(args)
+	callStack = callStack[:len(callStack)-1]
+	return v
+}
+
+// Global is a global variable, globals are bound late and may be declared
+// again.
+type Global struct {
+	v       Value
+	defined bool
+}
+
+// Define declares g with the value v, or sets it if it is declared.
+func (g *Global) Define(v Value) {
+	g.v, g.defined = v, true
+}
+
+// Get returns the value of g, called name, at line.
+func (g *Global) Get(line int, name string) Value {
+	if !g.defined {
+		Fail(line, "undefined variable '"+name+"'")
+	}
+	return Check(line, name, g.v)
+}
+
+// Set assigns v to g, called name, at line and returns v.
+func (g *Global) Set(line int, name string, v Value) Value {
+	if !g.defined {
+		Fail(line, "undefined variable '"+name+"'")
+	}
+	g.v = v
+	return v
+}
+
+// Check returns v, the value of the variable name at line, unless it was
+// never assigned.
+func Check(line int, name string, v Value) Value {
+	if v.kind == unassignedKind {
+		Fail(line, "variable '"+name+"' used before assignment")
+	}
+	return v
+}
+
+// Load returns v. The programs read the locals with it where Go would not
+// read them in the order of the script.
+func Load(v Value) Value {
+	return v
+}
+
+// Set assigns v to the local variable at p and returns v.
+func Set(p *Value, v Value) Value {
+	*p = v
+	return v
+}
+
+var stdout = bufio.NewWriter(os.Stdout)
+
+// Print prints v on a line of its own.
+func Print(v Value) {
+	stdout.WriteString(v.String())
+	stdout.WriteByte('\n')
+}
+
+// Main runs the script in run with the arguments of the program and exits
+// with 1 after printing the error if it fails.
+func Main(run func()) {
+	args = os.Args[1:]
+	code := 0
+	func() {
+		defer func() {
+			if e := recover(); e != nil {
+				re, ok := e.(RuntimeError)
+				if !ok {
+					panic(e)
+				}
+				stdout.WriteString(re.Error() + "\n")
+				code = 1
+			}
+		}()
+		run()
+	}()
+	stdout.Flush()
+	cleanup()
+	os.Exit(code)
+}
@@ -0,0 +1,220 @@
+package loxrt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// natives are the functions of Go that scripts can call, like those of the
+// interpreter but the tasks.
+var natives = map[string]*Func{}
+
+// denied are the capabilities whose natives fail when they are called. By
+// default they are those that the interpreter denies: the natives that
+// read and write files, run programs or connect to the network.
+var denied = map[string]bool{"file": true, "exec": true, "network": true}
+
+// Allow lets the program call the natives with any of caps, which are
+// named like the capabilities of the interpreter. glox build bakes in the
+// capabilities allowed with -allow.
+func Allow(caps ...string) {
+	for _, c := range caps {
+		delete(denied, c)
+	}
+}
+
+// Deny makes the natives with any of caps fail, like -deny does.
+func Deny(caps ...string) {
+	for _, c := range caps {
+		denied[c] = true
+	}
+}
+
+func native(name, cap string, nargs int, fn func(args []Value) (Value, error)) {
+	f := &Func{name: name, params: make([]string, nargs), native: true, cap: cap}
+	f.fn = func(args []Value) Value {
+		v, err := fn(args)
+		if err != nil {
+			panic(err)
+		}
+		return v
+	}
+	natives[name] = f
+}
+
+// Native returns the native called name, nil if there is none.
+func Native(name string) Value {
+	if f := natives[name]; f != nil {
+		return Value{kind: funKind, fn: f}
+	}
+	return Nil
+}
+
+// HasNative tells if there is a native called name.
+func HasNative(name string) bool {
+	return natives[name] != nil
+}
+
+// callNative calls f and turns its error, or a panic of Go, into a runtime
+// error at line.
+func callNative(line int, f *Func, args []Value) Value {
+	defer func() {
+		if e := recover(); e != nil {
+			if re, ok := e.(RuntimeError); ok {
+				panic(re)
+			}
+			Fail(line, fmt.Sprintf("native '%v' failed: %v", f.name, e))
+		}
+	}()
+	if denied[f.cap] {
+		Fail(line, fmt.Sprintf("capability not permitted: '%v' needs %v", f.name, f.cap))
+	}
+	return f.fn(args)
+}
+
+// stringArg returns argument i of a native, which must be a string.
+func stringArg(args []Value, i int) (string, error) {
+	if args[i].kind != strKind {
+		return "", fmt.Errorf("argument %v must be a string", i+1)
+	}
+	return args[i].str, nil
+}
+
+var (
+	args    []string
+	stdin   = bufio.NewReader(os.Stdin)
+	tempDir string // created on the first use
+)
+
+func root() (string, error) {
+	if tempDir == "" {
+		dir, err := os.MkdirTemp("", "glox-")
+		if err != nil {
+			return "", err
+		}
+		tempDir = dir
+	}
+	return tempDir, nil
+}
+
+// cleanup removes the directory of tempFile() and tempDir().
+func cleanup() {
+	if tempDir != "" {
+		os.RemoveAll(tempDir)
+	}
+}
+
+func init() {
+	native("clock", "time", 0, func([]Value) (Value, error) {
+		return Number(float64(time.Now().UnixNano())), nil
+	})
+	native("tempDir", "tempfile", 0, func([]Value) (Value, error) {
+		dir, err := root()
+		if err != nil {
+			return Nil, err
+		}
+		dir, err = os.MkdirTemp(dir, "dir-")
+		return String(dir), err
+	})
+	native("tempFile", "tempfile", 0, func([]Value) (Value, error) {
+		dir, err := root()
+		if err != nil {
+			return Nil, err
+		}
+		f, err := os.CreateTemp(dir, "file-")
+		if err != nil {
+			return Nil, err
+		}
+		defer f.Close()
+		return String(f.Name()), nil
+	})
+	native("readFile", "file", 1, func(args []Value) (Value, error) {
+		path, err := stringArg(args, 0)
+		if err != nil {
+			return Nil, err
+		}
+		data, err := os.ReadFile(path)
+		return String(string(data)), err
+	})
+	native("writeFile", "file", 2, func(args []Value) (Value, error) {
+		path, err := stringArg(args, 0)
+		if err != nil {
+			return Nil, err
+		}
+		text, err := stringArg(args, 1)
+		if err != nil {
+			return Nil, err
+		}
+		return Nil, os.WriteFile(path, []byte(text), 0644)
+	})
+	native("exec", "exec", 1, func(args []Value) (Value, error) {
+		// The command line is split at spaces and run without a shell.
+		line, err := stringArg(args, 0)
+		if err != nil {
+			return Nil, err
+		}
+		argv := strings.Fields(line)
+		if len(argv) == 0 {
+			return Nil, errors.New("empty command")
+		}
+		out, err := exec.Command(argv[0], argv[1:]...).CombinedOutput()
+		return String(string(out)), err
+	})
+	native("httpGet", "network", 1, func(args []Value) (Value, error) {
+		url, err := stringArg(args, 0)
+		if err != nil {
+			return Nil, err
+		}
+		resp, err := http.Get(url)
+		if err != nil {
+			return Nil, err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err == nil && resp.StatusCode != http.StatusOK {
+			err = errors.New(resp.Status)
+		}
+		return String(string(body)), err
+	})
+	native("args", "args", 0, func([]Value) (Value, error) {
+		return Number(float64(len(args))), nil
+	})
+	native("arg", "args", 1, func(a []Value) (Value, error) {
+		i := a[0].num
+		if a[0].kind != numKind || i != float64(int(i)) {
+			return Nil, fmt.Errorf("argument 1 must be an integer")
+		}
+		if i < 0 || i >= float64(len(args)) {
+			return Nil, nil
+		}
+		return String(args[int(i)]), nil
+	})
+	native("toString", "", 1, func(args []Value) (Value, error) {
+		return String(args[0].String()), nil
+	})
+	native("deepEquals", "", 2, func(args []Value) (Value, error) {
+		x, y := args[0], args[1]
+		if x.kind == numKind && y.kind == numKind && x.num != x.num {
+			return Bool(y.num != y.num), nil
+		}
+		return Bool(Equal(x, y)), nil
+	})
+	native("readLine", "stdin", 0, func([]Value) (Value, error) {
+		stdout.Flush()
+		line, err := stdin.ReadString('\n')
+		if err == io.EOF {
+			if line == "" {
+				return Nil, nil
+			}
+			err = nil
+		}
+		return String(strings.TrimRight(line, "\r\n")), err
+	})
+}
@@ -0,0 +1,168 @@
+package loxrt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RuntimeError is an error of the script, with the calls that led to it.
+type RuntimeError struct {
+	line  int
+	msg   string
+	trace []frame
+}
+
+// TraceDepth is the number of innermost and outermost frames shown in
+// stack traces, 0 shows all of them.
+var TraceDepth = 10
+
+func (e RuntimeError) Error() string {
+	s := fmt.Sprintf("[line %v] runtime error: %v", e.line, e.msg)
+	if len(e.trace) == 0 {
+		return s
+	}
+
+	// Walk from the innermost call outwards, each frame is at the line of the
+	// call into the frame above it.
+	lines := make([]string, 0, len(e.trace)+1)
+	line := e.line
+	for i := len(e.trace) - 1; i >= 0; i-- {
+		lines = append(lines, fmt.Sprintf("  [line %v] in %v", line, e.trace[i].fn.frameName()))
+		line = e.trace[i].line
+	}
+	lines = append(lines, fmt.Sprintf("  [line %v] in script", line))
+
+	if n, d := len(lines), TraceDepth; d > 0 && n > 2*d {
+		elided := fmt.Sprintf("  ... %v frames elided ...", commas(n-2*d))
+		lines = append(append(lines[:d:d], elided), lines[n-d:]...)
+	}
+	return s + "\n" + strings.Join(lines, "\n")
+}
+
+// commas formats n with thousands separators.
+func commas(n int) string {
+	s := fmt.Sprint(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// Fail stops the script with the runtime error msg at line.
+func Fail(line int, msg string) {
+	trace := make([]frame, len(callStack))
+	copy(trace, callStack)
+	panic(RuntimeError{line: line, msg: msg, trace: trace})
+}
+
+// Add returns x + y, the sum of two numbers or two strings joined.
+func Add(line int, x, y Value) Value {
+	switch {
+	case x.kind == numKind && y.kind == numKind:
+		return Number(x.num + y.num)
+	case x.kind == strKind && y.kind == strKind:
+		return String(x.str + y.str)
+	}
+	Fail(line, operandsError("+", "two numbers or two strings", x, y))
+	return Nil
+}
+
+// numbers returns the numbers in x and y, the operands of the arithmetic
+// operator op at line.
+func numbers(line int, op string, x, y Value) (float64, float64) {
+	if x.kind != numKind || y.kind != numKind {
+		Fail(line, operandsError(op, "numbers", x, y))
+	}
+	return x.num, y.num
+}
+
+// comparands returns numbers that compare like x and y, the operands of the
+// comparison op at line. Two strings compare in the order of their bytes,
+// they are turned into strings.Compare(x, y) and 0.
+func comparands(line int, op string, x, y Value) (float64, float64) {
+	switch {
+	case x.kind == numKind && y.kind == numKind:
+		return x.num, y.num
+	case x.kind == strKind && y.kind == strKind:
+		return float64(strings.Compare(x.str, y.str)), 0
+	}
+	Fail(line, operandsError(op, "two numbers or two strings", x, y))
+	return 0, 0
+}
+
+// operandsError is the message of the error of the operator op, that wants
+// operands other than x and y.
+func operandsError(op, want string, x, y Value) string {
+	return fmt.Sprintf("operands of '%v' must be %v, got %v and %v", op, want, typeName(x), typeName(y))
+}
+
+// typeName names the type of v like Value.Type of the interpreter.
+func typeName(v Value) string {
+	switch v.kind {
+	case nilKind:
+		return "nil"
+	case boolKind:
+		return "boolean"
+	case numKind:
+		return "number"
+	case strKind:
+		return "string"
+	case funKind:
+		if v.fn.native {
+			return "native"
+		}
+		return "function"
+	}
+	return "uninitialized"
+}
+
+func Sub(line int, x, y Value) Value {
+	a, b := numbers(line, "-", x, y)
+	return Number(a - b)
+}
+
+func Mul(line int, x, y Value) Value {
+	a, b := numbers(line, "*", x, y)
+	return Number(a * b)
+}
+
+func Div(line int, x, y Value) Value {
+	a, b := numbers(line, "/", x, y)
+	if b == 0 {
+		Fail(line, "division by zero")
+	}
+	return Number(a / b)
+}
+
+func Less(line int, x, y Value) Value {
+	a, b := comparands(line, "<", x, y)
+	return Bool(a < b)
+}
+
+func LessEqual(line int, x, y Value) Value {
+	a, b := comparands(line, "<=", x, y)
+	return Bool(a <= b)
+}
+
+func Greater(line int, x, y Value) Value {
+	a, b := comparands(line, ">", x, y)
+	return Bool(a > b)
+}
+
+func GreaterEqual(line int, x, y Value) Value {
+	a, b := comparands(line, ">=", x, y)
+	return Bool(a >= b)
+}
+
+// Neg returns -x.
+func Neg(line int, x Value) Value {
+	if x.kind != numKind {
+		Fail(line, "operand must be a number")
+	}
+	return Number(-x.num)
+}
+
+// Not returns !x.
+func Not(x Value) Value {
+	return Bool(!Truthy(x))
+}
@@ -0,0 +1,9 @@
+package loxrt
+
+import "embed"
+
+// Source holds the files of this package, so that glox build can compile
+// programs without fetching the module.
+//
+//go:embed *.go
+var Source embed.FS